@@ -8,20 +8,32 @@ import (
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/quanghia24/mySmartHome/cmd/mqtt"
+	"github.com/quanghia24/mySmartHome/services/alert"
+	"github.com/quanghia24/mySmartHome/services/anomaly"
 	"github.com/quanghia24/mySmartHome/services/cart"
 	"github.com/quanghia24/mySmartHome/services/device"
 	"github.com/quanghia24/mySmartHome/services/doorpwd"
+	"github.com/quanghia24/mySmartHome/services/escalation"
+	"github.com/quanghia24/mySmartHome/services/events"
+	"github.com/quanghia24/mySmartHome/services/export"
+	"github.com/quanghia24/mySmartHome/services/household"
 	"github.com/quanghia24/mySmartHome/services/log_device"
 	"github.com/quanghia24/mySmartHome/services/log_sensor"
 	"github.com/quanghia24/mySmartHome/services/notification"
 	"github.com/quanghia24/mySmartHome/services/order"
 	"github.com/quanghia24/mySmartHome/services/plan"
 	"github.com/quanghia24/mySmartHome/services/product"
+	"github.com/quanghia24/mySmartHome/services/retention"
+	"github.com/quanghia24/mySmartHome/services/rollup"
 	"github.com/quanghia24/mySmartHome/services/room"
 	"github.com/quanghia24/mySmartHome/services/schedule"
 	"github.com/quanghia24/mySmartHome/services/sensor"
+	"github.com/quanghia24/mySmartHome/services/shadow"
 	"github.com/quanghia24/mySmartHome/services/statistic"
+	"github.com/quanghia24/mySmartHome/services/stream"
+	"github.com/quanghia24/mySmartHome/services/tariff"
 	"github.com/quanghia24/mySmartHome/services/user"
+	"github.com/quanghia24/mySmartHome/services/webhook"
 )
 
 type APIServer struct {
@@ -48,13 +60,43 @@ func (s *APIServer) Run() error {
 		})
 	})
 
-	mqttClient := mqtt.NewClient(s.db)
-
 	subrouter := router.PathPrefix("/api/v1").Subrouter()
 
 	notiStore := notification.NewStore(s.db)
 
 	userStore := user.NewStore(s.db)
+	dispatcher := notification.NewDispatcher(notiStore, userStore)
+	webhookStore := webhook.NewStore(s.db)
+	webhookSender := webhook.NewSender(webhookStore)
+
+	deviceStore := device.NewStore(s.db)
+	logDeviceStore := log_device.NewStore(s.db)
+	sensorStore := sensor.NewStore(s.db)
+	logSensorStore := log_sensor.NewStore(s.db)
+	planStore := plan.NewStore(s.db)
+	alertStore := alert.NewStore(s.db)
+	shadowStore := shadow.NewStore(s.db)
+
+	// the feeds' values are published on the bus, subscribe before connecting
+	bus := events.NewBus()
+	shadows := shadow.NewTracker(shadowStore, deviceStore, bus)
+	liveStream := stream.NewHub()
+	evaluator := plan.NewEvaluator()
+	sensor.NewMonitor(bus, sensorStore, logSensorStore, planStore, alertStore, anomaly.NewDetector(logSensorStore), evaluator).Subscribe()
+	device.SubscribeLogs(bus, logDeviceStore)
+	device.SubscribeAutoControl(bus, deviceStore, shadows)
+	device.SubscribeLockAlerts(bus, alertStore)
+	shadows.Subscribe(bus)
+	dispatcher.Subscribe(bus)
+	webhookSender.Subscribe(bus)
+	liveStream.Subscribe(bus)
+
+	feeds := mqtt.NewSubscriptions(deviceStore, sensorStore, bus)
+	mqtt.NewClient(feeds)
+	go shadows.Start()
+	go dispatcher.StartReceiptPolling()
+	go dispatcher.StartDigests()
+
 	userHanlder := user.NewHandler(userStore, notiStore)
 	userHanlder.RegisterRoutes(subrouter)
 
@@ -64,52 +106,82 @@ func (s *APIServer) Run() error {
 
 	orderStore := order.NewStore(s.db)
 
-	cartHandler := cart.NewHandler(orderStore, productStore, userStore)
+	cartHandler := cart.NewHandler(orderStore, productStore, userStore, webhookSender, dispatcher)
 	cartHandler.RegisterRouter(subrouter)
 
 	roomStore := room.NewStore(s.db)
 	roomHandler := room.NewHandler(roomStore, userStore)
 	roomHandler.RegisterRoutes(subrouter)
 
-	logDeviceStore := log_device.NewStore(s.db)
-	logDeviceHandler := log_device.NewHandler(logDeviceStore, userStore)
-	logDeviceHandler.RegisterRoutes(subrouter)
-
 	doorStore := doorpwd.NewStore(s.db)
 
-	deviceStore := device.NewStore(s.db)
-	deviceHandler := device.NewHandler(deviceStore, userStore, roomStore, logDeviceStore, doorStore, mqttClient)
+	logDeviceHandler := log_device.NewHandler(logDeviceStore, userStore, deviceStore)
+	logDeviceHandler.RegisterRoutes(subrouter)
+
+	deviceHandler := device.NewHandler(deviceStore, sensorStore, userStore, roomStore, logDeviceStore, doorStore, feeds, shadows, webhookSender, dispatcher)
 	deviceHandler.RegisterRoutes(subrouter)
 
-	logSensorStore := log_sensor.NewStore(s.db)
 	logSensorHandler := log_sensor.NewHandler(logSensorStore)
 	logSensorHandler.RegisterRoutes(subrouter)
 
-	planStore := plan.NewStore(s.db)
-	planHandler := plan.NewHandler(planStore)
+	planHandler := plan.NewHandler(planStore, alertStore, evaluator)
 	planHandler.RegisterRoutes(subrouter)
 
-	sensorStore := sensor.NewStore(s.db)
-	sensorHandler := sensor.NewHandler(sensorStore, userStore, logSensorStore, planStore, mqttClient)
+	householdStore := household.NewStore(s.db)
+	householdHandler := household.NewHandler(householdStore, userStore)
+	householdHandler.RegisterRoutes(subrouter)
+
+	alertHandler := alert.NewHandler(alertStore, userStore, householdStore)
+	alertHandler.RegisterRoutes(subrouter)
+
+	rollupStore := rollup.NewStore(s.db)
+
+	sensorHandler := sensor.NewHandler(sensorStore, deviceStore, userStore, roomStore, logSensorStore, rollupStore, alertStore, evaluator, feeds)
 	sensorHandler.RegisterRoutes(subrouter)
 
 	go sensorHandler.StartSensorDataPolling()
 
 	scheduleStore := schedule.NewStore(s.db)
-	scheduleHandler := schedule.NewHandler(scheduleStore, deviceStore, logDeviceStore, doorStore, userStore)
+	scheduleHandler := schedule.NewHandler(scheduleStore, deviceStore, logDeviceStore, doorStore, userStore, shadows, webhookSender, dispatcher)
 	scheduleHandler.RegisterRoutes(subrouter)
 
-	statisticHandler := statistic.NewHandler(logDeviceStore, logSensorStore, userStore, roomStore, deviceStore, sensorStore)
+	electricTariff, err := tariff.Load()
+	if err != nil {
+		return err
+	}
+
+	retentionConfig, err := retention.Load()
+	if err != nil {
+		return err
+	}
+
+	compactor := retention.NewCompactor(retentionConfig, rollupStore, sensorStore)
+	go compactor.Start()
+
+	escalationConfig, err := escalation.Load()
+	if err != nil {
+		return err
+	}
+
+	escalator := escalation.NewEscalator(escalationConfig, alertStore, userStore, householdStore, dispatcher)
+	go escalator.Start()
+
+	statisticHandler := statistic.NewHandler(logDeviceStore, logSensorStore, userStore, roomStore, deviceStore, sensorStore, rollupStore, retentionConfig, electricTariff)
 	statisticHandler.RegisterRoutes(subrouter)
 
-	notiHandler := notification.NewHandler(notiStore, userStore)
+	exportHandler := export.NewHandler(logDeviceStore, logSensorStore, userStore, roomStore, deviceStore, sensorStore, rollupStore, retentionConfig)
+	exportHandler.RegisterRoutes(subrouter)
+
+	notiHandler := notification.NewHandler(notiStore, userStore, dispatcher)
 	notiHandler.RegisterRoutes(subrouter)
 
-	scheduleHandler.StartSchedule()
+	webhookHandler := webhook.NewHandler(webhookStore, userStore, webhookSender)
+	webhookHandler.RegisterRoutes(subrouter)
 
-	// mqtt.ResubscribeDevices(deviceStore, mqttClient, logDeviceStore)
-	// mqtt.ResubscribeSensors(sensorStore, deviceStore, mqttClient, planStore, logSensorStore, notiStore)
-	// fmt.Println("Reconnected to mqtt")
+	streamHandler := stream.NewHandler(liveStream, userStore)
+	streamHandler.RegisterRoutes(subrouter)
+
+	scheduleHandler.StartSchedule()
 
 	fmt.Println("Listening on port", s.addr)
 
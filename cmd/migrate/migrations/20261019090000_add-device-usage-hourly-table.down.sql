@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `device_usage_hourly`;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `device_usage_hourly` (
+    `deviceId` INT UNSIGNED NOT NULL,
+    `hour` DATETIME NOT NULL,           -- start of the UTC hour
+    `onSeconds` DOUBLE NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (`deviceId`, `hour`),
+    FOREIGN KEY (`deviceId`) REFERENCES devices(`feedId`) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `sensor_stats_hourly`;
\ No newline at end of file
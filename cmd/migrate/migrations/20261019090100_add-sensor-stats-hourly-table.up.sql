@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS `sensor_stats_hourly` (
+    `sensorId` INT UNSIGNED NOT NULL,
+    `hour` DATETIME NOT NULL,           -- start of the UTC hour
+    `minValue` DOUBLE NOT NULL,
+    `maxValue` DOUBLE NOT NULL,
+    `sumValue` DOUBLE NOT NULL,
+    `count` INT UNSIGNED NOT NULL,
+
+    PRIMARY KEY (`sensorId`, `hour`),
+    FOREIGN KEY (`sensorId`) REFERENCES sensors(`feedId`) ON DELETE CASCADE
+);
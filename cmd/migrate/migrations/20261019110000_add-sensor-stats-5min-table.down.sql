@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `sensor_stats_5min`;
\ No newline at end of file
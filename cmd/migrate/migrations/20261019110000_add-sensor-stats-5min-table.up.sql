@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS `sensor_stats_5min` (
+    `sensorId` INT UNSIGNED NOT NULL,
+    `bucket` DATETIME NOT NULL,         -- start of the UTC 5 minute bucket
+    `minValue` DOUBLE NOT NULL,
+    `maxValue` DOUBLE NOT NULL,
+    `sumValue` DOUBLE NOT NULL,
+    `count` INT UNSIGNED NOT NULL,
+
+    PRIMARY KEY (`sensorId`, `bucket`),
+    FOREIGN KEY (`sensorId`) REFERENCES sensors(`feedId`) ON DELETE CASCADE
+);
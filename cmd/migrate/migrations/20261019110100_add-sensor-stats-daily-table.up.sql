@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `sensor_stats_daily` (
+    `sensorId` INT UNSIGNED NOT NULL,
+    `day` DATE NOT NULL,                -- calendar day in the owner's timezone
+    `dayStart` DATETIME NOT NULL,       -- UTC bounds of that day
+    `dayEnd` DATETIME NOT NULL,
+    `minValue` DOUBLE NOT NULL,
+    `maxValue` DOUBLE NOT NULL,
+    `sumValue` DOUBLE NOT NULL,
+    `count` INT UNSIGNED NOT NULL,
+
+    PRIMARY KEY (`sensorId`, `day`),
+    FOREIGN KEY (`sensorId`) REFERENCES sensors(`feedId`) ON DELETE CASCADE
+);
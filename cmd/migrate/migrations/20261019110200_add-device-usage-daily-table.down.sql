@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `device_usage_daily`;
\ No newline at end of file
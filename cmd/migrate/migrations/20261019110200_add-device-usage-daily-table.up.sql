@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `device_usage_daily` (
+    `deviceId` INT UNSIGNED NOT NULL,
+    `day` DATE NOT NULL,                -- calendar day in the owner's timezone
+    `dayStart` DATETIME NOT NULL,       -- UTC bounds of that day
+    `dayEnd` DATETIME NOT NULL,
+    `onSeconds` DOUBLE NOT NULL,
+
+    PRIMARY KEY (`deviceId`, `day`),
+    FOREIGN KEY (`deviceId`) REFERENCES devices(`feedId`) ON DELETE CASCADE
+);
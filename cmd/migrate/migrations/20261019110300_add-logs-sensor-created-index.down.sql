@@ -0,0 +1 @@
+DROP INDEX `idx_logs_sensor_created` ON `logs_sensor`;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- the compactor deletes raw readings by age
+CREATE INDEX `idx_logs_sensor_created` ON `logs_sensor` (`type`, `createdAt`);
@@ -0,0 +1 @@
+ALTER TABLE `sensors` DROP COLUMN `anomalySensitivity`;
\ No newline at end of file
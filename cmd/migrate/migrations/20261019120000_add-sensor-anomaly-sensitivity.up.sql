@@ -0,0 +1 @@
+ALTER TABLE `sensors` ADD COLUMN `anomalySensitivity` ENUM('off', 'low', 'medium', 'high') NOT NULL DEFAULT 'medium';
\ No newline at end of file
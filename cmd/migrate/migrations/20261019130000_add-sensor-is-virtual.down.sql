@@ -0,0 +1 @@
+ALTER TABLE `sensors` DROP COLUMN `isVirtual`;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `sensors` ADD COLUMN `isVirtual` BOOLEAN NOT NULL DEFAULT FALSE;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `sensors` MODIFY `type` ENUM('humidity', 'temperature', 'brightness') NOT NULL;
\ No newline at end of file
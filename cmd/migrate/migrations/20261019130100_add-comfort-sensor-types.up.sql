@@ -0,0 +1 @@
+ALTER TABLE `sensors` MODIFY `type` ENUM('humidity', 'temperature', 'brightness', 'heat_index', 'dew_point', 'absolute_humidity', 'comfort') NOT NULL;
\ No newline at end of file
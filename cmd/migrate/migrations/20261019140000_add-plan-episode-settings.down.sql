@@ -0,0 +1,4 @@
+ALTER TABLE `plans`
+    DROP COLUMN `hysteresis`,
+    DROP COLUMN `minDurationSeconds`,
+    DROP COLUMN `cooldownSeconds`;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE `plans`
+    ADD COLUMN `hysteresis` DOUBLE NOT NULL DEFAULT 0,
+    ADD COLUMN `minDurationSeconds` INT UNSIGNED NOT NULL DEFAULT 0,
+    ADD COLUMN `cooldownSeconds` INT UNSIGNED NOT NULL DEFAULT 0;
\ No newline at end of file
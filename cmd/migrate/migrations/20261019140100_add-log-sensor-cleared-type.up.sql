@@ -0,0 +1 @@
+ALTER TABLE `logs_sensor` MODIFY `type` ENUM('creation', 'data', 'warning', 'cleared') NOT NULL;
\ No newline at end of file
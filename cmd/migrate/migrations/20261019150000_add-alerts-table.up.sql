@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS `alerts` (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `sourceType` ENUM('sensor', 'device') NOT NULL,
+    `sourceId` INT UNSIGNED NOT NULL,    -- feedId of the sensor or device
+    `kind` VARCHAR(50) NOT NULL,         -- what went wrong, e.g. threshold or anomaly
+    `severity` ENUM('info', 'warning', 'critical') NOT NULL DEFAULT 'warning',
+    `message` TEXT NOT NULL,
+    `value` VARCHAR(255) NOT NULL,
+    `status` ENUM('open', 'acknowledged', 'resolved') NOT NULL DEFAULT 'open',
+    `openedAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `acknowledgedAt` TIMESTAMP NULL,
+    `acknowledgedBy` INT UNSIGNED NULL,
+    `resolvedAt` TIMESTAMP NULL,
+
+    PRIMARY KEY (`id`),
+    INDEX `idx_alerts_user_status` (`userId`, `status`),
+    INDEX `idx_alerts_source` (`sourceType`, `sourceId`, `kind`, `status`),
+    FOREIGN KEY (`userId`) REFERENCES users(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`acknowledgedBy`) REFERENCES users(`id`) ON DELETE SET NULL
+);
\ No newline at end of file
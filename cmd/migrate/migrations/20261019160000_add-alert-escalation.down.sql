@@ -0,0 +1,3 @@
+ALTER TABLE `alerts`
+    DROP COLUMN `escalationLevel`,
+    DROP COLUMN `escalatedAt`;
\ No newline at end of file
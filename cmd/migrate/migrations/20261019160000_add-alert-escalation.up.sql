@@ -0,0 +1,3 @@
+ALTER TABLE `alerts`
+    ADD COLUMN `escalationLevel` TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    ADD COLUMN `escalatedAt` TIMESTAMP NULL;
\ No newline at end of file
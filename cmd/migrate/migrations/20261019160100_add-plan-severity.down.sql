@@ -0,0 +1 @@
+ALTER TABLE `plans` DROP COLUMN `severity`;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `plans` ADD COLUMN `severity` ENUM('info', 'warning', 'critical') NOT NULL DEFAULT 'warning';
\ No newline at end of file
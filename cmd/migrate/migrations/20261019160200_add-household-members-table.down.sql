@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `household_members`;
\ No newline at end of file
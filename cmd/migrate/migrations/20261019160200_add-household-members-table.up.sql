@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `household_members` (
+    `ownerId` INT UNSIGNED NOT NULL,     -- the user the home belongs to
+    `memberId` INT UNSIGNED NOT NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`ownerId`, `memberId`),
+    FOREIGN KEY (`ownerId`) REFERENCES users(`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`memberId`) REFERENCES users(`id`) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `notification_channels`;
\ No newline at end of file
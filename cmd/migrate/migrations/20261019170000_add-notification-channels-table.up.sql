@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `notification_channels` (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `type` ENUM('email', 'webhook', 'telegram') NOT NULL,
+    `target` VARCHAR(512) NOT NULL,     -- address, url or chat id
+    `escalationOnly` BOOLEAN NOT NULL DEFAULT FALSE,
+    `enabled` BOOLEAN NOT NULL DEFAULT TRUE,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    FOREIGN KEY (`userId`) REFERENCES users(`id`) ON DELETE CASCADE
+);
\ No newline at end of file
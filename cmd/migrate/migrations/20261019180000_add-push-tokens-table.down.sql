@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `push_tokens`;
\ No newline at end of file
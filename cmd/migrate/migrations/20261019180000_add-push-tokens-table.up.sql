@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `push_tokens` (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `token` VARCHAR(255) NOT NULL UNIQUE,   -- Expo push token of a phone
+    `platform` VARCHAR(16) NOT NULL DEFAULT '',
+    `appVersion` VARCHAR(32) NOT NULL DEFAULT '',
+    `lastSeenAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    INDEX (`userId`),
+    FOREIGN KEY (`userId`) REFERENCES users(`id`) ON DELETE CASCADE
+);
\ No newline at end of file
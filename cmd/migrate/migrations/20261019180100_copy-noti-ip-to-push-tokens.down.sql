@@ -0,0 +1,2 @@
+INSERT IGNORE INTO `noti-ip` (userId, ip)
+SELECT userId, token FROM `push_tokens` ORDER BY lastSeenAt DESC;
\ No newline at end of file
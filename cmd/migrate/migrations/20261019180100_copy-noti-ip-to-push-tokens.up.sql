@@ -0,0 +1,2 @@
+INSERT IGNORE INTO `push_tokens` (userId, token, lastSeenAt, createdAt)
+SELECT userId, ip, createdAt, createdAt FROM `noti-ip`;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `noti-ip` (
+    id INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    userId INT UNSIGNED NOT NULL UNIQUE,
+    ip VARCHAR(255) NOT NULL,
+    createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY(id),
+    FOREIGN KEY(userId) REFERENCES users(id) ON DELETE CASCADE
+)
\ No newline at end of file
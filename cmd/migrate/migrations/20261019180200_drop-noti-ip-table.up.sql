@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `noti-ip`;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `push_tickets`;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `push_tickets` (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `notiId` INT UNSIGNED NOT NULL,
+    `token` VARCHAR(255) NOT NULL,
+    `ticketId` VARCHAR(64),                 -- given by Expo when it accepted the push
+    `status` ENUM('sent', 'delivered', 'error') NOT NULL,
+    `error` VARCHAR(255),
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `checkedAt` TIMESTAMP NULL,
+
+    PRIMARY KEY (`id`),
+    INDEX (`status`, `createdAt`),
+    INDEX (`ticketId`),
+    FOREIGN KEY (`notiId`) REFERENCES noti(`id`) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `noti` DROP COLUMN `deliveryStatus`, DROP COLUMN `deliveryError`;
\ No newline at end of file
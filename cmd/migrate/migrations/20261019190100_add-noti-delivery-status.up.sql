@@ -0,0 +1,3 @@
+ALTER TABLE `noti`
+    ADD COLUMN `deliveryStatus` ENUM('sent', 'delivered', 'error') NULL,
+    ADD COLUMN `deliveryError` VARCHAR(255) NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `noti` DROP INDEX `noti_user_id`, DROP COLUMN `readAt`, DROP COLUMN `category`;
\ No newline at end of file
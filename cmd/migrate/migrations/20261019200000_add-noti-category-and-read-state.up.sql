@@ -0,0 +1,4 @@
+ALTER TABLE `noti`
+    ADD COLUMN `category` ENUM('threshold', 'door', 'schedule', 'system') NOT NULL DEFAULT 'system',
+    ADD COLUMN `readAt` TIMESTAMP NULL,
+    ADD INDEX `noti_user_id` (`userId`, `category`, `id`);
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `notification_preferences` (
+    `userId` INT UNSIGNED NOT NULL,
+    `event` VARCHAR(64) NOT NULL,           -- threshold.<sensor type>, door, schedule or order
+    `channels` SET('push', 'email', 'webhook', 'telegram') NOT NULL DEFAULT '',
+
+    PRIMARY KEY (`userId`, `event`),
+    FOREIGN KEY (`userId`) REFERENCES users(`id`) ON DELETE CASCADE
+);
\ No newline at end of file
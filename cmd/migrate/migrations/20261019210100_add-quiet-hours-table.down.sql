@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `quiet_hours`;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `quiet_hours` (
+    `userId` INT UNSIGNED NOT NULL,
+    `startTime` TIME NOT NULL,
+    `endTime` TIME NOT NULL,
+    `timezone` VARCHAR(64) NOT NULL DEFAULT 'Asia/Ho_Chi_Minh',
+
+    PRIMARY KEY (`userId`),
+    FOREIGN KEY (`userId`) REFERENCES users(`id`) ON DELETE CASCADE
+);
\ No newline at end of file
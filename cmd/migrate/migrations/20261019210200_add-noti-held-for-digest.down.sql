@@ -0,0 +1 @@
+ALTER TABLE `noti` DROP COLUMN `heldForDigest`;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `noti` ADD COLUMN `heldForDigest` BOOLEAN NOT NULL DEFAULT FALSE;
\ No newline at end of file
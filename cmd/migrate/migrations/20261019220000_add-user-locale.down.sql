@@ -0,0 +1 @@
+ALTER TABLE `users` DROP COLUMN `locale`;
\ No newline at end of file
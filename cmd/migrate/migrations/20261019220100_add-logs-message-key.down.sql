@@ -0,0 +1 @@
+ALTER TABLE `logs` DROP COLUMN `messageKey`, DROP COLUMN `params`;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `logs` ADD COLUMN `messageKey` VARCHAR(64) NULL, ADD COLUMN `params` JSON NULL;
\ No newline at end of file
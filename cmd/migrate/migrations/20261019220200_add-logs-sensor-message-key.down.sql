@@ -0,0 +1 @@
+ALTER TABLE `logs_sensor` DROP COLUMN `messageKey`, DROP COLUMN `params`;
\ No newline at end of file
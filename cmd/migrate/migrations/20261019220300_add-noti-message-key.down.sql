@@ -0,0 +1 @@
+ALTER TABLE `noti` DROP COLUMN `messageKey`, DROP COLUMN `params`;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `noti` ADD COLUMN `messageKey` VARCHAR(64) NULL, ADD COLUMN `params` JSON NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `webhooks`;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `webhooks` (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `userId` INT UNSIGNED NOT NULL,
+    `url` VARCHAR(512) NOT NULL,
+    `secret` VARCHAR(64) NOT NULL,      -- signs the body of the posts
+    `events` SET('device.state', 'sensor.reading', 'threshold.breach', 'door.unlock', 'schedule.run', 'order.status') NOT NULL DEFAULT '',
+    `enabled` BOOLEAN NOT NULL DEFAULT TRUE,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    FOREIGN KEY (`userId`) REFERENCES users(`id`) ON DELETE CASCADE
+);
\ No newline at end of file
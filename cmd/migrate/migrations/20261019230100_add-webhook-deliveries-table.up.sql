@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS `webhook_deliveries` (
+    `id` INT UNSIGNED AUTO_INCREMENT NOT NULL,
+    `webhookId` INT UNSIGNED NOT NULL,
+    `event` VARCHAR(32) NOT NULL,
+    `payload` JSON NOT NULL,
+    `status` ENUM('pending', 'delivered', 'failed') NOT NULL DEFAULT 'pending',
+    `attempts` INT NOT NULL DEFAULT 0,
+    `responseStatus` INT NULL,         -- of the last attempt
+    `error` VARCHAR(255) NULL,
+    `createdAt` TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    `deliveredAt` TIMESTAMP NULL,
+
+    PRIMARY KEY (`id`),
+    INDEX `webhook_deliveries_webhook_id` (`webhookId`, `id`),
+    FOREIGN KEY (`webhookId`) REFERENCES webhooks(`id`) ON DELETE CASCADE
+);
\ No newline at end of file
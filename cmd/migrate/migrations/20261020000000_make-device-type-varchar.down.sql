@@ -0,0 +1 @@
+ALTER TABLE `devices` MODIFY `type` ENUM('fan', 'light', 'door') NOT NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `sensors` MODIFY `type` VARCHAR(32) NOT NULL;
\ No newline at end of file
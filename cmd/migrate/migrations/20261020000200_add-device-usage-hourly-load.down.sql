@@ -0,0 +1 @@
+ALTER TABLE `device_usage_hourly` DROP COLUMN `loadSeconds`;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `device_usage_daily` DROP COLUMN `loadSeconds`;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `device_usage_daily` ADD COLUMN `loadSeconds` DOUBLE NOT NULL DEFAULT 0;
\ No newline at end of file
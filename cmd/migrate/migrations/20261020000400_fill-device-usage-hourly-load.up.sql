@@ -0,0 +1 @@
+UPDATE `device_usage_hourly` SET `loadSeconds` = `onSeconds`;
\ No newline at end of file
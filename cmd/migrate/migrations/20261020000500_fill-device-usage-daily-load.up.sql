@@ -0,0 +1 @@
+UPDATE `device_usage_daily` SET `loadSeconds` = `onSeconds`;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `devices` DROP COLUMN `levels`;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `devices` ADD COLUMN `levels` VARCHAR(255) NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `device_shadows`;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `device_shadows` (
+    `deviceId` INT UNSIGNED NOT NULL,
+    `desired` VARCHAR(255) NOT NULL DEFAULT '',
+    `desiredAt` TIMESTAMP NULL,
+    `reported` VARCHAR(255) NOT NULL DEFAULT '',
+    `reportedAt` TIMESTAMP NULL,
+    `status` ENUM('', 'pending', 'confirmed', 'failed') NOT NULL DEFAULT '',
+    `error` VARCHAR(255) NOT NULL DEFAULT '',
+
+    PRIMARY KEY (`deviceId`),
+    INDEX `device_shadows_status` (`status`, `desiredAt`),
+    FOREIGN KEY (`deviceId`) REFERENCES devices(`feedId`) ON DELETE CASCADE
+);
\ No newline at end of file
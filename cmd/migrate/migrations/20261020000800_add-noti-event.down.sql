@@ -0,0 +1 @@
+ALTER TABLE `noti` DROP COLUMN `event`;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `noti` ADD COLUMN `event` VARCHAR(64) NULL;
\ No newline at end of file
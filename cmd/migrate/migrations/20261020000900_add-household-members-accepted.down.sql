@@ -0,0 +1 @@
+ALTER TABLE `household_members` DROP COLUMN `acceptedAt`;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE `household_members` ADD COLUMN `acceptedAt` TIMESTAMP NULL;
\ No newline at end of file
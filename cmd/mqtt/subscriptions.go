@@ -0,0 +1,135 @@
+package mqtt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/quanghia24/mySmartHome/services/events"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// Subscriptions keeps one subscription per feed key. The device or the
+// sensor of a feed is looked up when a value comes in, so a renamed or
+// moved one is published as it is now.
+type Subscriptions struct {
+	devices types.DeviceStore
+	sensors types.SensorStore
+	bus     *events.Bus
+
+	mu     sync.Mutex
+	client MQTT.Client
+	feeds  map[string]bool
+}
+
+func NewSubscriptions(devices types.DeviceStore, sensors types.SensorStore, bus *events.Bus) *Subscriptions {
+	return &Subscriptions{
+		devices: devices,
+		sensors: sensors,
+		bus:     bus,
+		feeds:   map[string]bool{},
+	}
+}
+
+// Subscribe subscribes to the feed, unless it already is. A feed added
+// before the client is connected is subscribed to on connection.
+func (s *Subscriptions) Subscribe(feedKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.feeds[feedKey] {
+		return nil
+	}
+	s.feeds[feedKey] = true
+	if s.client == nil || !s.client.IsConnected() {
+		return nil
+	}
+	return s.subscribe(feedKey)
+}
+
+// Unsubscribe stops the updates of a deleted device's or sensor's feed.
+func (s *Subscriptions) Unsubscribe(feedKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.feeds[feedKey] {
+		return nil
+	}
+	delete(s.feeds, feedKey)
+	if s.client == nil || !s.client.IsConnected() {
+		return nil
+	}
+
+	token := s.client.Unsubscribe(topic(feedKey))
+	if token.Wait() && token.Error() != nil {
+		return fmt.Errorf("unsubscribe %s: %v", feedKey, token.Error())
+	}
+	return nil
+}
+
+// connected subscribes the client to the feeds of every device and sensor,
+// and to the ones added since. When they can't be listed, the feeds known
+// already are subscribed to anyway and the error is returned.
+func (s *Subscriptions) connected(client MQTT.Client) error {
+	devices, err := s.devices.GetAllDevices()
+	var sensors []types.Sensor
+	if err == nil {
+		sensors, err = s.sensors.GetAllSensor()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.client = client
+	if err == nil {
+		for _, d := range devices {
+			s.feeds[d.FeedKey] = true
+		}
+		for _, d := range sensors {
+			// virtual sensors have no feed, they are updated with their pair
+			if !d.Virtual {
+				s.feeds[d.FeedKey] = true
+			}
+		}
+	}
+
+	for feedKey := range s.feeds {
+		if err := s.subscribe(feedKey); err != nil {
+			fmt.Println("Failed to subscribe:", err)
+		}
+	}
+	return err
+}
+
+func (s *Subscriptions) subscribe(feedKey string) error {
+	token := s.client.Subscribe(topic(feedKey), 0, s.handle)
+	if token.Wait() && token.Error() != nil {
+		return fmt.Errorf("subscribe %s: %v", feedKey, token.Error())
+	}
+	return nil
+}
+
+// handle publishes a new value of a feed for its device or its sensor.
+func (s *Subscriptions) handle(client MQTT.Client, msg MQTT.Message) {
+	fmt.Printf("Received message on %s: %s\n", msg.Topic(), msg.Payload())
+
+	feedKey := strings.TrimPrefix(msg.Topic(), topic(""))
+	value := string(msg.Payload())
+
+	if d, err := s.devices.GetDeviceByFeedKey(feedKey); err == nil {
+		s.bus.DeviceStateChanged.Publish(events.DeviceStateChanged{Device: *d, Value: value, At: time.Now()})
+		return
+	}
+	if d, err := s.sensors.GetSensorByFeedKey(feedKey); err == nil {
+		s.bus.SensorReading.Publish(events.NewSensorReading(*d, value, time.Now()))
+		return
+	}
+	fmt.Println("no device or sensor for feed", feedKey)
+}
+
+func topic(feedKey string) string {
+	return fmt.Sprintf("%s/feeds/%s", os.Getenv("AIOUSER"), feedKey)
+}
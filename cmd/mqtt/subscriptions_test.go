@@ -0,0 +1,126 @@
+package mqtt
+
+import (
+	"fmt"
+	"testing"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/quanghia24/mySmartHome/services/events"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+type devices struct {
+	types.DeviceStore
+	devices map[string]types.Device
+}
+
+func (s *devices) GetDeviceByFeedKey(feedKey string) (*types.Device, error) {
+	d, ok := s.devices[feedKey]
+	if !ok {
+		return nil, fmt.Errorf("no device found for feedKey %s", feedKey)
+	}
+	return &d, nil
+}
+
+func (s *devices) GetAllDevices() ([]types.AllDeviceDataPayload, error) {
+	return nil, fmt.Errorf("database is down")
+}
+
+type sensors struct {
+	types.SensorStore
+	sensors map[string]types.Sensor
+}
+
+func (s *sensors) GetSensorByFeedKey(feedKey string) (*types.Sensor, error) {
+	d, ok := s.sensors[feedKey]
+	if !ok {
+		return nil, fmt.Errorf("no sensor found for feedKey %s", feedKey)
+	}
+	return &d, nil
+}
+
+type message struct {
+	MQTT.Message
+	topic   string
+	payload string
+}
+
+func (m message) Topic() string   { return m.topic }
+func (m message) Payload() []byte { return []byte(m.payload) }
+
+func TestHandleLooksUpTheFeedOnEachMessage(t *testing.T) {
+	t.Setenv("AIOUSER", "home")
+	ds := &devices{devices: map[string]types.Device{"fan-1": {FeedId: 1, Title: "Fan", UserID: 7}}}
+	ss := &sensors{sensors: map[string]types.Sensor{"temp-1": {FeedId: 2, Type: "temperature", UserID: 7}}}
+	bus := events.NewBus()
+	subs := NewSubscriptions(ds, ss, bus)
+
+	states := []events.DeviceStateChanged{}
+	bus.DeviceStateChanged.Subscribe(func(e events.DeviceStateChanged) { states = append(states, e) })
+	readings := []events.SensorReading{}
+	bus.SensorReading.Subscribe(func(e events.SensorReading) { readings = append(readings, e) })
+
+	subs.handle(nil, message{topic: "home/feeds/fan-1", payload: "50"})
+	ds.devices["fan-1"] = types.Device{FeedId: 1, Title: "Ceiling fan", UserID: 7, RoomID: 3}
+	subs.handle(nil, message{topic: "home/feeds/fan-1", payload: "75"})
+	subs.handle(nil, message{topic: "home/feeds/temp-1", payload: "31.26"})
+	subs.handle(nil, message{topic: "home/feeds/gone", payload: "1"})
+
+	if len(states) != 2 || states[0].Device.Title != "Fan" || states[0].Value != "50" {
+		t.Fatalf("unexpected states %+v", states)
+	}
+	if states[1].Device.Title != "Ceiling fan" || states[1].Device.RoomID != 3 {
+		t.Errorf("expected the renamed and moved device, got %+v", states[1].Device)
+	}
+	if len(readings) != 1 || readings[0].Sensor.FeedId != 2 || readings[0].Value != 31.3 {
+		t.Errorf("unexpected readings %+v", readings)
+	}
+}
+
+func TestSubscribeKeepsOneSubscriptionPerFeed(t *testing.T) {
+	subs := NewSubscriptions(&devices{}, &sensors{}, events.NewBus())
+
+	for _, key := range []string{"fan-1", "fan-1", "temp-1"} {
+		if err := subs.Subscribe(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := subs.Unsubscribe("temp-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(subs.feeds) != 1 || !subs.feeds["fan-1"] {
+		t.Errorf("expected only fan-1 subscribed, got %v", subs.feeds)
+	}
+}
+
+type token struct{ MQTT.Token }
+
+func (token) Wait() bool   { return true }
+func (token) Error() error { return nil }
+
+type client struct {
+	MQTT.Client
+	topics []string
+}
+
+func (c *client) Subscribe(topic string, qos byte, callback MQTT.MessageHandler) MQTT.Token {
+	c.topics = append(c.topics, topic)
+	return token{}
+}
+
+func TestConnectedResubscribesWhenTheFeedsCantBeListed(t *testing.T) {
+	t.Setenv("AIOUSER", "home")
+	subs := NewSubscriptions(&devices{}, &sensors{}, events.NewBus())
+	if err := subs.Subscribe("fan-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &client{}
+	if err := subs.connected(c); err == nil {
+		t.Error("expected the error listing the devices")
+	}
+	if subs.client != c || len(c.topics) != 1 || c.topics[0] != "home/feeds/fan-1" {
+		t.Errorf("expected the known feed subscribed with the new client, got %v", c.topics)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/quanghia24/mySmartHome/db"
+	"github.com/quanghia24/mySmartHome/services/rollup"
+)
+
+// rebuilds the statistic rollups from the raw device and sensor logs
+func main() {
+	db, err := db.NewMySQLStorage(mysql.Config{
+		User:                 os.Getenv("DB_USER"),
+		Passwd:               os.Getenv("DB_PASSWORD"),
+		Addr:                 os.Getenv("DB_ADDRESS"),
+		DBName:               os.Getenv("DB_NAME"),
+		Net:                  "tcp",
+		AllowNativePasswords: true,
+		ParseTime:            true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := rollup.NewStore(db).Backfill(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("rollups rebuilt in %v", time.Since(start))
+}
@@ -0,0 +1,50 @@
+package alert
+
+import "github.com/quanghia24/mySmartHome/types"
+
+// what an alert is about
+const (
+	KindThreshold = "threshold"
+	KindAnomaly   = "anomaly"
+	// a lock left open
+	KindOpen = "open"
+)
+
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// statuses an alert goes through, an active alert is not resolved yet
+const (
+	StatusOpen         = "open"
+	StatusAcknowledged = "acknowledged"
+	StatusResolved     = "resolved"
+)
+
+var activeStatuses = []string{StatusOpen, StatusAcknowledged}
+
+// Raise opens the alert unless its source already has an active one of the
+// same kind, it reports whether a new alert was opened.
+func Raise(store types.AlertStore, a types.Alert) (bool, error) {
+	active, err := store.GetActiveAlert(a.SourceType, a.SourceID, a.Kind)
+	if err != nil {
+		return false, err
+	}
+	if active != nil {
+		return false, nil
+	}
+
+	if a.Severity == "" {
+		a.Severity = SeverityWarning
+	}
+	return true, store.CreateAlert(a)
+}
+
+// Clear resolves the active alerts of the kind on the source once its
+// readings are back to normal.
+func Clear(store types.AlertStore, sourceType string, sourceId int, kind string) error {
+	_, err := store.ResolveActiveAlerts(sourceType, sourceId, kind)
+	return err
+}
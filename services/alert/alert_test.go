@@ -0,0 +1,74 @@
+package alert
+
+import (
+	"testing"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// memory keeps alerts in a slice, as far as Raise and Clear need them.
+type memory struct {
+	types.AlertStore
+	alerts []types.Alert
+}
+
+func (m *memory) CreateAlert(a types.Alert) error {
+	a.ID = len(m.alerts) + 1
+	a.Status = StatusOpen
+	m.alerts = append(m.alerts, a)
+	return nil
+}
+
+func (m *memory) GetActiveAlert(sourceType string, sourceId int, kind string) (*types.Alert, error) {
+	for _, a := range m.alerts {
+		if a.SourceType == sourceType && a.SourceID == sourceId && a.Kind == kind && a.Status != StatusResolved {
+			return &a, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memory) ResolveActiveAlerts(sourceType string, sourceId int, kind string) (int64, error) {
+	n := int64(0)
+	for i, a := range m.alerts {
+		if a.SourceType == sourceType && a.SourceID == sourceId && a.Kind == kind && a.Status != StatusResolved {
+			m.alerts[i].Status = StatusResolved
+			n++
+		}
+	}
+	return n, nil
+}
+
+func TestRaiseOncePerProblem(t *testing.T) {
+	store := &memory{}
+	a := types.Alert{UserID: 1, SourceType: "sensor", SourceID: 7, Kind: KindThreshold}
+
+	if opened, err := Raise(store, a); err != nil || !opened {
+		t.Fatalf("expected the alert to open, got %v, %v", opened, err)
+	}
+	if store.alerts[0].Severity != SeverityWarning {
+		t.Errorf("expected the default severity, got %q", store.alerts[0].Severity)
+	}
+
+	if opened, _ := Raise(store, a); opened {
+		t.Errorf("expected no second alert while the first is active")
+	}
+
+	// another kind of problem on the same sensor is its own alert
+	anomaly := a
+	anomaly.Kind = KindAnomaly
+	if opened, _ := Raise(store, anomaly); !opened {
+		t.Errorf("expected an anomaly alert next to the threshold one")
+	}
+
+	if err := Clear(store, "sensor", 7, KindThreshold); err != nil {
+		t.Fatal(err)
+	}
+	if store.alerts[0].Status != StatusResolved || store.alerts[1].Status != StatusOpen {
+		t.Errorf("expected only the threshold alert to resolve, got %+v", store.alerts)
+	}
+
+	if opened, _ := Raise(store, a); !opened {
+		t.Errorf("expected a new alert once the last one resolved")
+	}
+}
@@ -0,0 +1,147 @@
+package alert
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/types"
+	"github.com/quanghia24/mySmartHome/utils"
+)
+
+type Handler struct {
+	store      types.AlertStore
+	userStore  types.UserStore
+	households types.HouseholdStore
+}
+
+func NewHandler(store types.AlertStore, userStore types.UserStore, households types.HouseholdStore) *Handler {
+	return &Handler{
+		store:      store,
+		userStore:  userStore,
+		households: households,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/alerts", auth.WithJWTAuth(h.getAlerts, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/alerts/{id}", auth.WithJWTAuth(h.getAlert, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/alerts/{id}/acknowledge", auth.WithJWTAuth(h.acknowledgeAlert, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/alerts/{id}/resolve", auth.WithJWTAuth(h.resolveAlert, h.userStore)).Methods(http.MethodPut)
+}
+
+// statuses listed for the status query parameter
+var statusFilters = map[string][]string{
+	"active":           activeStatuses,
+	StatusOpen:         {StatusOpen},
+	StatusAcknowledged: {StatusAcknowledged},
+	StatusResolved:     {StatusResolved},
+	"all":              {StatusOpen, StatusAcknowledged, StatusResolved},
+}
+
+// getAlerts lists the alerts of the user and of the homes the user is a
+// member of, the active ones unless another status is asked for.
+func (h *Handler) getAlerts(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "active"
+	}
+	statuses, ok := statusFilters[status]
+	if !ok {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown status %q", status))
+		return
+	}
+
+	alerts, err := h.store.GetAlertsByUserID(userId, statuses)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, alerts)
+}
+
+func (h *Handler) getAlert(w http.ResponseWriter, r *http.Request) {
+	a, ok := h.ownedAlert(w, r)
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, a)
+}
+
+func (h *Handler) acknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	a, ok := h.ownedAlert(w, r)
+	if !ok {
+		return
+	}
+
+	if a.Status != StatusOpen {
+		utils.WriteError(w, http.StatusConflict, fmt.Errorf("alert %d is already %s", a.ID, a.Status))
+		return
+	}
+
+	if err := h.store.AcknowledgeAlert(a.ID, userId); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeAlert(w, a.ID)
+}
+
+func (h *Handler) resolveAlert(w http.ResponseWriter, r *http.Request) {
+	a, ok := h.ownedAlert(w, r)
+	if !ok {
+		return
+	}
+
+	if a.Status == StatusResolved {
+		utils.WriteError(w, http.StatusConflict, fmt.Errorf("alert %d is already resolved", a.ID))
+		return
+	}
+
+	if err := h.store.ResolveAlert(a.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.writeAlert(w, a.ID)
+}
+
+// ownedAlert loads the alert of the path, writing the error response when it
+// is neither the user's nor from a home the user is a member of.
+func (h *Handler) ownedAlert(w http.ResponseWriter, r *http.Request) (*types.Alert, bool) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid alert id"))
+		return nil, false
+	}
+
+	a, err := h.store.GetAlertByID(id)
+	if err == nil && a.UserID != userId {
+		var member bool
+		member, err = h.households.IsMember(a.UserID, userId)
+		if err == nil && !member {
+			err = fmt.Errorf("not a member")
+		}
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("alert %d not found", id))
+		return nil, false
+	}
+	return a, true
+}
+
+func (h *Handler) writeAlert(w http.ResponseWriter, id int) {
+	a, err := h.store.GetAlertByID(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, a)
+}
@@ -0,0 +1,196 @@
+package alert
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// the most alerts returned in one list
+const listLimit = 200
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+func (s *Store) CreateAlert(a types.Alert) error {
+	_, err := s.db.Exec(
+		"INSERT INTO alerts (userId, sourceType, sourceId, kind, severity, message, value) VALUES (?,?,?,?,?,?,?)",
+		a.UserID, a.SourceType, a.SourceID, a.Kind, a.Severity, a.Message, a.Value,
+	)
+	return err
+}
+
+// the title of the sensor or device the alert is about
+const selectAlert = `
+	SELECT a.id, a.userId, a.sourceType, a.sourceId, COALESCE(s.title, d.title, ''),
+		a.kind, a.severity, a.message, a.value, a.status,
+		a.openedAt, a.acknowledgedAt, a.acknowledgedBy, a.resolvedAt,
+		a.escalationLevel, a.escalatedAt
+	FROM alerts a
+	LEFT JOIN sensors s ON a.sourceType = 'sensor' AND s.feedId = a.sourceId
+	LEFT JOIN devices d ON a.sourceType = 'device' AND d.feedId = a.sourceId
+`
+
+func (s *Store) GetAlertByID(id int) (*types.Alert, error) {
+	rows, err := s.db.Query(selectAlert+"WHERE a.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("alert %d not found", id)
+	}
+	return scanRowIntoAlert(rows)
+}
+
+// GetAlertsByUserID lists the alerts in the statuses of the user and of the
+// homes the user is a member of, newest first.
+func (s *Store) GetAlertsByUserID(userId int, statuses []string) ([]types.Alert, error) {
+	args := []any{userId, userId}
+	for _, st := range statuses {
+		args = append(args, st)
+	}
+	args = append(args, listLimit)
+
+	query := selectAlert + `
+		WHERE (a.userId = ? OR a.userId IN (
+			SELECT ownerId FROM household_members WHERE memberId = ? AND acceptedAt IS NOT NULL
+		)) AND a.status IN (` + strings.TrimSuffix(strings.Repeat("?,", len(statuses)), ",") + `)
+		ORDER BY a.openedAt DESC, a.id DESC
+		LIMIT ?
+	`
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []types.Alert{}
+	for rows.Next() {
+		a, err := scanRowIntoAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, *a)
+	}
+	return alerts, rows.Err()
+}
+
+// GetActiveAlert returns the unresolved alert of the kind on the source, or
+// nil when there is none.
+func (s *Store) GetActiveAlert(sourceType string, sourceId int, kind string) (*types.Alert, error) {
+	rows, err := s.db.Query(selectAlert+`
+		WHERE a.sourceType = ? AND a.sourceId = ? AND a.kind = ? AND a.status <> 'resolved'
+		ORDER BY a.openedAt DESC
+		LIMIT 1
+	`, sourceType, sourceId, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanRowIntoAlert(rows)
+}
+
+func (s *Store) AcknowledgeAlert(id int, userId int) error {
+	_, err := s.db.Exec(`
+		UPDATE alerts
+		SET status = 'acknowledged', acknowledgedAt = CURRENT_TIMESTAMP, acknowledgedBy = ?
+		WHERE id = ? AND status = 'open'
+	`, userId, id)
+	return err
+}
+
+func (s *Store) ResolveAlert(id int) error {
+	_, err := s.db.Exec(`
+		UPDATE alerts
+		SET status = 'resolved', resolvedAt = CURRENT_TIMESTAMP
+		WHERE id = ? AND status <> 'resolved'
+	`, id)
+	return err
+}
+
+// ResolveActiveAlerts resolves the unresolved alerts of the kind on the
+// source and returns how many there were.
+func (s *Store) ResolveActiveAlerts(sourceType string, sourceId int, kind string) (int64, error) {
+	res, err := s.db.Exec(`
+		UPDATE alerts
+		SET status = 'resolved', resolvedAt = CURRENT_TIMESTAMP
+		WHERE sourceType = ? AND sourceId = ? AND kind = ? AND status <> 'resolved'
+	`, sourceType, sourceId, kind)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetUnacknowledgedAlerts lists the open alerts of every user, oldest first.
+func (s *Store) GetUnacknowledgedAlerts() ([]types.Alert, error) {
+	rows, err := s.db.Query(selectAlert + "WHERE a.status = 'open' ORDER BY a.openedAt, a.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []types.Alert{}
+	for rows.Next() {
+		a, err := scanRowIntoAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, *a)
+	}
+	return alerts, rows.Err()
+}
+
+func (s *Store) SetEscalationLevel(id int, level int) error {
+	_, err := s.db.Exec(`
+		UPDATE alerts
+		SET escalationLevel = ?, escalatedAt = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, level, id)
+	return err
+}
+
+func scanRowIntoAlert(rows *sql.Rows) (*types.Alert, error) {
+	a := new(types.Alert)
+
+	err := rows.Scan(
+		&a.ID,
+		&a.UserID,
+		&a.SourceType,
+		&a.SourceID,
+		&a.SourceTitle,
+		&a.Kind,
+		&a.Severity,
+		&a.Message,
+		&a.Value,
+		&a.Status,
+		&a.OpenedAt,
+		&a.AcknowledgedAt,
+		&a.AcknowledgedBy,
+		&a.ResolvedAt,
+		&a.EscalationLevel,
+		&a.EscalatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
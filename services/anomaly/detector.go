@@ -0,0 +1,210 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+const (
+	// history the baseline is trained on, and how often it is retrained
+	trainWindow  = 14 * 24 * time.Hour
+	retrainEvery = 24 * time.Hour
+
+	// readings needed before an hour of the day, or the sensor as a whole,
+	// is trusted as a baseline
+	minHourSamples = 8
+	minSamples     = 30
+
+	// a sensor raises at most one anomaly per cooldown
+	cooldown = 30 * time.Minute
+)
+
+// Sensitivities maps the sensitivity setting of a sensor to the z-score a
+// reading has to exceed to be unusual. "off" disables the detection.
+var Sensitivities = map[string]float64{
+	"off":    0,
+	"low":    4,
+	"medium": 3,
+	"high":   2.5,
+}
+
+// Anomaly describes an unusual reading.
+type Anomaly struct {
+	Value    float64
+	Expected float64
+	StdDev   float64
+	Z        float64
+	// the sensor is still unusual within the cooldown of the last anomaly
+	Repeat bool
+}
+
+// Detector keeps a seasonal baseline per sensor: the mean and spread of its
+// readings for every hour of the day, trained on logs_sensor history and
+// updated with every normal reading. Readings that lie too many standard
+// deviations away from the baseline of their hour are unusual, which
+// catches spikes and drifts that are still inside a plan's bounds.
+type Detector struct {
+	logStore types.LogSensorStore
+
+	mu     sync.Mutex
+	models map[int]*model
+}
+
+func NewDetector(logStore types.LogSensorStore) *Detector {
+	return &Detector{
+		logStore: logStore,
+		models:   map[int]*model{},
+	}
+}
+
+// Check scores a reading of the sensor and returns the anomaly it is, or nil
+// for a normal reading. Normal readings are folded into the baseline, and
+// recovered tells the one ending an anomaly. An unusual reading within the
+// cooldown is returned as a repeat.
+func (d *Detector) Check(sensor types.Sensor, value float64, at time.Time) (a *Anomaly, recovered bool, err error) {
+	threshold, ok := Sensitivities[sensor.AnomalySensitivity]
+	if !ok {
+		threshold = Sensitivities["medium"]
+	}
+	if threshold == 0 {
+		// the anomaly the sensor was in, if any, ends with the detection
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		m := d.models[sensor.FeedId]
+		delete(d.models, sensor.FeedId)
+		return nil, m != nil && m.unusual, nil
+	}
+
+	d.mu.Lock()
+	m := d.models[sensor.FeedId]
+	d.mu.Unlock()
+
+	// trained without the lock, so the query doesn't hold up the readings of
+	// the other sensors
+	if m == nil || m.stale(at) {
+		trained, err := d.train(sensor.FeedId, at)
+		if err != nil {
+			return nil, false, err
+		}
+
+		d.mu.Lock()
+		// another reading may have retrained it meanwhile
+		m = d.models[sensor.FeedId]
+		if m == nil || m.stale(at) {
+			if m != nil {
+				trained.lastAlert = m.lastAlert
+				trained.unusual = m.unusual
+			}
+			m = trained
+			d.models[sensor.FeedId] = m
+		}
+		d.mu.Unlock()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	a = m.score(value, at, threshold)
+	if a == nil {
+		m.observe(value, at)
+		recovered, m.unusual = m.unusual, false
+		return nil, recovered, nil
+	}
+
+	m.unusual = true
+	if at.Sub(m.lastAlert) < cooldown {
+		a.Repeat = true
+		return a, false, nil
+	}
+	m.lastAlert = at
+	return a, false, nil
+}
+
+func (d *Detector) train(feedId int, now time.Time) (*model, error) {
+	logs, err := d.logStore.GetSensorsByFeedIDBetween(feedId, now.Add(-trainWindow), now)
+	if err != nil {
+		return nil, fmt.Errorf("train anomaly baseline: %v", err)
+	}
+
+	// the sensor may have been left unusual before a restart, so the first
+	// normal reading ends it
+	m := &model{trainedAt: now, unusual: true}
+	for _, l := range logs {
+		v, err := strconv.ParseFloat(l.Value, 64)
+		if err != nil {
+			continue
+		}
+		m.observe(v, l.CreatedAt)
+	}
+	return m, nil
+}
+
+// running mean and variance (Welford)
+type stats struct {
+	n    float64
+	mean float64
+	m2   float64
+}
+
+func (s *stats) add(v float64) {
+	s.n++
+	delta := v - s.mean
+	s.mean += delta / s.n
+	s.m2 += delta * (v - s.mean)
+}
+
+func (s *stats) stddev() float64 {
+	if s.n < 2 {
+		return 0
+	}
+	return math.Sqrt(s.m2 / (s.n - 1))
+}
+
+type model struct {
+	hours     [24]stats // by UTC hour, the daily cycle is the same in any zone
+	all       stats
+	trainedAt time.Time
+	lastAlert time.Time
+	// the last reading was an anomaly
+	unusual bool
+}
+
+func (m *model) stale(now time.Time) bool {
+	return now.Sub(m.trainedAt) > retrainEvery
+}
+
+func (m *model) observe(v float64, at time.Time) {
+	m.hours[at.UTC().Hour()].add(v)
+	m.all.add(v)
+}
+
+// score returns the anomaly the reading is at the given z-score threshold,
+// or nil when it is normal or there is too little history to tell.
+func (m *model) score(v float64, at time.Time, threshold float64) *Anomaly {
+	baseline := &m.hours[at.UTC().Hour()]
+	if baseline.n < minHourSamples {
+		baseline = &m.all
+	}
+	if baseline.n < minSamples && baseline == &m.all {
+		return nil
+	}
+
+	// a sensor that barely moves would make every small change unusual
+	std := math.Max(baseline.stddev(), math.Max(0.05*math.Abs(baseline.mean), 0.5))
+
+	z := math.Abs(v-baseline.mean) / std
+	if z < threshold {
+		return nil
+	}
+	return &Anomaly{
+		Value:    v,
+		Expected: baseline.mean,
+		StdDev:   std,
+		Z:        z,
+	}
+}
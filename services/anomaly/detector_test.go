@@ -0,0 +1,109 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// history returns a reading every 15 minutes for two weeks that follows a
+// daily cycle between 24 and 32 degrees.
+type history struct {
+	types.LogSensorStore
+	end time.Time
+}
+
+func (h history) GetSensorsByFeedIDBetween(feedId int, start time.Time, end time.Time) ([]types.LogSensor, error) {
+	logs := []types.LogSensor{}
+	for t := h.end.Add(-trainWindow); t.Before(h.end); t = t.Add(15 * time.Minute) {
+		logs = append(logs, types.LogSensor{SensorID: feedId, Value: fmt.Sprint(cycle(t)), CreatedAt: t})
+	}
+	return logs, nil
+}
+
+func cycle(t time.Time) float64 {
+	hour := float64(t.UTC().Hour()) + float64(t.UTC().Minute())/60
+	return 28 + 4*math.Sin(hour/24*2*math.Pi)
+}
+
+func TestCheckUsesSeasonalBaseline(t *testing.T) {
+	now := time.Date(2025, 6, 1, 6, 0, 0, 0, time.UTC) // the top of the cycle
+	d := NewDetector(history{end: now})
+	sensor := types.Sensor{FeedId: 1, AnomalySensitivity: "medium"}
+
+	if a, _, err := d.Check(sensor, cycle(now), now); err != nil || a != nil {
+		t.Fatalf("expected the usual reading to pass, got %+v, %v", a, err)
+	}
+
+	// 24 degrees is normal at night but not at the top of the cycle
+	a, _, err := d.Check(sensor, 24, now.Add(time.Minute))
+	if err != nil || a == nil {
+		t.Fatalf("expected 24 to be unusual at 06:00, got %+v, %v", a, err)
+	}
+	if math.Abs(a.Expected-32) > 0.5 {
+		t.Errorf("expected a baseline near 32, got %v", a.Expected)
+	}
+
+	// the same spike again is within the cooldown
+	if a, _, _ := d.Check(sensor, 24, now.Add(2*time.Minute)); a == nil || !a.Repeat {
+		t.Errorf("expected a repeat within the cooldown, got %+v", a)
+	}
+	if a, _, _ := d.Check(sensor, 24, now.Add(cooldown+2*time.Minute)); a == nil || a.Repeat {
+		t.Errorf("expected the anomaly to be raised again after the cooldown")
+	}
+}
+
+func TestCheckReportsRecovery(t *testing.T) {
+	now := time.Date(2025, 6, 1, 6, 0, 0, 0, time.UTC)
+	d := NewDetector(history{end: now})
+	sensor := types.Sensor{FeedId: 1, AnomalySensitivity: "medium"}
+
+	// a fresh baseline doesn't know how the sensor was before, its first
+	// normal reading ends any anomaly left over
+	if _, recovered, _ := d.Check(sensor, cycle(now), now); !recovered {
+		t.Errorf("expected the first normal reading to end a left over anomaly")
+	}
+	if _, recovered, _ := d.Check(sensor, cycle(now), now.Add(time.Minute)); recovered {
+		t.Errorf("expected a normal reading after a normal one not to be a recovery")
+	}
+
+	d.Check(sensor, 24, now.Add(2*time.Minute))
+	if _, recovered, _ := d.Check(sensor, cycle(now), now.Add(3*time.Minute)); !recovered {
+		t.Errorf("expected the normal reading after the anomaly to be a recovery")
+	}
+}
+
+func TestCheckSensitivity(t *testing.T) {
+	now := time.Date(2025, 6, 1, 6, 0, 0, 0, time.UTC)
+
+	// 5 degrees off the usual 32 is a z-score of about 3
+	for sensitivity, unusual := range map[string]bool{"off": false, "low": false, "medium": true, "high": true} {
+		d := NewDetector(history{end: now})
+		a, _, _ := d.Check(types.Sensor{FeedId: 1, AnomalySensitivity: sensitivity}, 27, now)
+		if (a != nil) != unusual {
+			t.Errorf("%s: expected unusual to be %v, got %+v", sensitivity, unusual, a)
+		}
+	}
+
+	d := NewDetector(history{end: now})
+	if a, _, _ := d.Check(types.Sensor{FeedId: 1, AnomalySensitivity: "off"}, -100, now); a != nil {
+		t.Errorf("expected detection to be off, got %+v", a)
+	}
+}
+
+func TestScoreNeedsHistory(t *testing.T) {
+	m := &model{}
+	now := time.Now()
+	for i := 0; i < minSamples-1; i++ {
+		m.observe(20, now)
+	}
+	if m.hours[now.UTC().Hour()].n < minHourSamples {
+		t.Fatalf("expected the hour to have enough samples")
+	}
+	if a := m.score(100, now.Add(time.Hour), 3); a != nil {
+		t.Errorf("expected no verdict for an hour without history, got %+v", a)
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/services/notification"
+	"github.com/quanghia24/mySmartHome/services/webhook"
 	"github.com/quanghia24/mySmartHome/types"
 	"github.com/quanghia24/mySmartHome/utils"
 )
@@ -15,13 +18,17 @@ type Handler struct {
 	store        types.OrderStore
 	productStore types.ProductStore
 	userStore    types.UserStore
+	webhooks     *webhook.Sender
+	dispatcher   *notification.Dispatcher
 }
 
-func NewHandler(store types.OrderStore, productStore types.ProductStore, userStore types.UserStore) *Handler {
+func NewHandler(store types.OrderStore, productStore types.ProductStore, userStore types.UserStore, webhooks *webhook.Sender, dispatcher *notification.Dispatcher) *Handler {
 	return &Handler{
 		store:        store,
 		productStore: productStore,
 		userStore:    userStore,
+		webhooks:     webhooks,
+		dispatcher:   dispatcher,
 	}
 }
 
@@ -65,6 +72,21 @@ func (h *Handler) handleCheckout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// an order out of stock isn't created
+	if orderID != 0 {
+		h.dispatcher.Notify(userID, notification.Message{
+			TitleKey: "noti.order.title",
+			BodyKey:  "noti.order.body",
+			Params:   i18n.Params{"orderId": fmt.Sprint(orderID), "total": fmt.Sprint(totalPrice)},
+			Event:    notification.EventOrder,
+		})
+		h.webhooks.Emit(userID, webhook.EventOrderStatus, map[string]any{
+			"orderId": orderID,
+			"status":  "pending",
+			"total":   totalPrice,
+		})
+	}
+
 	utils.WriteJSON(w, http.StatusOK, map[string]any{
 		"total_price": totalPrice,
 		"order_id":    orderID,
@@ -0,0 +1,144 @@
+package comfort
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+func TestMetrics(t *testing.T) {
+	cases := []struct {
+		name     string
+		fn       func(float64, float64) float64
+		temp, rh float64
+		expected float64
+	}{
+		// 90°F at 70% reads 106°F on the weather service chart
+		{"heat index hot", HeatIndex, 32.2, 70, 41.1},
+		{"heat index mild", HeatIndex, 20, 50, 19.4},
+		{"dew point", DewPoint, 20, 50, 9.3},
+		{"dew point saturated", DewPoint, 25, 100, 25},
+		{"absolute humidity", AbsoluteHumidity, 20, 50, 8.6},
+	}
+	for _, c := range cases {
+		if got := c.fn(c.temp, c.rh); math.Abs(got-c.expected) > 0.3 {
+			t.Errorf("%s: expected %.1f, got %.2f", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	if s := Score(23, 50); s != 100 {
+		t.Errorf("expected 23°C at 50%% to be fully comfortable, got %v", s)
+	}
+	if a, b := Score(29, 50), Score(33, 80); !(b < a && a < 100) {
+		t.Errorf("expected the score to drop as it gets hot and humid, got %v and %v", a, b)
+	}
+	if s := Score(45, 95); s != 0 {
+		t.Errorf("expected the score to bottom out at 0, got %v", s)
+	}
+}
+
+type latest struct {
+	types.LogSensorStore
+	readings map[int]types.LogSensor
+}
+
+func (l latest) GetLatestSensorData(feedId int) (*types.LogSensor, error) {
+	r, ok := l.readings[feedId]
+	if !ok {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+func TestDerive(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	sensors := []types.Sensor{
+		{FeedId: 1, Type: "temperature", RoomID: 3, UserID: 7},
+		{FeedId: 2, Type: "humidity", RoomID: 3, UserID: 7},
+		{FeedId: 3, Type: "brightness", RoomID: 3, UserID: 7},
+	}
+	store := latest{readings: map[int]types.LogSensor{
+		1: {SensorID: 1, Value: "20", CreatedAt: now.Add(-10 * time.Minute)},
+		2: {SensorID: 2, Value: "50", CreatedAt: now.Add(-2 * time.Hour)},
+	}}
+
+	if m, err := Derive(sensors, store, nil, now); err != nil || m != nil {
+		t.Fatalf("expected no metrics from a stale humidity reading, got %+v, %v", m, err)
+	}
+
+	fresh := &types.LogSensor{SensorID: 2, Value: "50", CreatedAt: now}
+	m, err := Derive(sensors, store, fresh, now)
+	if err != nil || m == nil {
+		t.Fatalf("expected metrics with a fresh reading, got %+v, %v", m, err)
+	}
+	if math.Abs(m.DewPoint-9.3) > 0.3 {
+		t.Errorf("expected a dew point near 9.3, got %v", m.DewPoint)
+	}
+
+	if m, _ := Derive(sensors[:1], store, nil, now); m != nil {
+		t.Errorf("expected no metrics without a humidity sensor")
+	}
+}
+
+func TestMissing(t *testing.T) {
+	sensors := []types.Sensor{
+		{FeedId: 1, Type: "temperature", RoomID: 3, UserID: 7},
+	}
+	if m := Missing(sensors); len(m) != 0 {
+		t.Fatalf("expected nothing to add without a pair, got %+v", m)
+	}
+
+	sensors = append(sensors, types.Sensor{FeedId: 2, Type: "humidity", RoomID: 3, UserID: 7})
+	sensors = append(sensors, types.Sensor{FeedId: FeedID(3, ScoreType), Type: ScoreType, RoomID: 3, UserID: 7, Virtual: true})
+
+	missing := Missing(sensors)
+	if len(missing) != len(Types)-1 {
+		t.Fatalf("expected %d sensors to add, got %+v", len(Types)-1, missing)
+	}
+	seen := map[int]bool{}
+	for _, s := range missing {
+		if !s.Virtual || s.UserID != 7 || s.RoomID != 3 || !IsVirtualFeedID(s.FeedId) || seen[s.FeedId] {
+			t.Errorf("unexpected virtual sensor %+v", s)
+		}
+		seen[s.FeedId] = true
+	}
+	if FeedID(3, HeatIndexType) == FeedID(4, HeatIndexType) {
+		t.Errorf("expected rooms to get distinct feed ids")
+	}
+}
+
+func TestOrphaned(t *testing.T) {
+	score := types.Sensor{FeedId: FeedID(3, ScoreType), Type: ScoreType, RoomID: 3, Virtual: true}
+	sensors := []types.Sensor{
+		{FeedId: 1, Type: "temperature", RoomID: 3},
+		{FeedId: 2, Type: "humidity", RoomID: 3},
+		score,
+	}
+	if o := Orphaned(sensors); len(o) != 0 {
+		t.Fatalf("expected a paired room to keep its sensors, got %+v", o)
+	}
+
+	// the humidity sensor moved to another room
+	if o := Orphaned([]types.Sensor{sensors[0], score}); len(o) != 1 || o[0].FeedId != score.FeedId {
+		t.Errorf("expected the virtual sensor to be orphaned, got %+v", o)
+	}
+}
+
+func TestTypesAreRegistered(t *testing.T) {
+	for _, name := range Types {
+		mtype, ok := devicetype.Lookup(name)
+		if !ok || !mtype.Virtual || !devicetype.IsSensor(name) {
+			t.Errorf("expected %s registered as a virtual sensor, got %+v", name, mtype)
+		}
+	}
+
+	score, _ := devicetype.Lookup(ScoreType)
+	if lowest, highest, ok := score.Schema.Bounds(); !ok || lowest != 0 || highest != 100 {
+		t.Errorf("expected the comfort score between 0 and 100, got %v %v", lowest, highest)
+	}
+}
@@ -0,0 +1,99 @@
+package comfort
+
+import "math"
+
+// Metrics are derived from a temperature in °C and a relative humidity in %.
+type Metrics struct {
+	HeatIndex        float64 // °C
+	DewPoint         float64 // °C
+	AbsoluteHumidity float64 // g/m³
+	Score            float64 // 0 to 100, higher is more comfortable
+}
+
+// Compute derives all metrics from one temperature and humidity reading.
+func Compute(temp, humidity float64) Metrics {
+	humidity = math.Min(math.Max(humidity, 0), 100)
+	return Metrics{
+		HeatIndex:        HeatIndex(temp, humidity),
+		DewPoint:         DewPoint(temp, humidity),
+		AbsoluteHumidity: AbsoluteHumidity(temp, humidity),
+		Score:            Score(temp, humidity),
+	}
+}
+
+// Values returns the metrics keyed by their sensor type.
+func (m Metrics) Values() map[string]float64 {
+	return map[string]float64{
+		HeatIndexType:        m.HeatIndex,
+		DewPointType:         m.DewPoint,
+		AbsoluteHumidityType: m.AbsoluteHumidity,
+		ScoreType:            m.Score,
+	}
+}
+
+// HeatIndex is the temperature it feels like, using the regression of the
+// US National Weather Service.
+func HeatIndex(temp, humidity float64) float64 {
+	t := temp*9/5 + 32
+	rh := humidity
+
+	// the simple formula is good enough below 80°F
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + rh*0.094)
+	if (hi+t)/2 >= 80 {
+		hi = -42.379 + 2.04901523*t + 10.14333127*rh -
+			0.22475541*t*rh - 0.00683783*t*t - 0.05481717*rh*rh +
+			0.00122874*t*t*rh + 0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+
+		if rh < 13 && t >= 80 && t <= 112 {
+			hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+		} else if rh > 85 && t >= 80 && t <= 87 {
+			hi += (rh - 85) / 10 * (87 - t) / 5
+		}
+	}
+	return (hi - 32) * 5 / 9
+}
+
+// DewPoint is the temperature the air has to cool to for water to condense,
+// using the Magnus formula.
+func DewPoint(temp, humidity float64) float64 {
+	const b, c = 17.62, 243.12
+	// the logarithm is undefined for perfectly dry air
+	gamma := math.Log(math.Max(humidity, 0.1)/100) + b*temp/(c+temp)
+	return c * gamma / (b - gamma)
+}
+
+// AbsoluteHumidity is the mass of water vapour in a cubic meter of air.
+func AbsoluteHumidity(temp, humidity float64) float64 {
+	return 6.112 * math.Exp(17.67*temp/(temp+243.5)) * humidity * 2.1674 / (273.15 + temp)
+}
+
+// ranges considered comfortable indoors
+const (
+	minComfortTemp     = 20.0
+	maxComfortTemp     = 26.0
+	minComfortHumidity = 40.0
+	maxComfortHumidity = 60.0
+)
+
+// Score rates how comfortable the air is from 0 to 100. It drops by 8 for
+// every degree the felt temperature is outside 20-26°C and by 1.5 for every
+// percent of humidity outside 40-60%.
+func Score(temp, humidity float64) float64 {
+	felt := HeatIndex(temp, humidity)
+
+	score := 100.0
+	score -= 8 * outside(felt, minComfortTemp, maxComfortTemp)
+	score -= 1.5 * outside(humidity, minComfortHumidity, maxComfortHumidity)
+	return math.Max(score, 0)
+}
+
+// outside returns how far v is from the range [lo, hi].
+func outside(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo - v
+	}
+	if v > hi {
+		return v - hi
+	}
+	return 0
+}
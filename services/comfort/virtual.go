@@ -0,0 +1,177 @@
+package comfort
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// types of the virtual sensors that carry the metrics of a room
+const (
+	HeatIndexType        = "heat_index"
+	DewPointType         = "dew_point"
+	AbsoluteHumidityType = "absolute_humidity"
+	ScoreType            = "comfort"
+)
+
+// Types lists the virtual sensor types in the order their feed ids are
+// numbered.
+var Types = []string{HeatIndexType, DewPointType, AbsoluteHumidityType, ScoreType}
+
+var titles = map[string]string{
+	HeatIndexType:        "Heat index",
+	DewPointType:         "Dew point",
+	AbsoluteHumidityType: "Absolute humidity",
+	ScoreType:            "Comfort",
+}
+
+func init() {
+	for _, t := range []devicetype.Type{
+		{Name: HeatIndexType, Schema: devicetype.Schema{Kind: devicetype.ValueNumber, Min: -40, Max: 100}, Unit: "°C"},
+		{Name: DewPointType, Schema: devicetype.Schema{Kind: devicetype.ValueNumber, Min: -60, Max: 80}, Unit: "°C"},
+		{Name: AbsoluteHumidityType, Schema: devicetype.Schema{Kind: devicetype.ValueNumber, Min: 0, Max: 300}, Unit: "g/m³"},
+		{Name: ScoreType, Schema: devicetype.Schema{Kind: devicetype.ValueNumber, Min: 0, Max: 100}},
+	} {
+		t.Kind = devicetype.Sensor
+		t.Virtual = true
+		devicetype.Register(t)
+	}
+}
+
+// Virtual sensors are not Adafruit feeds, their feed ids are numbered from
+// the top of the unsigned column so they never meet a real one.
+const virtualFeedBase = 4_000_000_000
+
+// the oldest reading of a pair that is still combined with the other
+const maxReadingAge = time.Hour
+
+// IsVirtualFeedID reports whether the feed id is in the range of virtual
+// sensors.
+func IsVirtualFeedID(feedId int) bool {
+	return feedId >= virtualFeedBase
+}
+
+// IsType reports whether the sensor type is one of the derived metrics.
+func IsType(mtype string) bool {
+	_, ok := titles[mtype]
+	return ok
+}
+
+// FeedID returns the feed id of the room's virtual sensor of the type.
+func FeedID(roomId int, mtype string) int {
+	for i, t := range Types {
+		if t == mtype {
+			return virtualFeedBase + roomId*len(Types) + i
+		}
+	}
+	return 0
+}
+
+// Pair returns the temperature and humidity sensors the metrics of a room
+// are derived from.
+func Pair(sensors []types.Sensor) (temp types.Sensor, humidity types.Sensor, ok bool) {
+	var hasTemp, hasHumidity bool
+	for _, s := range sensors {
+		if s.Virtual {
+			continue
+		}
+		if s.Type == "temperature" && !hasTemp {
+			temp, hasTemp = s, true
+		}
+		if s.Type == "humidity" && !hasHumidity {
+			humidity, hasHumidity = s, true
+		}
+	}
+	return temp, humidity, hasTemp && hasHumidity
+}
+
+// Missing returns the virtual sensors a room with paired sensors lacks.
+func Missing(sensors []types.Sensor) []types.Sensor {
+	temp, _, ok := Pair(sensors)
+	if !ok {
+		return nil
+	}
+
+	existing := map[string]bool{}
+	for _, s := range sensors {
+		if s.Virtual {
+			existing[s.Type] = true
+		}
+	}
+
+	missing := []types.Sensor{}
+	for _, t := range Types {
+		if existing[t] {
+			continue
+		}
+		missing = append(missing, types.Sensor{
+			FeedId:  FeedID(temp.RoomID, t),
+			FeedKey: fmt.Sprintf("room-%d-%s", temp.RoomID, strings.ReplaceAll(t, "_", "-")),
+			Title:   titles[t],
+			Type:    t,
+			UserID:  temp.UserID,
+			RoomID:  temp.RoomID,
+			Virtual: true,
+		})
+	}
+	return missing
+}
+
+// Orphaned returns the virtual sensors of a room that no longer has a pair
+// to derive them from.
+func Orphaned(sensors []types.Sensor) []types.Sensor {
+	if _, _, ok := Pair(sensors); ok {
+		return nil
+	}
+
+	orphaned := []types.Sensor{}
+	for _, s := range sensors {
+		if s.Virtual {
+			orphaned = append(orphaned, s)
+		}
+	}
+	return orphaned
+}
+
+// Derive computes the metrics of a room from the latest readings of its
+// paired sensors. A reading that just arrived and is not logged yet is passed
+// as current. It returns nil when the room has no pair or either reading is
+// too old to be combined.
+func Derive(sensors []types.Sensor, logStore types.LogSensorStore, current *types.LogSensor, now time.Time) (*Metrics, error) {
+	temp, humidity, ok := Pair(sensors)
+	if !ok {
+		return nil, nil
+	}
+
+	read := func(s types.Sensor) (float64, bool, error) {
+		reading := current
+		if reading == nil || reading.SensorID != s.FeedId {
+			var err error
+			reading, err = logStore.GetLatestSensorData(s.FeedId)
+			if err != nil || reading == nil {
+				return 0, false, err
+			}
+		}
+		if now.Sub(reading.CreatedAt) > maxReadingAge {
+			return 0, false, nil
+		}
+		v, err := strconv.ParseFloat(reading.Value, 64)
+		return v, err == nil, nil
+	}
+
+	t, ok, err := read(temp)
+	if err != nil || !ok {
+		return nil, err
+	}
+	h, ok, err := read(humidity)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	m := Compute(t, h)
+	return &m, nil
+}
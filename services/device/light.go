@@ -0,0 +1,159 @@
+package device
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/services/light"
+	"github.com/quanghia24/mySmartHome/types"
+	"github.com/quanghia24/mySmartHome/utils"
+)
+
+type lightState struct {
+	light.State
+	Value string `json:"value"`
+}
+
+type lightEffect struct {
+	Name         string       `json:"name"`
+	From         *light.State `json:"from,omitempty"`
+	To           light.State  `json:"to"`
+	TransitionMs int64        `json:"transitionMs"`
+}
+
+func (h *Handler) getLight(w http.ResponseWriter, r *http.Request) {
+	device, ok := h.ownedLight(w, r)
+	if !ok {
+		return
+	}
+
+	state, err := light.FromHex(device.Value)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, lightState{state, device.Value})
+}
+
+func (h *Handler) getLightEffects(w http.ResponseWriter, r *http.Request) {
+	effects := []lightEffect{}
+	for name, e := range light.Effects {
+		effects = append(effects, lightEffect{name, e.From, e.To, e.Transition.Milliseconds()})
+	}
+	sort.Slice(effects, func(i, j int) bool { return effects[i].Name < effects[j].Name })
+
+	utils.WriteJSON(w, http.StatusOK, effects)
+}
+
+// setLight fades a light to the state of the command, posting the steps of
+// the transition to its feed in the background.
+func (h *Handler) setLight(w http.ResponseWriter, r *http.Request) {
+	var payload types.LightCommandPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	device, ok := h.ownedLight(w, r)
+	if !ok {
+		return
+	}
+
+	// a value that isn't a color is left behind as off
+	from, _ := light.FromHex(device.Value)
+	to := from
+	var transition time.Duration
+	steps := []light.Step{}
+	if payload.Effect != "" {
+		effect, ok := light.Effects[payload.Effect]
+		if !ok {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown effect %s", payload.Effect))
+			return
+		}
+		if effect.From != nil {
+			from = *effect.From
+			steps = append(steps, light.Step{Value: from.Hex()})
+		}
+		to = effect.To
+		transition = effect.Transition
+	}
+	to = applyLightCommand(to, payload)
+	if payload.TransitionMs != nil {
+		transition = time.Duration(*payload.TransitionMs) * time.Millisecond
+	}
+
+	steps = append(steps, light.Transition(from, to, transition)...)
+	h.shadows.Transition(device.FeedID, device.FeedKey, steps)
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"state":        lightState{to, to.Hex()},
+		"transitionMs": transition.Milliseconds(),
+	})
+}
+
+// ownedLight returns the light of the request with its current value,
+// writing the error when it isn't one of the user's lights.
+func (h *Handler) ownedLight(w http.ResponseWriter, r *http.Request) (*types.DeviceDataPayload, bool) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	feedId, err := strconv.Atoi(mux.Vars(r)["feed_id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid feed id"))
+		return nil, false
+	}
+
+	owner, err := h.store.GetDevice(feedId)
+	if err != nil || owner.UserID != userId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("device %d not found", feedId))
+		return nil, false
+	}
+	// any type of the registry driven by a color feed
+	if t, ok := devicetype.Lookup(owner.Type); !ok || t.Schema.Kind != devicetype.ValueColor {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("device %d is not a light", feedId))
+		return nil, false
+	}
+
+	device, err := h.store.GetDevicesByFeedID(feedId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return device, true
+}
+
+// applyLightCommand changes the fields the command has of the state. Setting
+// the brightness or a color turns the light on, a color and a color
+// temperature replace each other.
+func applyLightCommand(s light.State, p types.LightCommandPayload) light.State {
+	if p.Brightness != nil {
+		s.On = true
+		s.Brightness = *p.Brightness
+	}
+	if p.Color != nil {
+		s.On = true
+		s.Color, s.ColorTemp = *p.Color, 0
+	}
+	if p.ColorTemp != nil {
+		s.On = true
+		s.Color, s.ColorTemp = "", *p.ColorTemp
+	}
+	if p.On != nil {
+		s.On = *p.On
+	}
+	if s.On && s.Brightness == 0 {
+		s.Brightness = 100
+	}
+	return s
+}
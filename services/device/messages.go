@@ -0,0 +1,40 @@
+package device
+
+import (
+	"strconv"
+
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// StateMessage is the template, and its params, of the log of a device
+// reporting a new value. A device with levels logs the level the value maps
+// back to, one made continuous out of a type with levels its speed.
+func StateMessage(deviceType string, levels []int, title string, value string) (string, i18n.Params) {
+	params := i18n.Params{"title": title, "value": value}
+	t, ok := devicetype.ForDevice(deviceType, levels)
+	if !ok {
+		return "", params
+	}
+	if level, ok := t.Level(value); ok {
+		params["value"] = strconv.Itoa(level)
+	} else if levels != nil && len(levels) == 0 {
+		return "log.device.speed", params
+	}
+	return t.MessageKey(value), params
+}
+
+// withLevel fills in the levels of a device's payload and the level its
+// value maps back to, for the app to show levels rather than raw values.
+func withLevel(d types.DeviceDataPayload) types.DeviceDataPayload {
+	t, ok := devicetype.ForDevice(d.Type, d.Levels)
+	if !ok || t.Kind != devicetype.Device {
+		return d
+	}
+	d.Levels = t.Levels
+	if level, ok := t.Level(d.Value); ok {
+		d.Level = &level
+	}
+	return d
+}
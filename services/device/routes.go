@@ -1,40 +1,49 @@
 package device
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strconv"
-	"time"
 
-	MQTT "github.com/eclipse/paho.mqtt.golang"
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/services/log_device"
+	"github.com/quanghia24/mySmartHome/services/notification"
+	"github.com/quanghia24/mySmartHome/services/shadow"
+	"github.com/quanghia24/mySmartHome/services/webhook"
 	"github.com/quanghia24/mySmartHome/types"
 	"github.com/quanghia24/mySmartHome/utils"
 )
 
 type Handler struct {
-	store      types.DeviceStore
-	userStore  types.UserStore
-	roomStore  types.RoomStore
-	logStore   types.LogDeviceStore
-	doorStore  types.DoorStore
-	mqttClient MQTT.Client
+	store       types.DeviceStore
+	sensorStore types.SensorStore
+	userStore   types.UserStore
+	roomStore   types.RoomStore
+	logStore    types.LogDeviceStore
+	doorStore   types.DoorStore
+	feeds       types.FeedSubscriptions
+	shadows     *shadow.Tracker
+	webhooks    *webhook.Sender
+	dispatcher  *notification.Dispatcher
 }
 
-func NewHandler(store types.DeviceStore, userStore types.UserStore, roomStore types.RoomStore, logStore types.LogDeviceStore, doorStore types.DoorStore, mqttClient MQTT.Client) *Handler {
+func NewHandler(store types.DeviceStore, sensorStore types.SensorStore, userStore types.UserStore, roomStore types.RoomStore, logStore types.LogDeviceStore, doorStore types.DoorStore, feeds types.FeedSubscriptions, shadows *shadow.Tracker, webhooks *webhook.Sender, dispatcher *notification.Dispatcher) *Handler {
 	return &Handler{
-		store:      store,
-		userStore:  userStore,
-		roomStore:  roomStore,
-		logStore:   logStore,
-		doorStore:  doorStore,
-		mqttClient: mqttClient,
+		store:       store,
+		sensorStore: sensorStore,
+		userStore:   userStore,
+		roomStore:   roomStore,
+		logStore:    logStore,
+		doorStore:   doorStore,
+		feeds:       feeds,
+		shadows:     shadows,
+		webhooks:    webhooks,
+		dispatcher:  dispatcher,
 	}
 }
 
@@ -44,6 +53,9 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/devices/{feed_id}/logs", h.getDeviceData).Methods(http.MethodGet)
 	router.HandleFunc("/devices/{feed_id}", h.getDeviceInfo).Methods(http.MethodGet)
 	router.HandleFunc("/devices/room/{roomID}", h.getAllDeviceInRoom).Methods(http.MethodGet)
+	router.HandleFunc("/device-types", h.getDeviceTypes).Methods(http.MethodGet)
+	router.HandleFunc("/devices/{feed_id}/light", auth.WithJWTAuth(h.getLight, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/light-effects", h.getLightEffects).Methods(http.MethodGet)
 	// post
 	router.HandleFunc("/devices", auth.WithJWTAuth(h.createDevice, h.userStore)).Methods(http.MethodPost)
 	router.HandleFunc("/devices/{feed_id}", auth.WithJWTAuth(h.addDeviceData, h.userStore)).Methods(http.MethodPost)
@@ -51,6 +63,10 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/devices/{feed_id}/getpwd", h.getPassword).Methods(http.MethodGet)
 	router.HandleFunc("/devices/{feed_id}/checkpwd", h.checkPassword).Methods(http.MethodPost)
 
+	// put
+	router.HandleFunc("/devices/{feed_id}", auth.WithJWTAuth(h.updateDevice, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/devices/{feed_id}/light", auth.WithJWTAuth(h.setLight, h.userStore)).Methods(http.MethodPut)
+
 	// delete
 	router.HandleFunc("/devices/{feed_id}", auth.WithJWTAuth(h.deleteDevice, h.userStore)).Methods(http.MethodDelete)
 
@@ -61,16 +77,132 @@ func (h *Handler) deleteDevice(w http.ResponseWriter, r *http.Request) {
 	deviceId := params["feed_id"]
 	userId := auth.GetUserIDFromContext(r.Context())
 
-	err := h.store.DeleteDevice(deviceId, userId)
+	feedId, err := strconv.Atoi(deviceId)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid feed id"))
+		return
+	}
+	device, err := h.store.GetDevice(feedId)
+	if err != nil || device.UserID != userId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("device %v not found", deviceId))
+		return
+	}
+
+	err = h.store.DeleteDevice(deviceId, userId)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	if err := h.feeds.Unsubscribe(device.FeedKey); err != nil {
+		log.Println("mqtt unsubscribe:", err)
+	}
+
 	utils.WriteJSON(w, http.StatusOK, fmt.Sprintf("Device %v has been deleted", deviceId))
 
 }
 
+// updateDevice renames the device, moves it to another room, changes its
+// feed key or its type. Its history stays attached to its feedId, and the
+// MQTT subscription follows a new feed key.
+func (h *Handler) updateDevice(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	feedId, err := strconv.Atoi(mux.Vars(r)["feed_id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid feed id"))
+		return
+	}
+
+	var payload types.UpdateDevicePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	device, err := h.store.GetDevice(feedId)
+	if err != nil || device.UserID != userId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("device %d not found", feedId))
+		return
+	}
+	updated := *device
+
+	if payload.Title != nil {
+		updated.Title = *payload.Title
+	}
+	if payload.Type != nil {
+		if !devicetype.IsDevice(*payload.Type) {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown device type %s", *payload.Type))
+			return
+		}
+		updated.Type = *payload.Type
+	}
+	if payload.RoomID != nil {
+		room, err := h.roomStore.GetRoomByID(*payload.RoomID)
+		if err != nil || room.UserID != userId {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("room %d not found", *payload.RoomID))
+			return
+		}
+		updated.RoomID = room.ID
+	}
+	// a device of a new type starts from the type's levels
+	if updated.Type != device.Type {
+		updated.Levels = nil
+	}
+	if payload.Levels != nil {
+		updated.Levels = *payload.Levels
+	}
+	t, _ := devicetype.Lookup(updated.Type)
+	if err := t.ValidateLevels(updated.Levels); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if payload.FeedKey != nil && *payload.FeedKey != device.FeedKey {
+		if other, err := h.store.GetDeviceByFeedKey(*payload.FeedKey); err == nil && other.FeedId != feedId {
+			utils.WriteError(w, http.StatusConflict, fmt.Errorf("feed %s is used by device %d", *payload.FeedKey, other.FeedId))
+			return
+		}
+		// a feed's messages go to the device or the sensor it belongs to,
+		// never both
+		if other, err := h.sensorStore.GetSensorByFeedKey(*payload.FeedKey); err == nil {
+			utils.WriteError(w, http.StatusConflict, fmt.Errorf("feed %s is used by sensor %d", *payload.FeedKey, other.FeedId))
+			return
+		}
+		updated.FeedKey = *payload.FeedKey
+	}
+
+	if err := h.store.UpdateDevice(updated); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// a device turned into a lock gets its password like a new one
+	if t.Lock && updated.Type != device.Type {
+		if _, err := h.doorStore.GetPassword(feedId); err != nil {
+			if err := h.doorStore.CreatePassword(types.DoorPassword{FeedID: feedId, PWD: ""}); err != nil {
+				utils.WriteError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+	}
+
+	if updated.FeedKey != device.FeedKey {
+		if err := h.feeds.Unsubscribe(device.FeedKey); err != nil {
+			log.Println("mqtt unsubscribe:", err)
+		}
+		if err := h.feeds.Subscribe(updated.FeedKey); err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("mqtt subscribe error: %v", err))
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, updated)
+}
+
 func (h *Handler) setPassword(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	feedId, _ := strconv.Atoi(params["feed_id"])
@@ -125,6 +257,7 @@ func (h *Handler) checkPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if pwd.PWD == "" {
+		h.unlocked(feedId)
 		utils.WriteJSON(w, http.StatusOK, "door unlocked")
 		return
 	}
@@ -139,6 +272,7 @@ func (h *Handler) checkPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if payload.PWD == pwd.PWD {
+		h.unlocked(feedId)
 		utils.WriteJSON(w, http.StatusOK, "door unlocked")
 		return
 	}
@@ -146,6 +280,25 @@ func (h *Handler) checkPassword(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSON(w, http.StatusUnauthorized, "wrong password")
 }
 
+// unlocked tells the door's owner and their webhooks it got unlocked.
+func (h *Handler) unlocked(feedId int) {
+	d, err := h.store.GetDevice(feedId)
+	if err != nil {
+		log.Println("door unlock notification:", err)
+		return
+	}
+	h.dispatcher.Notify(d.UserID, notification.Message{
+		TitleKey: "noti.door.title",
+		BodyKey:  "noti.door.body",
+		Params:   i18n.Params{"title": d.Title},
+		Event:    notification.EventDoor,
+	})
+	h.webhooks.Emit(d.UserID, webhook.EventDoorUnlock, map[string]any{
+		"feedId": d.FeedId,
+		"title":  d.Title,
+	})
+}
+
 func (h *Handler) addDeviceData(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	feedId, err := strconv.Atoi(params["feed_id"])
@@ -160,27 +313,23 @@ func (h *Handler) addDeviceData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	url := os.Getenv("AIOAPI") + device.FeedKey + "/data"
-	log.Println("adding data to", url)
-
-
 	var payload types.DeviceDataPayload
 	if err := utils.ParseJSON(r, &payload); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	if device.Type == "fan" {
-		if payload.Value == "1" {
-			payload.Value = "50"
-		} else if payload.Value == "2" {
-			payload.Value = "75"
-		} else if payload.Value == "3" {
-			payload.Value = "100"
-		} else {
-			payload.Value = "0"
-		}
-	} else if device.Type == "door" {
+	t, ok := devicetype.ForDevice(device.Type, device.Levels)
+	if !ok {
+		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("unknown device type %s", device.Type))
+		return
+	}
+	payload.Value, err = t.ToFeed(payload.Value)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if t.Lock {
 		err := h.doorStore.CreatePassword(types.DoorPassword{
 			FeedID: feedId,
 			PWD:    "",
@@ -191,51 +340,22 @@ func (h *Handler) addDeviceData(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	location, err := time.LoadLocation("Asia/Ho_Chi_Minh")
-	if err != nil {
-		log.Fatalf("failed to load location: %v", err)
-	}
-	payload.CreatedAt = time.Now().In(location)
-
 	if err := utils.Validate.Struct(payload); err != nil {
 		errors := err.(validator.ValidationErrors)
 		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
 		return
 	}
 
-	// send request to adafruit server
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	apiKey := os.Getenv("AIOKey")
-	if apiKey == "" {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("missing AIO Key"))
-		return
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-AIO-Key", apiKey)
-
-	// make the request
-	client := &http.Client{}
-	_, err = client.Do(req)
+	// the device still has its value until it reports the new one, the
+	// shadow tells whether it did
+	shadow, err := h.shadows.Send(feedId, device.FeedKey, payload.Value)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, http.StatusBadGateway, err)
 		return
 	}
+	device.Shadow = &shadow
 
-	device.Value = payload.Value
-
-	utils.WriteJSON(w, http.StatusOK, device)
+	utils.WriteJSON(w, http.StatusAccepted, withLevel(*device))
 }
 
 func (h *Handler) getDeviceData(w http.ResponseWriter, r *http.Request) {
@@ -252,7 +372,7 @@ func (h *Handler) getDeviceData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, logs)
+	utils.WriteJSON(w, http.StatusOK, log_device.Localize(logs, i18n.FromRequest(r, "")))
 }
 
 func (h *Handler) getDeviceInfo(w http.ResponseWriter, r *http.Request) {
@@ -265,7 +385,13 @@ func (h *Handler) getDeviceInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, deviceData)
+	devices := []types.DeviceDataPayload{withLevel(*deviceData)}
+	if err := h.withShadows(devices); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, devices[0])
 }
 
 func (h *Handler) getAllDeviceBelongToID(w http.ResponseWriter, r *http.Request) {
@@ -283,6 +409,13 @@ func (h *Handler) getAllDeviceBelongToID(w http.ResponseWriter, r *http.Request)
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
+	for i := range devices {
+		devices[i] = withLevel(devices[i])
+	}
+	if err := h.withShadows(devices); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
 
 	utils.WriteJSON(w, http.StatusOK, devices)
 }
@@ -298,23 +431,22 @@ func (h *Handler) getAllDeviceInRoom(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
+	if err := h.withShadows(devices); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
 
 	response := map[string][]types.DeviceDataPayload{
-		"fanList":    {},
-		"lightList":  {},
-		"doorList":   {},
 		"sensorList": {},
 	}
+	for _, t := range devicetype.Devices() {
+		response[t.Name+"List"] = []types.DeviceDataPayload{}
+	}
 
 	for _, d := range devices {
-		switch d.Type {
-		case "fan":
-			response["fanList"] = append(response["fanList"], d)
-		case "light":
-			response["lightList"] = append(response["lightList"], d)
-		case "door":
-			response["doorList"] = append(response["doorList"], d)
-		default:
+		if devicetype.IsDevice(d.Type) {
+			response[d.Type+"List"] = append(response[d.Type+"List"], withLevel(d))
+		} else {
 			response["sensorList"] = append(response["sensorList"], d)
 		}
 	}
@@ -322,6 +454,53 @@ func (h *Handler) getAllDeviceInRoom(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSON(w, http.StatusOK, response)
 }
 
+// withShadows fills in the shadows of the devices among the payloads, the
+// sensors have none.
+func (h *Handler) withShadows(devices []types.DeviceDataPayload) error {
+	ids := []int{}
+	for _, d := range devices {
+		if devicetype.IsDevice(d.Type) {
+			ids = append(ids, d.FeedID)
+		}
+	}
+
+	shadows, err := h.shadows.Shadows(ids)
+	if err != nil {
+		return err
+	}
+	for i, d := range devices {
+		if sh, ok := shadows[d.FeedID]; ok && devicetype.IsDevice(d.Type) {
+			devices[i].Shadow = &sh
+		}
+	}
+	return nil
+}
+
+type deviceType struct {
+	devicetype.Type
+	DisplayName string `json:"displayName"`
+}
+
+// getDeviceTypes lists the device and sensor types, with their names in the
+// reader's locale, for the app to build its forms from.
+func (h *Handler) getDeviceTypes(w http.ResponseWriter, r *http.Request) {
+	locale := i18n.FromRequest(r, "")
+
+	response := map[string][]deviceType{"devices": {}, "sensors": {}}
+	for _, t := range devicetype.Devices() {
+		response["devices"] = append(response["devices"], deviceType{t, t.DisplayName(locale)})
+	}
+	for _, t := range devicetype.Sensors() {
+		// not for the forms, the server adds them
+		if t.Virtual {
+			continue
+		}
+		response["sensors"] = append(response["sensors"], deviceType{t, t.DisplayName(locale)})
+	}
+
+	utils.WriteJSON(w, http.StatusOK, response)
+}
+
 func (h *Handler) createDevice(w http.ResponseWriter, r *http.Request) {
 	userId := auth.GetUserIDFromContext(r.Context())
 
@@ -336,6 +515,15 @@ func (h *Handler) createDevice(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
 		return
 	}
+	t, ok := devicetype.Lookup(payload.Type)
+	if !ok || t.Kind != devicetype.Device {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown device type %s", payload.Type))
+		return
+	}
+	if err := t.ValidateLevels(payload.Levels); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
 
 	err := h.store.CreateDevice(types.Device{
 		Title:   payload.Title,
@@ -344,6 +532,7 @@ func (h *Handler) createDevice(w http.ResponseWriter, r *http.Request) {
 		Type:    payload.Type,
 		UserID:  userId,
 		RoomID:  payload.RoomID,
+		Levels:  payload.Levels,
 	})
 
 	if err != nil {
@@ -351,24 +540,20 @@ func (h *Handler) createDevice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	value := "0" // Default value
-	if payload.Type == "light" {
-		value = "#000000"
-	}
-
 	err = h.logStore.CreateLog(types.LogDevice{
-		Type:     "creation",
-		Message:  fmt.Sprintf("[%s] got added", payload.Title),
-		DeviceID: payload.FeedID,
-		UserID:   userId,
-		Value:    value,
+		Type:       "creation",
+		MessageKey: "log.device.added",
+		Params:     i18n.Params{"title": payload.Title},
+		DeviceID:   payload.FeedID,
+		UserID:     userId,
+		Value:      t.Off,
 	})
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("log creation error:%v", err))
 		return
 	}
 	
-	if payload.Type == "door" {
+	if t.Lock {
 		err := h.doorStore.CreatePassword(types.DoorPassword{
 			FeedID: payload.FeedID,
 			PWD:    "",
@@ -380,41 +565,8 @@ func (h *Handler) createDevice(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// mqtt
-	topic := fmt.Sprintf("%s/feeds/%s", os.Getenv("AIOUSER"), payload.FeedKey)
-	fmt.Println(topic)
-
-	if token := h.mqttClient.Subscribe(topic, 0, func(client MQTT.Client, msg MQTT.Message) {
-		fmt.Printf("New message on %s: %s\n", msg.Topic(), msg.Payload())
-
-		// You can extract device ID by looking up payload.FeedKey or topic
-		message := ""
-		value := string(msg.Payload())
-		switch payload.Type {
-		case "door":
-			if value == "0" {
-				message = fmt.Sprintf("[%s] got closed", payload.Title)
-			} else {
-				message = fmt.Sprintf("[%s] got opened", payload.Title)
-			}
-		case "fan":
-			message = fmt.Sprintf("[%s]'s set at level: %s", payload.Title, value)
-		case "light":
-			message = fmt.Sprintf("[%s]'s set color: %s", payload.Title, value)
-		}
-
-		err = h.logStore.CreateLog(types.LogDevice{
-			Type:     "onoff",
-			Message:  message,
-			DeviceID: payload.FeedID,
-			UserID:   userId,
-			Value:    value,
-		})
-		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("log creation:%v", err))
-			return
-		}
-	}); token.Wait() && token.Error() != nil {
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("mqtt subscribe error: %v", token.Error()))
+	if err := h.feeds.Subscribe(payload.FeedKey); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("mqtt subscribe error: %v", err))
 		return
 	}
 
@@ -3,6 +3,8 @@ package device
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/quanghia24/mySmartHome/types"
 )
@@ -18,11 +20,55 @@ func NewStore(db *sql.DB) *Store {
 }
 
 func (s *Store) CreateDevice(device types.Device) error {
-	_, err := s.db.Exec("INSERT INTO devices (feedId, feedKey, title, type, userID, roomID) VALUES (?, ?, ?, ?, ?, ?)", device.FeedId, device.FeedKey, device.Title, device.Type, device.UserID, device.RoomID)
+	_, err := s.db.Exec("INSERT INTO devices (feedId, feedKey, title, type, userID, roomID, levels) VALUES (?, ?, ?, ?, ?, ?, ?)", device.FeedId, device.FeedKey, device.Title, device.Type, device.UserID, device.RoomID, levelsValue(device.Levels))
 	
 	return err
 }
 
+func (s *Store) GetDevice(feedId int) (*types.Device, error) {
+	device := new(types.Device)
+	var levels sql.NullString
+	err := s.db.QueryRow("SELECT feedId, feedKey, title, type, userId, roomId, levels FROM devices WHERE feedId = ?", feedId).Scan(
+		&device.FeedId,
+		&device.FeedKey,
+		&device.Title,
+		&device.Type,
+		&device.UserID,
+		&device.RoomID,
+		&levels,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no device found for feedId %d", feedId)
+		}
+		return nil, err
+	}
+	device.Levels = parseLevels(levels)
+	return device, nil
+}
+
+func (s *Store) GetDeviceByFeedKey(feedKey string) (*types.Device, error) {
+	device := new(types.Device)
+	var levels sql.NullString
+	err := s.db.QueryRow("SELECT feedId, feedKey, title, type, userId, roomId, levels FROM devices WHERE feedKey = ? LIMIT 1", feedKey).Scan(
+		&device.FeedId,
+		&device.FeedKey,
+		&device.Title,
+		&device.Type,
+		&device.UserID,
+		&device.RoomID,
+		&levels,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no device found for feedKey %s", feedKey)
+		}
+		return nil, err
+	}
+	device.Levels = parseLevels(levels)
+	return device, nil
+}
+
 func (s *Store) GetAllDevices() ([]types.AllDeviceDataPayload, error) {
 	dquery := `
 		SELECT d.feedId, d.feedKey, l.value, d.type, d.title, d.userId, l.createdAt
@@ -117,7 +163,7 @@ func (s *Store) GetDevicesByRoomIdAndType(roomId int, mtype string) ([]int, erro
 
 func (s *Store) GetDevicesByUserID(userId int) ([]types.DeviceDataPayload, error) {
 	dquery := `
-		SELECT d.feedId, d.feedKey, l.value, d.type, d.title, l.createdAt
+		SELECT d.feedId, d.feedKey, l.value, d.type, d.title, l.createdAt, d.levels
 		FROM devices d
 		LEFT JOIN logs l 
 			ON d.feedId = l.deviceId
@@ -135,7 +181,7 @@ func (s *Store) GetDevicesByUserID(userId int) ([]types.DeviceDataPayload, error
 	}
 
 	squery := `
-		SELECT d.feedId, d.feedKey, l.value, d.type, d.title, l.createdAt
+		SELECT d.feedId, d.feedKey, l.value, d.type, d.title, l.createdAt, NULL
 		FROM sensors d
 		LEFT JOIN logs_sensor l 
 			ON d.feedId = l.sensorId
@@ -182,7 +228,7 @@ func (s *Store) GetDevicesByUserID(userId int) ([]types.DeviceDataPayload, error
 
 func (s *Store) GetDevicesByFeedID(feedId int) (*types.DeviceDataPayload, error) {
 	query := `
-		SELECT d.feedId, d.feedKey, logs.value, d.type, d.title, logs.createdAt 
+		SELECT d.feedId, d.feedKey, logs.value, d.type, d.title, logs.createdAt, d.levels
 		FROM devices d
 		LEFT JOIN logs ON d.feedId=logs.deviceId
 		WHERE d.feedId = ?
@@ -191,6 +237,7 @@ func (s *Store) GetDevicesByFeedID(feedId int) (*types.DeviceDataPayload, error)
 	`
 
 	var deviceData types.DeviceDataPayload
+	var levels sql.NullString
 	err := s.db.QueryRow(query, feedId).Scan(
 		&deviceData.FeedID,
 		&deviceData.FeedKey,
@@ -198,6 +245,7 @@ func (s *Store) GetDevicesByFeedID(feedId int) (*types.DeviceDataPayload, error)
 		&deviceData.Type,
 		&deviceData.Title,
 		&deviceData.CreatedAt,
+		&levels,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -205,13 +253,14 @@ func (s *Store) GetDevicesByFeedID(feedId int) (*types.DeviceDataPayload, error)
 		}
 		return nil, err
 	}
+	deviceData.Levels = parseLevels(levels)
 
 	return &deviceData, nil
 }
 
 func (s *Store) GetDevicesInRoomID(roomId int) ([]types.DeviceDataPayload, error) {
 	dquery := `
-		SELECT d.feedId, d.feedKey, l.value, d.type, d.title, l.createdAt
+		SELECT d.feedId, d.feedKey, l.value, d.type, d.title, l.createdAt, d.levels
 		FROM devices d
 		LEFT JOIN logs l 
 			ON d.feedId = l.deviceId
@@ -224,7 +273,7 @@ func (s *Store) GetDevicesInRoomID(roomId int) ([]types.DeviceDataPayload, error
 	`
 
 	squery := `
-		SELECT d.feedId, d.feedKey, l.value, d.type, d.title, l.createdAt
+		SELECT d.feedId, d.feedKey, l.value, d.type, d.title, l.createdAt, NULL
 		FROM sensors d
 		LEFT JOIN logs_sensor l 
 			ON d.feedId = l.sensorId
@@ -268,6 +317,11 @@ func (s *Store) GetDevicesInRoomID(roomId int) ([]types.DeviceDataPayload, error
 	return devices, nil
 }
 
+func (s *Store) UpdateDevice(device types.Device) error {
+	_, err := s.db.Exec("UPDATE devices SET feedKey = ?, title = ?, type = ?, roomId = ?, levels = ? WHERE feedId = ?", device.FeedKey, device.Title, device.Type, device.RoomID, levelsValue(device.Levels), device.FeedId)
+	return err
+}
+
 func (s *Store) DeleteDevice(deviceId string, userId int) error {
 	query := `
 		DELETE FROM devices
@@ -278,6 +332,7 @@ func (s *Store) DeleteDevice(deviceId string, userId int) error {
 
 func scanRowsIntoDeviceDataPayload(rows *sql.Rows) (*types.DeviceDataPayload, error) {
 	device := new(types.DeviceDataPayload)
+	var levels sql.NullString
 
 	err := rows.Scan(
 		&device.FeedID,
@@ -286,10 +341,12 @@ func scanRowsIntoDeviceDataPayload(rows *sql.Rows) (*types.DeviceDataPayload, er
 		&device.Type,
 		&device.Title,
 		&device.CreatedAt,
+		&levels,
 	)
 	if err != nil {
 		return nil, err
 	}
+	device.Levels = parseLevels(levels)
 
 	return device, nil
 }
@@ -313,3 +370,28 @@ func scanRowsIntoAllDeviceDataPayload(rows *sql.Rows) (*types.AllDeviceDataPaylo
 	return device, nil
 }
 
+// levels are stored comma-separated, NULL for the type's and empty for a
+// continuous range
+func parseLevels(levels sql.NullString) []int {
+	if !levels.Valid {
+		return nil
+	}
+	parsed := []int{}
+	for _, l := range strings.Split(levels.String, ",") {
+		if v, err := strconv.Atoi(l); err == nil {
+			parsed = append(parsed, v)
+		}
+	}
+	return parsed
+}
+
+func levelsValue(levels []int) any {
+	if levels == nil {
+		return nil
+	}
+	values := make([]string, len(levels))
+	for i, l := range levels {
+		values[i] = strconv.Itoa(l)
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,96 @@
+package device
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/quanghia24/mySmartHome/services/alert"
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/services/events"
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/services/plan"
+	"github.com/quanghia24/mySmartHome/services/shadow"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// SubscribeLogs keeps every new state of the devices in their log.
+func SubscribeLogs(bus *events.Bus, logStore types.LogDeviceStore) {
+	bus.DeviceStateChanged.Subscribe(func(e events.DeviceStateChanged) {
+		key, params := StateMessage(e.Device.Type, e.Device.Levels, e.Device.Title, e.Value)
+		err := logStore.CreateLog(types.LogDevice{
+			Type:       "onoff",
+			MessageKey: key,
+			Params:     params,
+			DeviceID:   e.Device.FeedId,
+			UserID:     e.Device.UserID,
+			Value:      e.Value,
+		})
+		if err != nil {
+			fmt.Printf("log creation err at mqtt:%v\n", err)
+		}
+	})
+}
+
+// SubscribeLockAlerts keeps a critical alert open while a lock, such as a
+// door, reports being open, so it gets escalated when nobody acknowledges it.
+func SubscribeLockAlerts(bus *events.Bus, alertStore types.AlertStore) {
+	bus.DeviceStateChanged.Subscribe(func(e events.DeviceStateChanged) {
+		t, ok := devicetype.ForDevice(e.Device.Type, e.Device.Levels)
+		if !ok || !t.Lock {
+			return
+		}
+
+		if t.IsOff(e.Value) {
+			if err := alert.Clear(alertStore, "device", e.Device.FeedId, alert.KindOpen); err != nil {
+				log.Println("resolve alert:", err)
+			}
+			return
+		}
+
+		key, params := StateMessage(e.Device.Type, e.Device.Levels, e.Device.Title, e.Value)
+		_, err := alert.Raise(alertStore, types.Alert{
+			UserID:     e.Device.UserID,
+			SourceType: "device",
+			SourceID:   e.Device.FeedId,
+			Kind:       alert.KindOpen,
+			Severity:   alert.SeverityCritical,
+			Message:    i18n.Render(i18n.DefaultLocale, key, params),
+			Value:      e.Value,
+		})
+		if err != nil {
+			log.Println("raise alert:", err)
+		}
+	})
+}
+
+// SubscribeAutoControl turns on the devices that counter a threshold breach
+// in their room, as declared by the sensor's type: a dark room gets its
+// lights on, a hot one its fans.
+func SubscribeAutoControl(bus *events.Bus, store types.DeviceStore, shadows *shadow.Tracker) {
+	bus.ThresholdCrossed.Subscribe(func(e events.ThresholdCrossed) {
+		if e.Event.Kind != plan.AlertStarted {
+			return
+		}
+
+		sensorType, _ := devicetype.Lookup(e.Sensor.Type)
+		targets := sensorType.Counters[e.Event.Bound]
+		if len(targets) == 0 {
+			return
+		}
+
+		devices, err := store.GetDevicesInRoomID(e.Sensor.RoomID)
+		if err != nil {
+			fmt.Println("error when get all devices in room:", err)
+		}
+
+		for _, device := range devices {
+			t, ok := devicetype.ForDevice(device.Type, device.Levels)
+			if ok && slices.Contains(targets, t.Name) && t.IsOff(device.Value) {
+				if _, err := shadows.Send(device.FeedID, device.FeedKey, t.On); err != nil {
+					log.Println("auto control:", err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package devicetype
+
+import "github.com/quanghia24/mySmartHome/services/light"
+
+func init() {
+	Register(Type{
+		Name:    "fan",
+		Kind:    Device,
+		Schema:  Schema{Kind: ValueNumber, Min: 0, Max: 100},
+		Off:     "0",
+		On:      "75",
+		PowerKW: 3.0,
+		// the app sends a level, the feed takes a speed in percent
+		Levels:  []int{50, 75, 100},
+		Message: fixed("log.device.fan_level"),
+	})
+	Register(Type{
+		Name:    "light",
+		Kind:    Device,
+		Schema:  Schema{Kind: ValueColor},
+		Off:     "#000000",
+		On:      "#FFFFFF",
+		PowerKW: 2.0,
+		// a dimmed light draws its share of the power
+		Load:    light.LoadOf,
+		Message: fixed("log.device.light_color"),
+	})
+	Register(Type{
+		Name:    "door",
+		Kind:    Device,
+		Schema:  Schema{Kind: ValueBinary},
+		Off:     "0",
+		On:      "1",
+		Lock:    true,
+		Message: onOff("log.device.door_opened", "log.device.door_closed"),
+	})
+	Register(Type{
+		Name:    "plug",
+		Kind:    Device,
+		Schema:  Schema{Kind: ValueBinary},
+		Off:     "0",
+		On:      "1",
+		PowerKW: 1.0,
+		Message: onOff("log.device.switched_on", "log.device.switched_off"),
+	})
+	Register(Type{
+		Name:    "curtain",
+		Kind:    Device,
+		Schema:  Schema{Kind: ValueNumber, Min: 0, Max: 100},
+		Off:     "0",
+		On:      "100",
+		Message: fixed("log.device.curtain_position"),
+	})
+	Register(Type{
+		Name: "ac",
+		Kind: Device,
+		// the target temperature, 0 when off
+		Schema:  Schema{Kind: ValueNumber, Min: 0, Max: 30},
+		Off:     "0",
+		On:      "26",
+		PowerKW: 1.5,
+		Message: onOff("log.device.ac_target", "log.device.switched_off"),
+	})
+
+	Register(Type{
+		Name:     "temperature",
+		Kind:     Sensor,
+		Schema:   Schema{Kind: ValueNumber, Min: -40, Max: 80},
+		Unit:     "°C",
+		Counters: map[string][]string{"upper": {"fan", "ac"}},
+	})
+	Register(Type{
+		Name:   "humidity",
+		Kind:   Sensor,
+		Schema: Schema{Kind: ValueNumber, Min: 0, Max: 100},
+		Unit:   "%",
+	})
+	Register(Type{
+		Name:     "brightness",
+		Kind:     Sensor,
+		Schema:   Schema{Kind: ValueNumber, Min: 0, Max: 100000},
+		Unit:     "lx",
+		Counters: map[string][]string{"lower": {"light"}},
+	})
+	Register(Type{
+		Name:   "motion",
+		Kind:   Sensor,
+		Schema: Schema{Kind: ValueBinary},
+	})
+	Register(Type{
+		Name:   "co2",
+		Kind:   Sensor,
+		Schema: Schema{Kind: ValueNumber, Min: 0, Max: 10000},
+		Unit:   "ppm",
+	})
+}
+
+// fixed logs every state with the same template.
+func fixed(key string) func(string) string {
+	return func(string) string { return key }
+}
+
+// onOff logs the off value with its own template.
+func onOff(on string, off string) func(string) string {
+	return func(value string) string {
+		if value == "0" {
+			return off
+		}
+		return on
+	}
+}
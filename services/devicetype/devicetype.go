@@ -0,0 +1,317 @@
+package devicetype
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+
+	"github.com/quanghia24/mySmartHome/services/i18n"
+)
+
+// Kind tells a device, which takes commands, from a sensor, which only
+// reports.
+type Kind string
+
+const (
+	Device Kind = "device"
+	Sensor Kind = "sensor"
+)
+
+// kinds of the values of a feed
+const (
+	ValueBinary = "binary" // "0" or "1"
+	ValueNumber = "number" // within Min and Max
+	ValueColor  = "color"  // #RRGGBB
+)
+
+var colorValue = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// Schema is the shape of the values of a feed.
+type Schema struct {
+	Kind string  `json:"kind"`
+	Min  float64 `json:"min,omitempty"`
+	Max  float64 `json:"max,omitempty"`
+}
+
+// Validate tells whether the value fits the schema.
+func (s Schema) Validate(value string) error {
+	switch s.Kind {
+	case ValueBinary:
+		if value != "0" && value != "1" {
+			return fmt.Errorf("value must be 0 or 1")
+		}
+	case ValueNumber:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < s.Min || f > s.Max {
+			return fmt.Errorf("value must be a number between %v and %v", s.Min, s.Max)
+		}
+	case ValueColor:
+		if !colorValue.MatchString(value) {
+			return fmt.Errorf("value must be a #RRGGBB color")
+		}
+	}
+	return nil
+}
+
+// Bounds returns the lowest and the highest value of the schema, false when
+// its values aren't numbers.
+func (s Schema) Bounds() (float64, float64, bool) {
+	switch s.Kind {
+	case ValueBinary:
+		return 0, 1, true
+	case ValueNumber:
+		return s.Min, s.Max, true
+	}
+	return 0, 0, false
+}
+
+// Type declares how the feeds of a device or a sensor type behave, so the
+// rest of the server has no list of types of its own.
+type Type struct {
+	Name string `json:"name"`
+	Kind Kind   `json:"kind"`
+	// key of the type's name in the i18n catalog
+	DisplayKey string `json:"-"`
+	Schema     Schema `json:"schema"`
+
+	// value of the feed of a device when off, and the one turning it on
+	Off string `json:"off,omitempty"`
+	On  string `json:"on,omitempty"`
+	// power drawn while on in kW, types without one are left out of the
+	// electric bills and the usage statistics
+	PowerKW float64 `json:"powerKW,omitempty"`
+	// share of PowerKW drawn at a value of the feed, all of it while on when
+	// nil
+	Load func(value string) float64 `json:"-"`
+	// keeps a password checked before unlocking
+	Lock bool `json:"lock,omitempty"`
+	// values of the feed at the levels 1, 2, ... the app sends instead of a
+	// value, level 0 being Off. A device of the type can have its own, or
+	// none to take any value of the schema.
+	Levels []int `json:"levels,omitempty"`
+	// template of the log of a new state, by its value
+	Message func(value string) string `json:"-"`
+
+	// unit of a sensor's readings
+	Unit string `json:"unit,omitempty"`
+	// derived by the server from other sensors, it is never added by hand
+	Virtual bool `json:"virtual,omitempty"`
+	// device types turned on against a reading out of a sensor's "lower" or
+	// "upper" bound
+	Counters map[string][]string `json:"counters,omitempty"`
+}
+
+// IsOff tells whether the value of a device's feed is its off value.
+func (t Type) IsOff(value string) bool {
+	return value == t.Off
+}
+
+// LoadAt is the share of its power the device draws at the value of its
+// feed.
+func (t Type) LoadAt(value string) float64 {
+	if t.IsOff(value) {
+		return 0
+	}
+	if t.Load != nil {
+		return t.Load(value)
+	}
+	return 1
+}
+
+// LoadAt is the share of its power a device of the type draws at the value,
+// the type's off value counting as 0 and any other as 1 for an unknown type.
+func LoadAt(name string, value string) float64 {
+	if t, ok := registry[name]; ok {
+		return t.LoadAt(value)
+	}
+	if value == "0" {
+		return 0
+	}
+	return 1
+}
+
+// ToFeed maps a command of the app to the value posted to the feed: a level
+// when the type has levels, the value itself otherwise.
+func (t Type) ToFeed(command string) (string, error) {
+	if len(t.Levels) > 0 {
+		level, err := strconv.Atoi(command)
+		if err != nil || level < 0 || level > len(t.Levels) {
+			return "", fmt.Errorf("level must be between 0 and %d", len(t.Levels))
+		}
+		if level == 0 {
+			return t.Off, nil
+		}
+		return strconv.Itoa(t.Levels[level-1]), nil
+	}
+	if err := t.Schema.Validate(command); err != nil {
+		return "", err
+	}
+	return command, nil
+}
+
+// Level maps a value of the feed back to the nearest level, false when the
+// type has no levels or the value isn't a number.
+func (t Type) Level(value string) (int, bool) {
+	if len(t.Levels) == 0 {
+		return 0, false
+	}
+	if t.IsOff(value) {
+		return 0, true
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	// a value set outside the app, e.g. from the dashboard, may sit between
+	// two levels
+	level, off := 0, math.Abs(v)
+	for i, l := range t.Levels {
+		if d := math.Abs(v - float64(l)); d < off {
+			level, off = i+1, d
+		}
+	}
+	return level, true
+}
+
+// ValidateLevels tells whether a device of the type can have the levels,
+// which must be increasing values of its schema. Empty levels make for a
+// continuous range.
+func (t Type) ValidateLevels(levels []int) error {
+	if levels == nil {
+		return nil
+	}
+	if t.Levels == nil {
+		return fmt.Errorf("%s has no levels", t.Name)
+	}
+	for i, l := range levels {
+		if float64(l) <= t.Schema.Min || float64(l) > t.Schema.Max {
+			return fmt.Errorf("levels must be above %v and at most %v", t.Schema.Min, t.Schema.Max)
+		}
+		if i > 0 && l <= levels[i-1] {
+			return fmt.Errorf("levels must be increasing")
+		}
+	}
+	return nil
+}
+
+// WithLevels is the type as a device with its own levels sees it, the type
+// itself for nil levels. The middle level is the one turning it on.
+func (t Type) WithLevels(levels []int) Type {
+	if levels == nil {
+		return t
+	}
+	t.Levels = levels
+	if len(levels) > 0 {
+		t.On = strconv.Itoa(levels[len(levels)/2])
+	}
+	return t
+}
+
+// MessageKey is the template of the log of a new state, empty when the type
+// has none.
+func (t Type) MessageKey(value string) string {
+	if t.Message == nil {
+		return ""
+	}
+	return t.Message(value)
+}
+
+// DisplayName is the name of the type in the locale.
+func (t Type) DisplayName(locale string) string {
+	return i18n.Render(locale, t.DisplayKey, nil)
+}
+
+var (
+	registry = map[string]Type{}
+	// names in the order they were registered in
+	names []string
+)
+
+// Register adds a type. It panics on a name registered twice, which is a
+// programming error.
+func Register(t Type) {
+	if _, ok := registry[t.Name]; ok {
+		panic("devicetype: " + t.Name + " registered twice")
+	}
+	if t.DisplayKey == "" {
+		t.DisplayKey = string(t.Kind) + "." + t.Name
+	}
+	registry[t.Name] = t
+	names = append(names, t.Name)
+}
+
+// Lookup returns the registered type of the name.
+func Lookup(name string) (Type, bool) {
+	t, ok := registry[name]
+	return t, ok
+}
+
+// ForDevice returns the registered type of the name as a device with the
+// levels sees it.
+func ForDevice(name string, levels []int) (Type, bool) {
+	t, ok := registry[name]
+	return t.WithLevels(levels), ok
+}
+
+// LevelOf maps the value of a device with the levels back to its level,
+// false when it has none.
+func LevelOf(name string, levels []int, value string) (int, bool) {
+	t, ok := ForDevice(name, levels)
+	if !ok {
+		return 0, false
+	}
+	return t.Level(value)
+}
+
+// IsDevice tells whether the name is a registered device type.
+func IsDevice(name string) bool {
+	t, ok := registry[name]
+	return ok && t.Kind == Device
+}
+
+// IsSensor tells whether the name is a registered sensor type.
+func IsSensor(name string) bool {
+	t, ok := registry[name]
+	return ok && t.Kind == Sensor
+}
+
+// Devices lists the device types in the order they were registered in.
+func Devices() []Type {
+	return ofKind(Device)
+}
+
+// Sensors lists the sensor types in the order they were registered in.
+func Sensors() []Type {
+	return ofKind(Sensor)
+}
+
+// Metered lists the device types drawing power while on.
+func Metered() []Type {
+	metered := []Type{}
+	for _, t := range Devices() {
+		if t.PowerKW > 0 {
+			metered = append(metered, t)
+		}
+	}
+	return metered
+}
+
+// Off is the off value of the device type, "0" for an unknown one.
+func Off(name string) string {
+	if t, ok := registry[name]; ok {
+		return t.Off
+	}
+	return "0"
+}
+
+func ofKind(kind Kind) []Type {
+	types := []Type{}
+	for _, name := range names {
+		if t := registry[name]; t.Kind == kind {
+			types = append(types, t)
+		}
+	}
+	return types
+}
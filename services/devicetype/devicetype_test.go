@@ -0,0 +1,131 @@
+package devicetype
+
+import "testing"
+
+func TestSchemaValidate(t *testing.T) {
+	cases := []struct {
+		schema Schema
+		value  string
+		ok     bool
+	}{
+		{Schema{Kind: ValueBinary}, "1", true},
+		{Schema{Kind: ValueBinary}, "2", false},
+		{Schema{Kind: ValueNumber, Min: 0, Max: 100}, "42.5", true},
+		{Schema{Kind: ValueNumber, Min: 0, Max: 100}, "101", false},
+		{Schema{Kind: ValueNumber, Min: 0, Max: 100}, "high", false},
+		{Schema{Kind: ValueColor}, "#FFaa00", true},
+		{Schema{Kind: ValueColor}, "#FFF", false},
+	}
+	for _, c := range cases {
+		if err := c.schema.Validate(c.value); (err == nil) != c.ok {
+			t.Errorf("%s %q: expected ok %v, got %v", c.schema.Kind, c.value, c.ok, err)
+		}
+	}
+}
+
+func TestToFeed(t *testing.T) {
+	fan, _ := Lookup("fan")
+	if v, _ := fan.ToFeed("2"); v != "75" {
+		t.Errorf("expected fan level 2 to be 75, got %s", v)
+	}
+
+	light, _ := Lookup("light")
+	if _, err := light.ToFeed("white"); err == nil {
+		t.Error("expected a color to be required")
+	}
+	if v, err := light.ToFeed("#FFFFFF"); err != nil || v != "#FFFFFF" {
+		t.Errorf("expected the color as is, got %s %v", v, err)
+	}
+}
+
+func TestBuiltinTypes(t *testing.T) {
+	for _, d := range Devices() {
+		if d.Schema.Validate(d.Off) != nil || d.Schema.Validate(d.On) != nil {
+			t.Errorf("%s: expected its on and off values to fit its schema", d.Name)
+		}
+		if d.MessageKey(d.On) == "" {
+			t.Errorf("%s: expected a log message", d.Name)
+		}
+	}
+	for _, name := range names {
+		if typ := registry[name]; typ.DisplayName("en") == typ.DisplayKey {
+			t.Errorf("%s: missing display name %s", name, typ.DisplayKey)
+		}
+	}
+	for _, s := range Sensors() {
+		for _, targets := range s.Counters {
+			for _, target := range targets {
+				if !IsDevice(target) {
+					t.Errorf("%s: unknown counter %s", s.Name, target)
+				}
+			}
+		}
+	}
+
+	if door, _ := Lookup("door"); door.MessageKey("0") != "log.device.door_closed" || !door.Lock {
+		t.Errorf("unexpected door type %+v", door)
+	}
+	if Off("light") != "#000000" || Off("unknown") != "0" {
+		t.Error("unexpected off values")
+	}
+	if IsDevice("temperature") || !IsSensor("co2") {
+		t.Error("expected the kinds to be told apart")
+	}
+}
+
+func TestLoadAt(t *testing.T) {
+	cases := []struct {
+		name, value string
+		want        float64
+	}{
+		{"light", "#000000", 0},
+		{"light", "#808080", 0.5},
+		{"fan", "50", 1},
+		{"fan", "0", 0},
+		{"unknown", "1", 1},
+	}
+	for _, c := range cases {
+		if got := LoadAt(c.name, c.value); got != c.want {
+			t.Errorf("%s at %s: got %v, want %v", c.name, c.value, got, c.want)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	fan, _ := ForDevice("fan", []int{30, 60, 80, 100})
+	if v, _ := fan.ToFeed("1"); v != "30" {
+		t.Errorf("expected level 1 to be 30, got %s", v)
+	}
+	if v, _ := fan.ToFeed("0"); v != "0" {
+		t.Errorf("expected level 0 to be off, got %s", v)
+	}
+	if _, err := fan.ToFeed("5"); err == nil {
+		t.Error("expected an error for a level the fan doesn't have")
+	}
+	if fan.On != "80" {
+		t.Errorf("expected the middle level to turn it on, got %s", fan.On)
+	}
+	for value, want := range map[string]int{"0": 0, "30": 1, "65": 2, "100": 4, "10": 0} {
+		if level, ok := fan.Level(value); !ok || level != want {
+			t.Errorf("%s: got level %d, want %d", value, level, want)
+		}
+	}
+
+	continuous, _ := ForDevice("fan", []int{})
+	if v, err := continuous.ToFeed("42"); err != nil || v != "42" {
+		t.Errorf("expected a continuous fan to take 42, got %s, %v", v, err)
+	}
+	if _, ok := continuous.Level("42"); ok {
+		t.Error("expected no level for a continuous fan")
+	}
+
+	if err := fan.ValidateLevels([]int{60, 30}); err == nil {
+		t.Error("expected decreasing levels to be rejected")
+	}
+	if err := fan.ValidateLevels([]int{0, 50}); err == nil {
+		t.Error("expected a level at the off value to be rejected")
+	}
+	if light, _ := Lookup("light"); light.ValidateLevels([]int{50}) == nil {
+		t.Error("expected a type without levels to reject them")
+	}
+}
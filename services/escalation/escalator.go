@@ -0,0 +1,147 @@
+package escalation
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/services/notification"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// how often the escalator looks for unacknowledged alerts
+const checkInterval = time.Minute
+
+// Escalator resends alerts nobody acknowledged and widens who hears about
+// them, step by step as their severity's policy says.
+type Escalator struct {
+	config     Config
+	alerts     types.AlertStore
+	userStore  types.UserStore
+	households types.HouseholdStore
+
+	notifier notifier
+	webhook  notification.Notifier
+}
+
+// notifier reaches a user, the notification.Dispatcher outside of tests.
+type notifier interface {
+	Notify(userId int, msg notification.Message)
+	Escalate(userId int, msg notification.Message)
+}
+
+func NewEscalator(config Config, alerts types.AlertStore, userStore types.UserStore, households types.HouseholdStore, dispatcher *notification.Dispatcher) *Escalator {
+	return &Escalator{
+		config:     config,
+		alerts:     alerts,
+		userStore:  userStore,
+		households: households,
+		notifier:   dispatcher,
+		webhook:    notification.WebhookNotifier{},
+	}
+}
+
+// Start checks the alerts every checkInterval.
+func (e *Escalator) Start() {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := e.Run(time.Now()); err != nil {
+			fmt.Println("Error escalating alerts:", err)
+		}
+	}
+}
+
+// Run escalates every open alert that reached its next step.
+func (e *Escalator) Run(now time.Time) error {
+	alerts, err := e.alerts.GetUnacknowledgedAlerts()
+	if err != nil {
+		return err
+	}
+
+	for _, a := range alerts {
+		policy := e.config.Severities[a.Severity]
+		level := policy.Level(now.Sub(a.OpenedAt))
+		if level <= a.EscalationLevel {
+			continue
+		}
+
+		for step := a.EscalationLevel + 1; step <= level; step++ {
+			e.escalate(a, step, policy)
+		}
+		if err := e.alerts.SetEscalationLevel(a.ID, level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Escalator) escalate(a types.Alert, step int, policy Policy) {
+	owner, err := e.userStore.GetUserByID(a.UserID)
+	if err != nil {
+		log.Println("escalate alert:", err)
+		return
+	}
+	params := i18n.Params{
+		"source":   a.SourceTitle,
+		"message":  a.Message,
+		"owner":    owner.FirstName,
+		"severity": a.Severity,
+		"openedAt": a.OpenedAt.Format(time.RFC1123),
+	}
+	critical := a.Severity == "critical"
+
+	switch step {
+	case levelResent:
+		if policy.ResendAfterMinutes > 0 {
+			e.notifier.Notify(owner.ID, notification.Message{TitleKey: "noti.resent.title", BodyKey: "noti.alert.body", Params: params, Category: notification.CategoryThreshold, Critical: critical})
+		}
+
+	case levelMembers:
+		if policy.MembersAfterMinutes == 0 {
+			return
+		}
+		members, err := e.households.GetMembers(owner.ID)
+		if err != nil {
+			log.Println("escalate alert:", err)
+			return
+		}
+		for _, m := range members {
+			e.notifier.Notify(m.ID, notification.Message{TitleKey: "noti.members.title", BodyKey: "noti.alert.body", Params: params, Category: notification.CategoryThreshold, Critical: critical})
+		}
+
+	case levelSecondary:
+		if policy.SecondaryAfterMinutes == 0 {
+			return
+		}
+		members, err := e.households.GetMembers(owner.ID)
+		if err != nil {
+			log.Println("escalate alert:", err)
+		}
+
+		escalated := notification.Message{
+			TitleKey: "noti.escalated.title",
+			BodyKey:  "noti.escalated.body",
+			Params:   params,
+			Data: map[string]string{
+				"event":    "alert.escalated",
+				"alertId":  strconv.Itoa(a.ID),
+				"severity": a.Severity,
+				"source":   a.SourceType + ":" + strconv.Itoa(a.SourceID),
+			},
+		}
+		e.notifier.Escalate(owner.ID, escalated)
+		for _, m := range members {
+			e.notifier.Escalate(m.ID, escalated)
+		}
+
+		if e.config.WebhookURL != "" {
+			if err := e.webhook.Send(e.config.WebhookURL, escalated.Localize(i18n.DefaultLocale)); err != nil {
+				log.Println("escalation webhook:", err)
+			}
+		}
+	}
+}
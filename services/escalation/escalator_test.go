@@ -0,0 +1,132 @@
+package escalation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/notification"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+type alerts struct {
+	types.AlertStore
+	open []types.Alert
+}
+
+func (a *alerts) GetUnacknowledgedAlerts() ([]types.Alert, error) {
+	return a.open, nil
+}
+
+func (a *alerts) SetEscalationLevel(id int, level int) error {
+	for i := range a.open {
+		if a.open[i].ID == id {
+			a.open[i].EscalationLevel = level
+		}
+	}
+	return nil
+}
+
+type users struct{ types.UserStore }
+
+func (users) GetUserByID(id int) (*types.User, error) {
+	return &types.User{ID: id, FirstName: "An", Email: "owner@example.com"}, nil
+}
+
+type households struct{ types.HouseholdStore }
+
+func (households) GetMembers(ownerId int) ([]types.User, error) {
+	return []types.User{{ID: 2, Email: "member@example.com"}}, nil
+}
+
+type recorder struct {
+	notified  []int
+	escalated []int
+}
+
+func (r *recorder) Notify(userId int, msg notification.Message) {
+	r.notified = append(r.notified, userId)
+}
+
+func (r *recorder) Escalate(userId int, msg notification.Message) {
+	r.escalated = append(r.escalated, userId)
+}
+
+type webhook struct{ sent int }
+
+func (w *webhook) Send(target string, msg notification.Message) error {
+	if msg.Data["event"] != "alert.escalated" {
+		return fmt.Errorf("unexpected payload %+v", msg)
+	}
+	w.sent++
+	return nil
+}
+
+func TestRunEscalatesStepByStep(t *testing.T) {
+	opened := time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC)
+	store := &alerts{open: []types.Alert{
+		{ID: 1, UserID: 1, Severity: "critical", Message: "too hot", OpenedAt: opened},
+		{ID: 2, UserID: 1, Severity: "info", Message: "odd", OpenedAt: opened},
+	}}
+
+	e := NewEscalator(Default(), store, users{}, households{}, nil)
+	e.config.WebhookURL = "http://hooks.example.com"
+	sent := &recorder{}
+	hook := &webhook{}
+	e.notifier = sent
+	e.webhook = hook
+
+	run := func(minutes int) {
+		if err := e.Run(opened.Add(time.Duration(minutes) * time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run(3)
+	if len(sent.notified) != 0 {
+		t.Fatalf("expected nothing before the resend, got %v", sent.notified)
+	}
+
+	run(6)
+	if len(sent.notified) != 1 || store.open[0].EscalationLevel != levelResent {
+		t.Fatalf("expected the owner to be notified again, got %v", sent.notified)
+	}
+
+	run(7)
+	if len(sent.notified) != 1 {
+		t.Fatalf("expected no second resend, got %v", sent.notified)
+	}
+
+	run(16)
+	if len(sent.notified) != 2 || sent.notified[1] != 2 || store.open[0].EscalationLevel != levelMembers {
+		t.Fatalf("expected the member to be notified, got %v", sent.notified)
+	}
+
+	run(31)
+	if len(sent.escalated) != 2 || hook.sent != 1 || store.open[0].EscalationLevel != levelSecondary {
+		t.Errorf("expected the owner and the member to be escalated to, got %v and %d webhooks", sent.escalated, hook.sent)
+	}
+	if store.open[1].EscalationLevel != levelOpened {
+		t.Errorf("expected the info alert to stay put")
+	}
+}
+
+func TestParse(t *testing.T) {
+	config, err := parse([]byte(`{"severities": {"warning": {"resendAfterMinutes": 10, "membersAfterMinutes": 20}}, "webhookUrl": "http://x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := config.Severities["warning"]; p.ResendAfterMinutes != 10 || p.MembersAfterMinutes != 20 {
+		t.Errorf("expected the warning policy from the file, got %+v", p)
+	}
+	if config.Severities["critical"] != Default().Severities["critical"] {
+		t.Errorf("expected the critical default to stay")
+	}
+	if p := config.Severities["warning"]; p.Level(25*time.Minute) != levelMembers || p.Level(5*time.Minute) != levelOpened {
+		t.Errorf("unexpected levels for %+v", p)
+	}
+
+	if _, err := parse([]byte(`{"severities": {"critical": {"resendAfterMinutes": -1}}}`)); err == nil {
+		t.Errorf("expected negative minutes to be rejected")
+	}
+}
@@ -0,0 +1,101 @@
+package escalation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// steps an unacknowledged alert goes through, the owner got a push when it
+// opened
+const (
+	levelOpened = iota
+	levelResent
+	levelMembers
+	levelSecondary
+)
+
+// Policy says how many minutes after an alert opened, while nobody
+// acknowledged it, it is pushed to the owner again, to the other members of
+// the household and to the secondary channels. 0 skips the step.
+type Policy struct {
+	ResendAfterMinutes    int `json:"resendAfterMinutes"`
+	MembersAfterMinutes   int `json:"membersAfterMinutes"`
+	SecondaryAfterMinutes int `json:"secondaryAfterMinutes"`
+}
+
+// Config holds the policies by alert severity and the webhook the secondary
+// step posts to, besides the escalation channels of the household.
+type Config struct {
+	Severities map[string]Policy `json:"severities"`
+	WebhookURL string            `json:"webhookUrl"`
+}
+
+// Default escalates critical alerts within half an hour and reminds the
+// owner of warnings after half an hour. Info alerts are never escalated.
+func Default() Config {
+	return Config{
+		Severities: map[string]Policy{
+			"critical": {ResendAfterMinutes: 5, MembersAfterMinutes: 15, SecondaryAfterMinutes: 30},
+			"warning":  {ResendAfterMinutes: 30},
+		},
+	}
+}
+
+// Load returns the escalation configured through ESCALATION_FILE, or
+// Default. A severity in the file replaces its default policy.
+func Load() (Config, error) {
+	config := Default()
+
+	path := os.Getenv("ESCALATION_FILE")
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read escalation file: %v", err)
+	}
+	return parse(data)
+}
+
+func parse(data []byte) (Config, error) {
+	config := Default()
+
+	var file Config
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Config{}, fmt.Errorf("parse escalation file: %v", err)
+	}
+
+	for severity, p := range file.Severities {
+		config.Severities[severity] = p
+	}
+	config.WebhookURL = file.WebhookURL
+
+	return config, config.Validate()
+}
+
+func (c Config) Validate() error {
+	for severity, p := range c.Severities {
+		if p.ResendAfterMinutes < 0 || p.MembersAfterMinutes < 0 || p.SecondaryAfterMinutes < 0 {
+			return fmt.Errorf("escalation for %s can't be negative", severity)
+		}
+	}
+	return nil
+}
+
+// Level returns the last step an alert of the given age has reached.
+func (p Policy) Level(age time.Duration) int {
+	level := levelOpened
+	for step, minutes := range map[int]int{
+		levelResent:    p.ResendAfterMinutes,
+		levelMembers:   p.MembersAfterMinutes,
+		levelSecondary: p.SecondaryAfterMinutes,
+	} {
+		if minutes > 0 && age >= time.Duration(minutes)*time.Minute && step > level {
+			level = step
+		}
+	}
+	return level
+}
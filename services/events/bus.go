@@ -0,0 +1,63 @@
+package events
+
+import (
+	"log"
+	"sync"
+)
+
+// Topic delivers the events of one type to its subscribers.
+type Topic[E any] struct {
+	name     string
+	mu       sync.RWMutex
+	handlers []func(E)
+}
+
+// Subscribe calls fn with every event published from now on.
+func (t *Topic[E]) Subscribe(fn func(E)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers = append(t.handlers, fn)
+}
+
+// Publish calls the subscribers in the order they subscribed and returns
+// once they are all done. A subscriber that panics is logged and doesn't
+// keep the event from the others.
+func (t *Topic[E]) Publish(e E) {
+	t.mu.RLock()
+	handlers := t.handlers
+	t.mu.RUnlock()
+
+	for _, fn := range handlers {
+		t.call(fn, e)
+	}
+}
+
+func (t *Topic[E]) call(fn func(E), e E) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("%s subscriber: %v\n", t.name, r)
+		}
+	}()
+	fn(e)
+}
+
+// Bus carries what happens in the home, from the MQTT feeds to the parts
+// that react to it: logs, plans, device control, notifications, webhooks and
+// the live stream.
+type Bus struct {
+	DeviceStateChanged Topic[DeviceStateChanged]
+	ShadowChanged      Topic[ShadowChanged]
+	SensorReading      Topic[SensorReading]
+	ThresholdCrossed   Topic[ThresholdCrossed]
+	AnomalyDetected    Topic[AnomalyDetected]
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		DeviceStateChanged: Topic[DeviceStateChanged]{name: "device state"},
+		ShadowChanged:      Topic[ShadowChanged]{name: "shadow"},
+		SensorReading:      Topic[SensorReading]{name: "sensor reading"},
+		ThresholdCrossed:   Topic[ThresholdCrossed]{name: "threshold"},
+		AnomalyDetected:    Topic[AnomalyDetected]{name: "anomaly"},
+	}
+}
@@ -0,0 +1,27 @@
+package events
+
+import "testing"
+
+func TestPublish(t *testing.T) {
+	bus := NewBus()
+
+	got := []string{}
+	bus.SensorReading.Subscribe(func(e SensorReading) {
+		got = append(got, "first "+e.Raw)
+	})
+	bus.SensorReading.Subscribe(func(e SensorReading) {
+		panic("broken subscriber")
+	})
+	bus.SensorReading.Subscribe(func(e SensorReading) {
+		got = append(got, "last "+e.Raw)
+	})
+	bus.DeviceStateChanged.Subscribe(func(e DeviceStateChanged) {
+		got = append(got, "device "+e.Value)
+	})
+
+	bus.SensorReading.Publish(SensorReading{Raw: "31.5"})
+
+	if len(got) != 2 || got[0] != "first 31.5" || got[1] != "last 31.5" {
+		t.Errorf("expected every subscriber of the topic in order, got %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/plan"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// DeviceStateChanged is a new value on the feed of a device.
+type DeviceStateChanged struct {
+	Device types.Device
+	Value  string
+	At     time.Time
+}
+
+// ShadowChanged is a command sent to a device, confirmed by the value it
+// reported or failed.
+type ShadowChanged struct {
+	Device types.Device
+	Shadow types.DeviceShadow
+}
+
+// SensorReading is a new value on the feed of a sensor, or a derived value
+// of a virtual one.
+type SensorReading struct {
+	Sensor types.Sensor
+	Raw    string
+	// rounded to 1 decimal place, 0 when the raw value isn't a number
+	Value   float64
+	Numeric bool
+	At      time.Time
+}
+
+// ThresholdCrossed is the start or the end of a reading out of the bounds of
+// the sensor's plan.
+type ThresholdCrossed struct {
+	Sensor types.Sensor
+	Event  plan.Event
+	Raw    string
+}
+
+// AnomalyDetected is a reading unusual for the sensor at that time.
+type AnomalyDetected struct {
+	Sensor   types.Sensor
+	Value    float64
+	Raw      string
+	Expected float64
+	StdDev   float64
+}
+
+// NewSensorReading reads the raw value of a sensor's feed.
+func NewSensorReading(s types.Sensor, raw string, at time.Time) SensorReading {
+	f, err := strconv.ParseFloat(raw, 64)
+	return SensorReading{
+		Sensor:  s,
+		Raw:     raw,
+		Value:   math.Round(f*10) / 10,
+		Numeric: err == nil,
+		At:      at,
+	}
+}
@@ -0,0 +1,348 @@
+package export
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/services/retention"
+	"github.com/quanghia24/mySmartHome/services/rollup"
+	"github.com/quanghia24/mySmartHome/types"
+	"github.com/quanghia24/mySmartHome/utils"
+)
+
+type Handler struct {
+	deviceLog   types.LogDeviceStore
+	sensorLog   types.LogSensorStore
+	userStore   types.UserStore
+	roomStore   types.RoomStore
+	deviceStore types.DeviceStore
+	sensorStore types.SensorStore
+	rollups     types.RollupStore
+	retention   retention.Config
+}
+
+func NewHandler(deviceLog types.LogDeviceStore, sensorLog types.LogSensorStore, userStore types.UserStore, roomStore types.RoomStore, deviceStore types.DeviceStore, sensorStore types.SensorStore, rollups types.RollupStore, retention retention.Config) *Handler {
+	return &Handler{
+		deviceLog:   deviceLog,
+		sensorLog:   sensorLog,
+		userStore:   userStore,
+		roomStore:   roomStore,
+		deviceStore: deviceStore,
+		sensorStore: sensorStore,
+		rollups:     rollups,
+		retention:   retention,
+	}
+}
+
+// datasets that can be exported
+const (
+	deviceLogs = "device-logs"
+	sensorData = "sensor-data"
+	statistics = "statistics"
+)
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// GET /export/{dataset}?format=csv|xlsx&start=&end=[&feed_id=|&room_id=]
+	// without a feed or room the whole home is exported
+	router.HandleFunc("/export/{dataset}", auth.WithJWTAuth(h.handleExport, h.userStore)).Methods(http.MethodGet)
+}
+
+// scope is the set of feeds an export covers.
+type scope struct {
+	name    string
+	devices []types.Device
+	sensors []types.Sensor
+}
+
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	dataset := mux.Vars(r)["dataset"]
+	if dataset != deviceLogs && dataset != sensorData && dataset != statistics {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("unknown dataset %s", dataset))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("format must be csv or xlsx"))
+		return
+	}
+
+	u, err := h.userStore.GetUserByID(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	loc := utils.LoadLocation(u.Timezone)
+
+	start, end, err := parseRange(r, loc)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	sc, status, err := h.resolveScope(r, userId)
+	if err != nil {
+		utils.WriteError(w, status, err)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s-%s-%s.%s", dataset, sc.name, utils.DayKey(start, loc), utils.DayKey(end.Add(-time.Nanosecond), loc), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	var out tableWriter
+	if format == "xlsx" {
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		out, err = newXLSXWriter(w, dataset, loc)
+		if err != nil {
+			log.Println("export:", err)
+			return
+		}
+	} else {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		out = newCSVWriter(w, loc)
+	}
+
+	// the status is already sent once rows are streamed, so a failure halfway
+	// can only cut the file short
+	switch dataset {
+	case deviceLogs:
+		err = h.writeDeviceLogs(out, sc, start, end, i18n.FromRequest(r, u.Locale))
+	case sensorData:
+		err = h.writeSensorData(out, sc, start, end)
+	case statistics:
+		err = h.writeStatistics(out, sc, start, end, loc)
+	}
+	if err != nil {
+		log.Printf("export %s for user %d: %v\n", dataset, userId, err)
+		return
+	}
+
+	if err := out.Close(); err != nil {
+		log.Printf("export %s for user %d: %v\n", dataset, userId, err)
+	}
+}
+
+func (h *Handler) writeDeviceLogs(out tableWriter, sc *scope, start, end time.Time, locale string) error {
+	if err := out.Write([]any{"time", "feed_id", "device", "device_type", "log_type", "value", "message"}); err != nil {
+		return err
+	}
+
+	devices := map[int]types.Device{}
+	feedIds := []int{}
+	for _, d := range sc.devices {
+		devices[d.FeedId] = d
+		feedIds = append(feedIds, d.FeedId)
+	}
+
+	return h.deviceLog.StreamLogsByFeedIDsBetween(feedIds, start, end, func(l types.LogDevice) error {
+		d := devices[l.DeviceID]
+		message := i18n.Localize(locale, l.MessageKey, l.Params, l.Message)
+		return out.Write([]any{l.CreatedAt, l.DeviceID, d.Title, d.Type, l.Type, l.Value, message})
+	})
+}
+
+func (h *Handler) writeSensorData(out tableWriter, sc *scope, start, end time.Time) error {
+	if err := out.Write([]any{"time", "feed_id", "sensor", "sensor_type", "value"}); err != nil {
+		return err
+	}
+
+	sensors := map[int]types.Sensor{}
+	feedIds := []int{}
+	for _, s := range sc.sensors {
+		sensors[s.FeedId] = s
+		feedIds = append(feedIds, s.FeedId)
+	}
+
+	return h.sensorLog.StreamSensorsByFeedIDsBetween(feedIds, start, end, func(l types.LogSensor) error {
+		s := sensors[l.SensorID]
+
+		// keep readings numeric so spreadsheets can chart them
+		var value any = l.Value
+		if v, err := strconv.ParseFloat(l.Value, 64); err == nil {
+			value = v
+		}
+		return out.Write([]any{l.CreatedAt, l.SensorID, s.Title, s.Type, value})
+	})
+}
+
+// writeStatistics writes one row per feed and local day: on-hours for
+// devices, min/max/avg for sensors.
+func (h *Handler) writeStatistics(out tableWriter, sc *scope, start, end time.Time, loc *time.Location) error {
+	if err := out.Write([]any{"date", "feed_id", "title", "type", "on_hours", "avg", "min", "max", "readings"}); err != nil {
+		return err
+	}
+
+	days := []string{}
+	for day := range utils.InitDateList(start, end, loc) {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	for _, d := range sc.devices {
+		res := h.retention.DeviceResolution(start, 24*time.Hour, time.Now())
+		usage, err := h.rollups.GetDeviceUsage([]int{d.FeedId}, res, start, end)
+		if err != nil {
+			return err
+		}
+
+		dayHours := rollup.DailyOnHours(usage, loc)
+		for _, day := range days {
+			row := []any{day, d.FeedId, d.Title, d.Type, math.Round(dayHours[day]*1000) / 1000, "", "", "", ""}
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, s := range sc.sensors {
+		res := h.retention.Sensor(s.Type).Resolution(start, 24*time.Hour, time.Now())
+		stats, err := h.rollups.GetSensorStats(s.FeedId, res, start, end)
+		if err != nil {
+			return err
+		}
+
+		dayStats := rollup.DailySensorStats(stats, loc)
+		for _, day := range days {
+			row := []any{day, s.FeedId, s.Title, s.Type, "", "", "", "", 0}
+			if ds, ok := dayStats[day]; ok && ds.Count > 0 {
+				row[5] = math.Round(ds.Avg()*100) / 100
+				row[6] = ds.Min
+				row[7] = ds.Max
+				row[8] = ds.Readings
+			}
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveScope picks the feeds to export from the feed_id or room_id query
+// parameter, or every feed of the user when neither is given.
+func (h *Handler) resolveScope(r *http.Request, userId int) (*scope, int, error) {
+	query := r.URL.Query()
+
+	if feed := query.Get("feed_id"); feed != "" {
+		feedId, err := strconv.Atoi(feed)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid feed_id")
+		}
+
+		if d, err := h.deviceStore.GetDevice(feedId); err == nil && d.UserID == userId {
+			return &scope{name: "feed-" + feed, devices: []types.Device{*d}}, 0, nil
+		}
+		if s, err := h.sensorStore.GetSensor(feedId); err == nil && s.UserID == userId {
+			return &scope{name: "feed-" + feed, sensors: []types.Sensor{*s}}, 0, nil
+		}
+		return nil, http.StatusNotFound, fmt.Errorf("feed %d not found", feedId)
+	}
+
+	if room := query.Get("room_id"); room != "" {
+		roomId, err := strconv.Atoi(room)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid room_id")
+		}
+
+		rm, err := h.roomStore.GetRoomByID(roomId)
+		if err != nil || rm.UserID != userId {
+			return nil, http.StatusNotFound, fmt.Errorf("room %d not found", roomId)
+		}
+
+		sc := &scope{name: "room-" + room}
+		if err := h.addRoom(sc, roomId); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return sc, 0, nil
+	}
+
+	rooms, err := h.roomStore.GetRoomsByUserID(userId)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	sc := &scope{name: "home"}
+	for _, rm := range rooms {
+		if err := h.addRoom(sc, rm.ID); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+	return sc, 0, nil
+}
+
+func (h *Handler) addRoom(sc *scope, roomId int) error {
+	feedIds, err := h.roomStore.GetDevicesByRoomId(roomId)
+	if err != nil {
+		return err
+	}
+	for _, feedId := range feedIds {
+		d, err := h.deviceStore.GetDevice(feedId)
+		if err != nil {
+			return err
+		}
+		sc.devices = append(sc.devices, *d)
+	}
+
+	sensors, err := h.sensorStore.GetSensorsByRoomId(roomId)
+	if err != nil {
+		return err
+	}
+	sc.sensors = append(sc.sensors, sensors...)
+	return nil
+}
+
+// parseRange reads start and end from the query, either as RFC 3339 times or
+// as local days, in which case end is inclusive. It defaults to the last 30
+// days.
+func parseRange(r *http.Request, loc *time.Location) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+	now := time.Now()
+
+	end := utils.NextDayStart(now, loc)
+	if v := query.Get("end"); v != "" {
+		t, day, err := parseTime(v, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end: %v", err)
+		}
+		end = t
+		if day {
+			end = utils.NextDayStart(t, loc)
+		}
+	}
+
+	start := utils.StartOfDay(end.AddDate(0, 0, -30), loc)
+	if v := query.Get("start"); v != "" {
+		t, _, err := parseTime(v, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start: %v", err)
+		}
+		start = t
+	}
+
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start must be before end")
+	}
+	return start, end, nil
+}
+
+// parseTime accepts "2006-01-02" or RFC 3339 and reports whether a plain day
+// was given.
+func parseTime(v string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", v, loc); err == nil {
+		return utils.StartOfDay(t, loc), true, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t, false, err
+}
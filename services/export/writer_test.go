@@ -0,0 +1,103 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVWriter(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Ho_Chi_Minh")
+	rec := httptest.NewRecorder()
+
+	out := newCSVWriter(rec, loc)
+	out.Write([]any{"time", "value", "message"})
+	out.Write([]any{time.Date(2025, 5, 1, 17, 30, 0, 0, time.UTC), 27.5, `turned "on", by schedule`})
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "time,value,message\n2025-05-02 00:30:00,27.5,\"turned \"\"on\"\", by schedule\"\n"
+	if rec.Body.String() != expected {
+		t.Errorf("expected %q, got %q", expected, rec.Body.String())
+	}
+}
+
+func TestCSVWriterEscapesFormulas(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	out := newCSVWriter(rec, time.UTC)
+	out.Write([]any{"=HYPERLINK(\"x\")", "+1", "@SUM(A1)", "\tlamp", "lamp", -2.5})
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "\"'=HYPERLINK(\"\"x\"\")\",'+1,'@SUM(A1),'\tlamp,lamp,-2.5\n"
+	if rec.Body.String() != expected {
+		t.Errorf("expected %q, got %q", expected, rec.Body.String())
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Ho_Chi_Minh")
+	rec := httptest.NewRecorder()
+
+	out, err := newXLSXWriter(rec, "device-logs", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Write([]any{"time", "value", "message"})
+	out.Write([]any{time.Date(2025, 5, 1, 17, 0, 0, 0, time.UTC), 27.5, "<on> & off"})
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("expected a zip archive: %v", err)
+	}
+
+	var sheet string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+
+		// every part must be well formed
+		dec := xml.NewDecoder(bytes.NewReader(body))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not valid xml: %v", f.Name, err)
+			}
+		}
+
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			sheet = string(body)
+		}
+	}
+
+	// 2025-05-02 00:00 local is 45779 days after 1899-12-30
+	for _, want := range []string{`<c r="A2" s="1"><v>45779</v></c>`, `<c r="B2"><v>27.5</v></c>`, `&lt;on&gt; &amp; off`} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("expected sheet to contain %s, got %s", want, sheet)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("expected column %d to be %s, got %s", i, want, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+)
+
+// z-score of the two-sided 95% confidence band
+const z95 = 1.96
+
+// Model is an additive Holt-Winters model: a level, a trend and a seasonal
+// offset for every step of the period, smoothed with alpha, beta and gamma.
+type Model struct {
+	Alpha  float64 `json:"alpha"`
+	Beta   float64 `json:"beta"`
+	Gamma  float64 `json:"gamma"`
+	Period int     `json:"period"`
+
+	level    float64
+	trend    float64
+	seasonal []float64
+	n        int     // length of the fitted series
+	sigma    float64 // standard deviation of the one step errors
+}
+
+// Point is a forecast value with its 95% confidence band.
+type Point struct {
+	Value float64 `json:"value"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// grid the smoothing parameters are picked from
+var grid = []float64{0.01, 0.05, 0.1, 0.2, 0.3, 0.5, 0.7, 0.9}
+
+// Fit picks the smoothing parameters that give the smallest one step error
+// on the series, which needs at least two full periods of evenly spaced
+// values.
+func Fit(series []float64, period int) (*Model, error) {
+	if period < 2 {
+		return nil, fmt.Errorf("period must be at least 2")
+	}
+	if len(series) < 2*period {
+		return nil, fmt.Errorf("need at least %d values, got %d", 2*period, len(series))
+	}
+
+	var best *Model
+	bestSSE := math.Inf(1)
+	for _, alpha := range grid {
+		for _, beta := range grid {
+			for _, gamma := range grid {
+				m := &Model{Alpha: alpha, Beta: beta, Gamma: gamma, Period: period}
+				sse := m.run(series)
+				if sse < bestSSE {
+					best, bestSSE = m, sse
+				}
+			}
+		}
+	}
+
+	best.sigma = math.Sqrt(bestSSE / float64(len(series)-period))
+	return best, nil
+}
+
+// run smooths the series and returns the sum of squared one step errors.
+func (m *Model) run(series []float64) float64 {
+	p := m.Period
+
+	first := mean(series[:p])
+	m.level = first
+	m.trend = (mean(series[p:2*p]) - first) / float64(p)
+	m.seasonal = make([]float64, p)
+	for i := 0; i < p; i++ {
+		m.seasonal[i] = series[i] - first
+	}
+
+	sse := 0.0
+	for t := p; t < len(series); t++ {
+		s := m.seasonal[t%p]
+		e := series[t] - (m.level + m.trend + s)
+		sse += e * e
+
+		level := m.Alpha*(series[t]-s) + (1-m.Alpha)*(m.level+m.trend)
+		m.trend = m.Beta*(level-m.level) + (1-m.Beta)*m.trend
+		m.seasonal[t%p] = m.Gamma*(series[t]-level) + (1-m.Gamma)*s
+		m.level = level
+	}
+	m.n = len(series)
+	return sse
+}
+
+// Forecast predicts the next steps after the fitted series. The band widens
+// with the horizon as the errors of the level, trend and season add up.
+func (m *Model) Forecast(steps int) []Point {
+	points := make([]Point, steps)
+
+	variance := 0.0
+	for h := 1; h <= steps; h++ {
+		if h > 1 {
+			j := float64(h - 1)
+			c := m.Alpha * (1 + j*m.Beta)
+			if (h-1)%m.Period == 0 {
+				c += m.Gamma
+			}
+			variance += c * c
+		}
+
+		value := m.level + float64(h)*m.trend + m.seasonal[(m.n+h-1)%m.Period]
+		band := z95 * m.sigma * math.Sqrt(1+variance)
+		points[h-1] = Point{Value: value, Lower: value - band, Upper: value + band}
+	}
+	return points
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
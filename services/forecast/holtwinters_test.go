@@ -0,0 +1,91 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+func daily(hours int, noise func(int) float64) []float64 {
+	series := make([]float64, hours)
+	for i := range series {
+		series[i] = 28 + 4*math.Sin(float64(i%24)/24*2*math.Pi) + noise(i)
+	}
+	return series
+}
+
+func TestForecastFollowsSeason(t *testing.T) {
+	series := daily(14*24, func(int) float64 { return 0 })
+
+	m, err := Fit(series, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := daily(14*24+6, func(int) float64 { return 0 })[14*24:]
+	for i, p := range m.Forecast(6) {
+		if math.Abs(p.Value-expected[i]) > 0.1 {
+			t.Errorf("step %d: expected %.2f, got %.2f", i+1, expected[i], p.Value)
+		}
+	}
+}
+
+func TestForecastBandsWiden(t *testing.T) {
+	// deterministic noise of +-0.5
+	series := daily(14*24, func(i int) float64 { return 0.5 * math.Sin(float64(i*i)) })
+
+	m, err := Fit(series, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	points := m.Forecast(12)
+	for i, p := range points {
+		if !(p.Lower < p.Value && p.Value < p.Upper) {
+			t.Fatalf("step %d: expected the value inside its band, got %+v", i+1, p)
+		}
+		if i > 0 && p.Upper-p.Lower < points[i-1].Upper-points[i-1].Lower {
+			t.Errorf("step %d: expected the band to widen with the horizon", i+1)
+		}
+	}
+}
+
+func TestFitNeedsTwoSeasons(t *testing.T) {
+	if _, err := Fit(make([]float64, 47), 24); err == nil {
+		t.Errorf("expected an error for less than two days of history")
+	}
+}
+
+func TestHourlySeries(t *testing.T) {
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	stat := func(hour int, avg float64) types.SensorStats {
+		return types.SensorStats{Start: start.Add(time.Duration(hour) * time.Hour), Sum: avg * 4, Count: 4}
+	}
+
+	// nothing in the first hour, a gap at 3 and 4, and the last hour missing
+	stats := []types.SensorStats{stat(1, 10), stat(2, 12), stat(5, 18), stat(6, 20)}
+	series, first, err := HourlySeries(stats, start, start.Add(8*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !first.Equal(start.Add(time.Hour)) {
+		t.Errorf("expected the series to start at the first reading, got %v", first)
+	}
+	expected := []float64{10, 12, 14, 16, 18, 20, 20}
+	if len(series) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, series)
+	}
+	for i := range expected {
+		if math.Abs(series[i]-expected[i]) > 1e-9 {
+			t.Errorf("expected %v, got %v", expected, series)
+			break
+		}
+	}
+
+	if _, _, err := HourlySeries(stats, start, start.Add(20*time.Hour)); err == nil {
+		t.Errorf("expected an error when the readings stopped long ago")
+	}
+}
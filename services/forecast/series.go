@@ -0,0 +1,71 @@
+package forecast
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// the most missing hours at the end of the history that are bridged with the
+// last known value
+const maxStaleHours = 6
+
+// HourlySeries turns hourly sensor stats into one average per hour from
+// start up to end. Hours without readings are interpolated between their
+// neighbours. The series starts at the first hour with readings, which is
+// returned as well.
+func HourlySeries(stats []types.SensorStats, start, end time.Time) ([]float64, time.Time, error) {
+	start = start.UTC().Truncate(time.Hour)
+	end = end.UTC().Truncate(time.Hour)
+
+	byHour := map[time.Time]float64{}
+	for _, st := range stats {
+		if st.Count > 0 {
+			byHour[st.Start.UTC().Truncate(time.Hour)] = st.Sum / float64(st.Count)
+		}
+	}
+
+	for start.Before(end) {
+		if _, ok := byHour[start]; ok {
+			break
+		}
+		start = start.Add(time.Hour)
+	}
+	if !start.Before(end) {
+		return nil, start, fmt.Errorf("no readings to forecast from")
+	}
+
+	series := []float64{}
+	known := []bool{}
+	for t := start; t.Before(end); t = t.Add(time.Hour) {
+		v, ok := byHour[t]
+		series = append(series, v)
+		known = append(known, ok)
+	}
+
+	last := len(series) - 1
+	for last >= 0 && !known[last] {
+		last--
+	}
+	if len(series)-1-last > maxStaleHours {
+		return nil, start, fmt.Errorf("no readings in the last %d hours", maxStaleHours)
+	}
+
+	prev := 0
+	for i := 1; i < len(series); i++ {
+		if !known[i] {
+			continue
+		}
+		// interpolate the gap since the previous reading
+		for j := prev + 1; j < i; j++ {
+			series[j] = series[prev] + (series[i]-series[prev])*float64(j-prev)/float64(i-prev)
+		}
+		prev = i
+	}
+	for j := prev + 1; j < len(series); j++ {
+		series[j] = series[prev]
+	}
+
+	return series, start, nil
+}
@@ -0,0 +1,148 @@
+package household
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/types"
+	"github.com/quanghia24/mySmartHome/utils"
+)
+
+type Handler struct {
+	store     types.HouseholdStore
+	userStore types.UserStore
+}
+
+func NewHandler(store types.HouseholdStore, userStore types.UserStore) *Handler {
+	return &Handler{
+		store:     store,
+		userStore: userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/household/members", auth.WithJWTAuth(h.getMembers, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/household/members", auth.WithJWTAuth(h.addMember, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/household/members/{member_id}", auth.WithJWTAuth(h.removeMember, h.userStore)).Methods(http.MethodDelete)
+	router.HandleFunc("/household/memberships", auth.WithJWTAuth(h.getMemberships, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/household/memberships/{owner_id}/accept", auth.WithJWTAuth(h.acceptInvite, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/household/memberships/{owner_id}", auth.WithJWTAuth(h.leaveHousehold, h.userStore)).Methods(http.MethodDelete)
+}
+
+func (h *Handler) getMembers(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+
+	members, err := h.store.GetMembers(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, members)
+}
+
+// addMember invites another user to share the user's home, they receive the
+// alerts nobody acknowledged once they accept. The response is the same
+// whether or not the email has an account, so it can't be used to find out.
+func (h *Handler) addMember(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+
+	var payload types.AddMemberPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	member, err := h.userStore.GetUserByEmail(payload.Email)
+	if err == nil && member.ID == userId {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("you are already in your household"))
+		return
+	}
+	if err == nil {
+		if err := h.store.InviteMember(userId, member.ID); err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, fmt.Sprintf("invite sent to %s if it has an account", payload.Email))
+}
+
+func (h *Handler) removeMember(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	memberId, err := strconv.Atoi(mux.Vars(r)["member_id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid member_id"))
+		return
+	}
+
+	if err := h.store.RemoveMember(userId, memberId); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, fmt.Sprintf("member %d removed", memberId))
+}
+
+// getMemberships lists the homes the user was invited to, with the invites
+// yet to be accepted.
+func (h *Handler) getMemberships(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+
+	memberships, err := h.store.GetMemberships(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, memberships)
+}
+
+func (h *Handler) acceptInvite(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	ownerId, err := strconv.Atoi(mux.Vars(r)["owner_id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid owner_id"))
+		return
+	}
+
+	accepted, err := h.store.AcceptInvite(ownerId, userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !accepted {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("no pending invite from user %d", ownerId))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, fmt.Sprintf("joined the household of user %d", ownerId))
+}
+
+// leaveHousehold leaves a home the user is a member of, or declines the
+// invite to it.
+func (h *Handler) leaveHousehold(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	ownerId, err := strconv.Atoi(mux.Vars(r)["owner_id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid owner_id"))
+		return
+	}
+
+	if err := h.store.RemoveMember(ownerId, userId); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, fmt.Sprintf("left the household of user %d", ownerId))
+}
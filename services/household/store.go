@@ -0,0 +1,103 @@
+package household
+
+import (
+	"database/sql"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// InviteMember records an invite to the owner's home, the user becomes a
+// member once they accept it.
+func (s *Store) InviteMember(ownerId int, memberId int) error {
+	_, err := s.db.Exec("INSERT IGNORE INTO household_members (ownerId, memberId) VALUES (?, ?)", ownerId, memberId)
+	return err
+}
+
+// AcceptInvite reports whether there was a pending invite to accept.
+func (s *Store) AcceptInvite(ownerId int, memberId int) (bool, error) {
+	res, err := s.db.Exec("UPDATE household_members SET acceptedAt = CURRENT_TIMESTAMP WHERE ownerId = ? AND memberId = ? AND acceptedAt IS NULL", ownerId, memberId)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *Store) RemoveMember(ownerId int, memberId int) error {
+	_, err := s.db.Exec("DELETE FROM household_members WHERE ownerId = ? AND memberId = ?", ownerId, memberId)
+	return err
+}
+
+func (s *Store) IsMember(ownerId int, memberId int) (bool, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM household_members WHERE ownerId = ? AND memberId = ? AND acceptedAt IS NOT NULL", ownerId, memberId).Scan(&n)
+	return n > 0, err
+}
+
+// GetMembers lists the users sharing the owner's home, without the owner and
+// the ones yet to accept their invite.
+func (s *Store) GetMembers(ownerId int) ([]types.User, error) {
+	rows, err := s.db.Query(`
+		SELECT u.id, u.firstName, u.lastName, u.email, u.avatar, u.timezone, u.createdAt
+		FROM household_members m
+		JOIN users u ON u.id = m.memberId
+		WHERE m.ownerId = ? AND m.acceptedAt IS NOT NULL
+		ORDER BY m.createdAt
+	`, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []types.User{}
+	for rows.Next() {
+		var u types.User
+		err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Avatar, &u.Timezone, &u.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, u)
+	}
+	return members, rows.Err()
+}
+
+// GetMemberships lists the homes the user was invited to, accepted or not.
+func (s *Store) GetMemberships(memberId int) ([]types.Membership, error) {
+	rows, err := s.db.Query(`
+		SELECT u.id, u.firstName, u.lastName, u.email, u.avatar, u.timezone, u.createdAt, m.createdAt, m.acceptedAt
+		FROM household_members m
+		JOIN users u ON u.id = m.ownerId
+		WHERE m.memberId = ?
+		ORDER BY m.createdAt
+	`, memberId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []types.Membership{}
+	for rows.Next() {
+		var m types.Membership
+		var acceptedAt sql.NullTime
+		u := &m.Owner
+		err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Avatar, &u.Timezone, &u.CreatedAt, &m.InvitedAt, &acceptedAt)
+		if err != nil {
+			return nil, err
+		}
+		if acceptedAt.Valid {
+			m.AcceptedAt = &acceptedAt.Time
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
@@ -0,0 +1,67 @@
+package i18n
+
+// catalog holds the templates of the messages by key and locale.
+var catalog = map[string]map[string]string{
+	// names used in other messages
+	"sensor.temperature":       {"vi": "nhiệt độ", "en": "temperature"},
+	"sensor.humidity":          {"vi": "độ ẩm", "en": "humidity"},
+	"sensor.brightness":        {"vi": "ánh sáng", "en": "brightness"},
+	"sensor.heat_index":        {"vi": "nhiệt độ cảm nhận", "en": "heat index"},
+	"sensor.dew_point":         {"vi": "điểm sương", "en": "dew point"},
+	"sensor.absolute_humidity": {"vi": "độ ẩm tuyệt đối", "en": "absolute humidity"},
+	"sensor.comfort":           {"vi": "mức dễ chịu", "en": "comfort"},
+	"sensor.motion":            {"vi": "chuyển động", "en": "motion"},
+	"sensor.co2":               {"vi": "CO2", "en": "CO2"},
+	"device.fan":               {"vi": "quạt", "en": "fan"},
+	"device.light":             {"vi": "đèn", "en": "light"},
+	"device.door":              {"vi": "cửa", "en": "door"},
+	"device.plug":              {"vi": "ổ cắm thông minh", "en": "smart plug"},
+	"device.curtain":           {"vi": "rèm", "en": "curtain"},
+	"device.ac":                {"vi": "máy lạnh", "en": "air conditioner"},
+	"bound.upper":              {"vi": "trên", "en": "upper"},
+	"bound.lower":              {"vi": "dưới", "en": "lower"},
+
+	// device logs
+	"log.device.added":            {"vi": "[{title}] đã được thêm", "en": "[{title}] got added"},
+	"log.device.door_opened":      {"vi": "[{title}] đã mở", "en": "[{title}] got opened"},
+	"log.device.door_closed":      {"vi": "[{title}] đã đóng", "en": "[{title}] got closed"},
+	"log.device.fan_level":        {"vi": "[{title}] được đặt ở mức: {value}", "en": "[{title}]'s set at level: {value}"},
+	"log.device.speed":            {"vi": "[{title}] được đặt tốc độ {value}%", "en": "[{title}]'s speed set at {value}%"},
+	"log.device.light_color":      {"vi": "[{title}] được đặt màu: {value}", "en": "[{title}]'s set color: {value}"},
+	"log.device.switched_on":      {"vi": "[{title}] đã bật", "en": "[{title}] got switched on"},
+	"log.device.switched_off":     {"vi": "[{title}] đã tắt", "en": "[{title}] got switched off"},
+	"log.device.curtain_position": {"vi": "[{title}] được mở {value}%", "en": "[{title}]'s opened to {value}%"},
+	"log.device.ac_target":        {"vi": "[{title}] được đặt ở {value}°C", "en": "[{title}]'s set at {value}°C"},
+
+	// sensor logs
+	"log.sensor.added":     {"vi": "[{title}] đã được thêm", "en": "[{title}] got added"},
+	"log.sensor.data":      {"vi": "Đã ghi nhận dữ liệu {value}", "en": "{value} data recorded"},
+	"log.sensor.upper":     {"vi": "{value} vượt ngưỡng trên {threshold}", "en": "{value} exceed the {threshold} upper bound"},
+	"log.sensor.lower":     {"vi": "{value} thấp hơn ngưỡng dưới {threshold}", "en": "{value} below the {threshold} lower bound"},
+	"log.sensor.cleared":   {"vi": "{value} đã trở lại trong ngưỡng {bound:bound} {threshold}", "en": "{value} back within the {threshold} {bound:bound} bound"},
+	"log.sensor.anomalous": {"vi": "Giá trị bất thường {value}, thường là {expected} ± {stddev}", "en": "unusual reading {value}, expected {expected} ± {stddev}"},
+
+	// notifications
+	"noti.threshold.title": {"vi": "Vượt ngưỡng cảm biến {type:sensor}", "en": "{type:sensor} sensor out of range"},
+	"noti.threshold.upper": {"vi": "Đo được {value}, vượt ngưỡng trên cho phép là {threshold}", "en": "Measured {value}, above the upper bound of {threshold}"},
+	"noti.threshold.lower": {"vi": "Đo được {value}, thấp hơn ngưỡng dưới cho phép là {threshold}", "en": "Measured {value}, below the lower bound of {threshold}"},
+	"noti.cleared.title":   {"vi": "Hết vượt ngưỡng cảm biến {type:sensor}", "en": "{type:sensor} sensor back in range"},
+	"noti.cleared.body":    {"vi": "Đo được {value}, đã trở lại trong ngưỡng cho phép", "en": "Measured {value}, back within range"},
+	"noti.anomaly.title":   {"vi": "Giá trị bất thường từ cảm biến {type:sensor}", "en": "Unusual {type:sensor} reading"},
+	"noti.anomaly.body":    {"vi": "Đo được {value}, khác thường so với mức {expected} hay gặp vào giờ này", "en": "Measured {value}, unlike the usual {expected} at this hour"},
+	"noti.alert.body":      {"vi": "[{source}] {message}", "en": "[{source}] {message}"},
+	"noti.resent.title":    {"vi": "Cảnh báo chưa được xác nhận", "en": "Unacknowledged alert"},
+	"noti.members.title":   {"vi": "Cảnh báo tại nhà của {owner}", "en": "Alert at {owner}'s home"},
+	"noti.escalated.title": {"vi": "[{severity}] Cảnh báo chưa được xác nhận: {source}", "en": "[{severity}] Unacknowledged alert: {source}"},
+	"noti.escalated.body":  {"vi": "{message}\n\nMở lúc {openedAt} và chưa được xác nhận. Hãy mở ứng dụng để xác nhận.\n", "en": "{message}\n\nOpened at {openedAt} and not acknowledged yet. Open the app to acknowledge it.\n"},
+	"noti.digest.title":    {"vi": "{count} thông báo trong giờ yên tĩnh", "en": "{count} notifications during quiet hours"},
+	"noti.digest.more":     {"vi": "... và {count} thông báo khác", "en": "... and {count} more"},
+	"noti.door.title":      {"vi": "Cửa đã được mở khóa", "en": "Door unlocked"},
+	"noti.door.body":       {"vi": "[{title}] vừa được mở khóa bằng mật khẩu", "en": "[{title}] got unlocked with its password"},
+	"noti.schedule.title":  {"vi": "Lịch hẹn không chạy được", "en": "Scheduled run failed"},
+	"noti.schedule.body":   {"vi": "Không thể thực hiện {action} lúc {time}: {error}", "en": "Couldn't apply {action} at {time}: {error}"},
+	"noti.order.title":     {"vi": "Đã đặt hàng", "en": "Order placed"},
+	"noti.order.body":      {"vi": "Đơn hàng #{orderId} trị giá {total} đang chờ xử lý", "en": "Order #{orderId} of {total} is pending"},
+	"noti.test.title":      {"vi": "Thông báo thử", "en": "Test notification"},
+	"noti.test.body":       {"vi": "Kênh thông báo này đã được thiết lập thành công.", "en": "This notification channel is set up."},
+}
@@ -0,0 +1,122 @@
+package i18n
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const DefaultLocale = "vi"
+
+// Locales the catalog is written in.
+var Locales = []string{"vi", "en"}
+
+// Params fill the placeholders of a template.
+type Params map[string]string
+
+func IsLocale(locale string) bool {
+	for _, l := range Locales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// Render fills the template of the key in the locale, falling back to the
+// default locale and then to the key itself. A placeholder is {name}, or
+// {name:prefix} for a param that is itself the key prefix.name, like the
+// name of a sensor type.
+func Render(locale string, key string, params Params) string {
+	return fill(locale, lookup(locale, key), params)
+}
+
+func lookup(locale string, key string) string {
+	translations, ok := catalog[key]
+	if !ok {
+		return key
+	}
+	if t, ok := translations[locale]; ok {
+		return t
+	}
+	return translations[DefaultLocale]
+}
+
+func fill(locale string, template string, params Params) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		b.WriteString(template[:start])
+		name, prefix, nested := strings.Cut(template[start+1:end], ":")
+		value, ok := params[name]
+		switch {
+		case !ok:
+			b.WriteString(template[start : end+1])
+		case nested:
+			b.WriteString(lookup(locale, prefix+"."+value))
+		default:
+			b.WriteString(value)
+		}
+		template = template[end+1:]
+	}
+	b.WriteString(template)
+	return b.String()
+}
+
+// Localize renders the message of a log or a notification for the reader:
+// its template when it has one, or the text it was stored with.
+func Localize(locale string, key string, params Params, text string) string {
+	if key == "" {
+		return text
+	}
+	return Render(locale, key, params)
+}
+
+// FromRequest picks the locale of the reader: the user's own, or the first
+// known one of the Accept-Language header.
+func FromRequest(r *http.Request, userLocale string) string {
+	if IsLocale(userLocale) {
+		return userLocale
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(tag, "-")
+		if l := strings.ToLower(lang); IsLocale(l) {
+			return l
+		}
+	}
+	return DefaultLocale
+}
+
+// Value stores the params as JSON, no params as NULL.
+func (p Params) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(p)
+	return string(b), err
+}
+
+func (p *Params) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	}
+	return fmt.Errorf("cannot scan %T into params", src)
+}
@@ -0,0 +1,54 @@
+package i18n
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	params := Params{"type": "temperature", "value": "36.5", "threshold": "35"}
+
+	if got := Render("en", "noti.threshold.title", params); got != "temperature sensor out of range" {
+		t.Errorf("unexpected english title %q", got)
+	}
+	if got := Render("vi", "noti.threshold.title", params); got != "Vượt ngưỡng cảm biến nhiệt độ" {
+		t.Errorf("unexpected vietnamese title %q", got)
+	}
+	if got := Render("fr", "noti.threshold.upper", params); got != "Đo được 36.5, vượt ngưỡng trên cho phép là 35" {
+		t.Errorf("expected the default locale, got %q", got)
+	}
+	if got := Render("en", "log.sensor.cleared", Params{"value": "30", "threshold": "35", "bound": "upper"}); got != "30 back within the 35 upper bound" {
+		t.Errorf("unexpected nested param %q", got)
+	}
+	if got := Render("en", "log.device.fan_level", Params{"title": "Fan"}); got != "[Fan]'s set at level: {value}" {
+		t.Errorf("expected a missing param to stay, got %q", got)
+	}
+	if got := Render("en", "no.such.key", nil); got != "no.such.key" {
+		t.Errorf("expected the key, got %q", got)
+	}
+}
+
+func TestCatalogIsComplete(t *testing.T) {
+	for key, translations := range catalog {
+		for _, locale := range Locales {
+			if translations[locale] == "" {
+				t.Errorf("%s has no %s translation", key, locale)
+			}
+		}
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "fr-FR, en-US;q=0.8")
+
+	if got := FromRequest(r, "vi"); got != "vi" {
+		t.Errorf("expected the user's locale, got %q", got)
+	}
+	if got := FromRequest(r, ""); got != "en" {
+		t.Errorf("expected the header's locale, got %q", got)
+	}
+	if got := FromRequest(httptest.NewRequest("GET", "/", nil), ""); got != DefaultLocale {
+		t.Errorf("expected the default locale, got %q", got)
+	}
+}
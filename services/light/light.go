@@ -0,0 +1,185 @@
+package light
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// bounds of the color temperatures, in kelvin
+const (
+	MinColorTemp = 2000
+	MaxColorTemp = 6500
+)
+
+const (
+	// Adafruit IO takes about 30 values a minute on a feed
+	minStep  = 2 * time.Second
+	maxSteps = 30
+)
+
+// Off is the feed value of a light turned off.
+const Off = "#000000"
+
+// State is what a light shows. The feed only takes a color, so the state is
+// folded into one on the way out and unfolded on the way back: Color is the
+// color at full brightness, replaced by the color of ColorTemp when set.
+type State struct {
+	On         bool   `json:"on"`
+	Brightness int    `json:"brightness"` // 1 to 100 %
+	Color      string `json:"color,omitempty"`
+	ColorTemp  int    `json:"colorTemp,omitempty"`
+}
+
+// Hex is the feed value of the state.
+func (s State) Hex() string {
+	if !s.On || s.Brightness <= 0 {
+		return Off
+	}
+
+	var r, g, b float64 = 255, 255, 255
+	if s.ColorTemp > 0 {
+		r, g, b = kelvinToRGB(s.ColorTemp)
+	} else if s.Color != "" {
+		if cr, cg, cb, err := parseHex(s.Color); err == nil {
+			r, g, b = cr, cg, cb
+		}
+	}
+
+	scale := float64(min(s.Brightness, 100)) / 100
+	return formatHex(r*scale, g*scale, b*scale)
+}
+
+// Load is the share of its full power the light draws.
+func (s State) Load() float64 {
+	if !s.On {
+		return 0
+	}
+	return float64(min(s.Brightness, 100)) / 100
+}
+
+// FromHex unfolds a feed value: the brightest channel gives the brightness,
+// the color is scaled back to full brightness.
+func FromHex(hex string) (State, error) {
+	r, g, b, err := parseHex(hex)
+	if err != nil {
+		return State{}, err
+	}
+
+	m := math.Max(r, math.Max(g, b))
+	if m == 0 {
+		return State{}, nil
+	}
+
+	scale := 255 / m
+	return State{
+		On:         true,
+		Brightness: max(1, int(math.Round(m/255*100))),
+		Color:      formatHex(r*scale, g*scale, b*scale),
+	}, nil
+}
+
+// LoadOf is the share of its full power a light draws at the feed value,
+// full for a value that isn't a color.
+func LoadOf(hex string) float64 {
+	s, err := FromHex(hex)
+	if err != nil {
+		return 1
+	}
+	return s.Load()
+}
+
+// Step is a feed value to post some time after a transition started.
+type Step struct {
+	After time.Duration
+	Value string
+}
+
+// Transition fades the light from one state to the other over d, in as few
+// steps as the feed takes. The last step is the target state.
+func Transition(from State, to State, d time.Duration) []Step {
+	target := to.Hex()
+	if d <= 0 {
+		return []Step{{Value: target}}
+	}
+
+	n := min(maxSteps, int(d/minStep))
+	if n < 1 {
+		n = 1
+	}
+
+	fr, fg, fb, _ := parseHex(from.Hex())
+	tr, tg, tb, _ := parseHex(target)
+
+	steps := []Step{}
+	for i := 1; i <= n; i++ {
+		k := float64(i) / float64(n)
+		value := formatHex(fr+(tr-fr)*k, fg+(tg-fg)*k, fb+(tb-fb)*k)
+		if i == n {
+			value = target
+		}
+		steps = append(steps, Step{After: d * time.Duration(i) / time.Duration(n), Value: value})
+	}
+	return steps
+}
+
+// Effect is a preset state, reached over its transition unless the command
+// asks for another one. With From set the light starts over from it.
+type Effect struct {
+	From       *State
+	To         State
+	Transition time.Duration
+}
+
+// Effects are the presets a light can be set to by name.
+var Effects = map[string]Effect{
+	"reading": {To: State{On: true, Brightness: 100, ColorTemp: 4500}, Transition: 2 * time.Second},
+	"relax":   {To: State{On: true, Brightness: 50, ColorTemp: 2700}, Transition: 4 * time.Second},
+	"night":   {To: State{On: true, Brightness: 5, ColorTemp: 2200}, Transition: 4 * time.Second},
+	"sunrise": {
+		From:       &State{On: true, Brightness: 1, ColorTemp: 2000},
+		To:         State{On: true, Brightness: 100, ColorTemp: 5000},
+		Transition: 15 * time.Minute,
+	},
+	"sunset": {To: State{}, Transition: 15 * time.Minute},
+}
+
+func parseHex(hex string) (float64, float64, float64, error) {
+	if len(hex) != 7 || hex[0] != '#' {
+		return 0, 0, 0, fmt.Errorf("invalid color %q", hex)
+	}
+	v, err := strconv.ParseUint(hex[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid color %q", hex)
+	}
+	return float64(v >> 16 & 0xFF), float64(v >> 8 & 0xFF), float64(v & 0xFF), nil
+}
+
+func formatHex(r, g, b float64) string {
+	return fmt.Sprintf("#%02X%02X%02X", channel(r), channel(g), channel(b))
+}
+
+func channel(v float64) int {
+	return int(math.Round(math.Min(math.Max(v, 0), 255)))
+}
+
+// kelvinToRGB approximates the color of a black body at the temperature,
+// after Tanner Helland's fit of the CIE tables.
+func kelvinToRGB(kelvin int) (float64, float64, float64) {
+	t := float64(min(max(kelvin, MinColorTemp), MaxColorTemp)) / 100
+
+	r, g, b := 255.0, 0.0, 255.0
+	if t > 66 {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	} else {
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+		if t <= 19 {
+			b = 0
+		} else {
+			b = 138.5177312231*math.Log(t-10) - 305.0447927307
+		}
+	}
+	return math.Min(math.Max(r, 0), 255), math.Min(math.Max(g, 0), 255), math.Min(math.Max(b, 0), 255)
+}
@@ -0,0 +1,85 @@
+package light
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHex(t *testing.T) {
+	cases := []struct {
+		state State
+		want  string
+	}{
+		{State{}, Off},
+		{State{On: true, Brightness: 100}, "#FFFFFF"},
+		{State{On: true, Brightness: 50, Color: "#FF8000"}, "#804000"},
+		{State{On: true, Brightness: 1}, "#030303"},
+		{State{On: true, Brightness: 100, ColorTemp: 6500}, "#FFFEFA"},
+	}
+	for _, c := range cases {
+		if got := c.state.Hex(); got != c.want {
+			t.Errorf("%+v: got %s, want %s", c.state, got, c.want)
+		}
+	}
+
+	// a warm white is redder than a cool one
+	warm, _ := FromHex(State{On: true, Brightness: 100, ColorTemp: 2200}.Hex())
+	if warm.Color[1:3] != "FF" || warm.Color[5:7] >= "80" {
+		t.Errorf("2200K: got %s", warm.Color)
+	}
+}
+
+func TestFromHex(t *testing.T) {
+	s, err := FromHex("#804000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.On || s.Brightness != 50 || s.Color != "#FF8000" {
+		t.Errorf("got %+v", s)
+	}
+
+	if s, _ := FromHex(Off); s.On {
+		t.Errorf("off: got %+v", s)
+	}
+	if _, err := FromHex("white"); err == nil {
+		t.Error("expected an error for a value that isn't a color")
+	}
+}
+
+func TestLoadOf(t *testing.T) {
+	cases := map[string]float64{
+		Off:       0,
+		"#FFFFFF": 1,
+		"#0D0D0D": 0.05,
+		"1":       1,
+	}
+	for value, want := range cases {
+		if got := LoadOf(value); got != want {
+			t.Errorf("%s: got %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestTransition(t *testing.T) {
+	from := State{}
+	to := State{On: true, Brightness: 100}
+
+	if steps := Transition(from, to, 0); len(steps) != 1 || steps[0].Value != "#FFFFFF" || steps[0].After != 0 {
+		t.Errorf("instant: got %+v", steps)
+	}
+
+	steps := Transition(from, to, 10*time.Second)
+	if len(steps) != 5 {
+		t.Fatalf("got %d steps, want 5", len(steps))
+	}
+	if steps[0].After != 2*time.Second || steps[0].Value != "#333333" {
+		t.Errorf("first step: got %+v", steps[0])
+	}
+	if last := steps[len(steps)-1]; last.After != 10*time.Second || last.Value != "#FFFFFF" {
+		t.Errorf("last step: got %+v", last)
+	}
+
+	if steps := Transition(from, to, time.Hour); len(steps) != maxSteps {
+		t.Errorf("got %d steps, want at most %d", len(steps), maxSteps)
+	}
+}
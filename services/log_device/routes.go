@@ -2,25 +2,29 @@ package log_device
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/services/i18n"
 	"github.com/quanghia24/mySmartHome/types"
 	"github.com/quanghia24/mySmartHome/utils"
 )
 
 type Handler struct {
-	store     types.LogDeviceStore
-	userStore types.UserStore
+	store       types.LogDeviceStore
+	userStore   types.UserStore
+	deviceStore types.DeviceStore
 }
 
-func NewHandler(store types.LogDeviceStore, userStore types.UserStore) *Handler {
+func NewHandler(store types.LogDeviceStore, userStore types.UserStore, deviceStore types.DeviceStore) *Handler {
 	return &Handler{
-		store:     store,
-		userStore: userStore,
+		store:       store,
+		userStore:   userStore,
+		deviceStore: deviceStore,
 	}
 }
 
@@ -77,7 +81,7 @@ func (h *Handler) getDeviceUsage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		details = calculateDailyOnTime(logs)
+		details = calculateDailyOnTime(logs, h.deviceLocation(feedId))
 
 		utils.WriteJSON(w, http.StatusOK, details)
 
@@ -93,7 +97,7 @@ func (h *Handler) getDeviceUsage(w http.ResponseWriter, r *http.Request) {
 
 
 		fmt.Println(details)
-		utils.WriteJSON(w, http.StatusOK, logs)
+		utils.WriteJSON(w, http.StatusOK, Localize(logs, i18n.FromRequest(r, "")))
 	} else {
 		fmt.Println("get interval")
 		logs, err = h.store.GetLogsByFeedIDBetween(feedId, payload.Start, payload.End)
@@ -102,14 +106,14 @@ func (h *Handler) getDeviceUsage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		fmt.Println(details)
-		utils.WriteJSON(w, http.StatusOK, logs)
+		utils.WriteJSON(w, http.StatusOK, Localize(logs, i18n.FromRequest(r, "")))
 	}
 }
 
 func (h *Handler) getAllDeviceBelongToID(w http.ResponseWriter, r *http.Request) {
 	userId := auth.GetUserIDFromContext(r.Context())
 
-	_, err := h.userStore.GetUserByID(userId)
+	u, err := h.userStore.GetUserByID(userId)
 	if err != nil {
 		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("requested user doesn't exists"))
 		return
@@ -121,17 +125,37 @@ func (h *Handler) getAllDeviceBelongToID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, logs)
+	utils.WriteJSON(w, http.StatusOK, Localize(logs, i18n.FromRequest(r, u.Locale)))
 }
 
-func calculateDailyOnTime(logs []types.LogDevice) []types.TimeObject {
+// Localize renders the messages of the logs in the locale.
+func Localize(logs []types.LogDevice, locale string) []types.LogDevice {
+	for i, l := range logs {
+		logs[i].Message = i18n.Localize(locale, l.MessageKey, l.Params, l.Message)
+	}
+	return logs
+}
+
+// deviceLocation returns the timezone of the household owning the device.
+func (h *Handler) deviceLocation(feedId int) *time.Location {
+	device, err := h.deviceStore.GetDevice(feedId)
+	if err != nil {
+		return utils.LoadLocation("")
+	}
+	u, err := h.userStore.GetUserByID(device.UserID)
+	if err != nil {
+		return utils.LoadLocation("")
+	}
+	return utils.LoadLocation(u.Timezone)
+}
+
+func calculateDailyOnTime(logs []types.LogDevice, loc *time.Location) []types.TimeObject {
 	if len(logs) == 0 {
 		return nil
 	}
 
-
-	// Grouping by date: map[YYYY-MM-DD] -> onTimeMinutes
-	onTimePerDay := make(map[string]int)
+	// Grouping by local date: map[YYYY-MM-DD] -> onTimeMinutes
+	onTimePerDay := make(map[string]float64)
 
 	// last seen ON timestamp (the log before an OFF)
 	var prevTime *time.Time
@@ -150,14 +174,9 @@ func calculateDailyOnTime(logs []types.LogDevice) []types.TimeObject {
 			}
 		} else { // device is OFF
 			if isOn && prevTime != nil {
-				// track usage from prevTime to now
-				duration := -prevTime.Sub(createdAt)
-				minutes := int(duration.Minutes())
-
-				if minutes > 0 {
-					// assign the ON time to the day of the START TIME (when ON began)
-					day := prevTime.Format("2006-01-02")
-					onTimePerDay[day] += minutes
+				// track usage from prevTime to now, split at local midnight
+				for day, hours := range utils.SplitDurationByDay(*prevTime, createdAt, loc) {
+					onTimePerDay[day] += hours * 60
 				}
 			}
 			isOn = false
@@ -170,25 +189,20 @@ func calculateDailyOnTime(logs []types.LogDevice) []types.TimeObject {
 	if isOn && prevTime != nil {
 		// use the oldest log's timestamp as the OFF time
 		lastLog := logs[len(logs)-1].CreatedAt
-		duration := prevTime.Sub(lastLog)
-		minutes := int(duration.Minutes())
-
-		if minutes > 0 {
-			day := prevTime.Format("2006-01-02")
-			onTimePerDay[day] += minutes
+		for day, hours := range utils.SplitDurationByDay(*prevTime, lastLog, loc) {
+			onTimePerDay[day] += hours * 60
 		}
 	}
 
 	// Create result for the last 7 days (based on latest log's day)
-	latest := logs[len(logs)-1].CreatedAt.In(time.FixedZone("UTC+7", 7*60*60))
-	startOfToday := time.Date(latest.Year(), latest.Month(), latest.Day(), 0, 0, 0, 0, latest.Location())
-
+	latest := logs[len(logs)-1].CreatedAt.In(loc)
 
 	results := []types.TimeObject{}
 	for i := 6; i >= 0; i-- {
-		date := startOfToday.AddDate(0, 0, -i)
-		key := date.Format("2006-01-02")
-		minutes := onTimePerDay[key]
+		// step from noon so DST changes can't shift the date
+		date := utils.StartOfDay(time.Date(latest.Year(), latest.Month(), latest.Day()-i, 12, 0, 0, 0, loc), loc)
+		key := utils.DayKey(date, loc)
+		minutes := int(math.Round(onTimePerDay[key]))
 		results = append(results, types.TimeObject{
 			Date:  date,
 			Value: fmt.Sprintf("%d", minutes),
@@ -3,30 +3,47 @@ package log_device
 import (
 	"database/sql"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/services/rollup"
 	"github.com/quanghia24/mySmartHome/types"
 )
 
 type Store struct {
-	db *sql.DB
+	db      *sql.DB
+	rollups *rollup.Store
 }
 
 func NewStore(db *sql.DB) *Store {
 	return &Store{
-		db: db,
+		db:      db,
+		rollups: rollup.NewStore(db),
 	}
 }
 
-func (s *Store) CreateLog(log types.LogDevice) error {
-	_, err := s.db.Exec("INSERT INTO logs (type, message, deviceID, userID, value) VALUES (?,?,?,?,?)", log.Type, log.Message, log.DeviceID, log.UserID, log.Value)
-	return err
+func (s *Store) CreateLog(l types.LogDevice) error {
+	// the default locale is kept for the readers of the plain message
+	l.Message = i18n.Localize(i18n.DefaultLocale, l.MessageKey, l.Params, l.Message)
+	res, err := s.db.Exec("INSERT INTO logs (type, message, messageKey, params, deviceID, userID, value) VALUES (?,?,NULLIF(?, ''),?,?,?,?)", l.Type, l.Message, l.MessageKey, l.Params, l.DeviceID, l.UserID, l.Value)
+	if err != nil {
+		return err
+	}
 
+	// the log itself is stored, a missed rollup can be rebuilt with the backfill
+	if id, err := res.LastInsertId(); err == nil {
+		if err := s.rollups.AddDeviceLog(int(id)); err != nil {
+			log.Printf("device usage rollup for %d: %v\n", l.DeviceID, err)
+		}
+	}
+	return nil
 }
 
 func (s *Store) GetLogsByFeedID(feedId int) ([]types.LogDevice, error) {
 	query := `
-		SELECT id, type, message, deviceId, userId, value, CONVERT_TZ(logs.createdAt, '+00:00', '+07:00') AS createdAt_utc7 
+		SELECT id, type, message, COALESCE(messageKey, ''), params, deviceId, userId, value, createdAt
 		FROM logs 
 		WHERE deviceId = ? 
 		ORDER BY logs.createdAt DESC
@@ -52,7 +69,7 @@ func (s *Store) GetLogsByFeedID(feedId int) ([]types.LogDevice, error) {
 
 func (s *Store) GetLogsByFeedIDBetween(feedId int, start time.Time, end time.Time) ([]types.LogDevice, error) {
 	query := `
-		SELECT id, type, message, deviceId, userId, value, CONVERT_TZ(logs.createdAt, '+00:00', '+07:00') AS createdAt_utc7 
+		SELECT id, type, message, COALESCE(messageKey, ''), params, deviceId, userId, value, createdAt
 		FROM logs 
 		WHERE deviceId = ? AND createdAt BETWEEN ? AND ?
 		ORDER BY logs.createdAt DESC
@@ -81,8 +98,8 @@ func (s *Store) GetLogsByFeedIDBetween(feedId int, start time.Time, end time.Tim
 func (s *Store) GetLogsByFeedID7Days(feedId int, end time.Time) ([]types.LogDevice, error) {
 	query := `
 	SELECT 
-		id, type, message, deviceId, userId, value, 
-		CONVERT_TZ(logs.createdAt, '+00:00', '+07:00') AS createdAt_utc7
+		id, type, message, COALESCE(messageKey, ''), params, deviceId, userId, value, 
+		createdAt
 	FROM 
 		logs
 	WHERE 
@@ -127,7 +144,7 @@ func (s *Store) GetLogsByFeedID7Days(feedId int, end time.Time) ([]types.LogDevi
 
 func (s *Store) GetLogsByUserID(userId int) ([]types.LogDevice, error) {
 	query := `
-		SELECT id, type, message, deviceId, userId, value, CONVERT_TZ(logs.createdAt, '+00:00', '+07:00') AS createdAt_utc7 
+		SELECT id, type, message, COALESCE(messageKey, ''), params, deviceId, userId, value, createdAt
 		FROM logs 
 		WHERE userId = ?
 		ORDER BY logs.createdAt DESC
@@ -150,6 +167,45 @@ func (s *Store) GetLogsByUserID(userId int) ([]types.LogDevice, error) {
 	return logs, nil
 }
 
+// StreamLogsByFeedIDsBetween calls fn for every log of the devices in
+// [start, end), oldest first, without loading them all into memory.
+func (s *Store) StreamLogsByFeedIDsBetween(feedIds []int, start time.Time, end time.Time, fn func(types.LogDevice) error) error {
+	if len(feedIds) == 0 {
+		return nil
+	}
+
+	args := []any{}
+	for _, id := range feedIds {
+		args = append(args, id)
+	}
+	args = append(args, start, end)
+
+	query := `
+		SELECT id, type, message, COALESCE(messageKey, ''), params, deviceId, userId, value, createdAt
+		FROM logs
+		WHERE deviceId IN (` + strings.TrimSuffix(strings.Repeat("?,", len(feedIds)), ",") + `)
+		AND createdAt >= ? AND createdAt < ?
+		ORDER BY createdAt, id
+	`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l, err := scanRowIntoLog(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 func scanRowIntoLog(rows *sql.Rows) (*types.LogDevice, error) {
 	log := new(types.LogDevice)
 
@@ -157,6 +213,8 @@ func scanRowIntoLog(rows *sql.Rows) (*types.LogDevice, error) {
 		&log.ID,
 		&log.Type,
 		&log.Message,
+		&log.MessageKey,
+		&log.Params,
 		&log.DeviceID,
 		&log.UserID,
 		&log.Value,
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/quanghia24/mySmartHome/services/i18n"
 	"github.com/quanghia24/mySmartHome/types"
 	"github.com/quanghia24/mySmartHome/utils"
 )
@@ -50,6 +51,10 @@ func (h *Handler) getSensorUsage(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
+	locale := i18n.FromRequest(r, "")
+	for i, l := range logs {
+		logs[i].Message = i18n.Localize(locale, l.MessageKey, l.Params, l.Message)
+	}
 
 
 	// if payload.End.IsZero() {
@@ -2,32 +2,49 @@ package log_sensor
 
 import (
 	"database/sql"
+	"log"
+	"strings"
 	"time"
 
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/services/rollup"
 	"github.com/quanghia24/mySmartHome/types"
 )
 
 type Store struct {
-	db *sql.DB
+	db      *sql.DB
+	rollups *rollup.Store
 }
 
 func NewStore(db *sql.DB) *Store {
 	return &Store{
-		db: db,
+		db:      db,
+		rollups: rollup.NewStore(db),
 	}
 }
 
-func (s *Store) CreateLogSensor(log types.LogSensor) error {
-	_, err := s.db.Exec("INSERT INTO logs_sensor (type, message, sensorID, userID, value) VALUES (?,?,?,?,?)", log.Type, log.Message, log.SensorID, log.UserID, log.Value)
-	return err
+func (s *Store) CreateLogSensor(l types.LogSensor) error {
+	// the default locale is kept for the readers of the plain message
+	l.Message = i18n.Localize(i18n.DefaultLocale, l.MessageKey, l.Params, l.Message)
+	res, err := s.db.Exec("INSERT INTO logs_sensor (type, message, messageKey, params, sensorID, userID, value) VALUES (?,?,NULLIF(?, ''),?,?,?,?)", l.Type, l.Message, l.MessageKey, l.Params, l.SensorID, l.UserID, l.Value)
+	if err != nil {
+		return err
+	}
 
+	// the log itself is stored, a missed rollup can be rebuilt with the backfill
+	if id, err := res.LastInsertId(); err == nil && l.Type == "data" {
+		if err := s.rollups.AddSensorLog(int(id)); err != nil {
+			log.Printf("sensor stats rollup for %d: %v\n", l.SensorID, err)
+		}
+	}
+	return nil
 }
 
 func (s *Store) GetLogSensorsLast7HoursByFeedID(feedId int, end time.Time) ([]types.LogSensor, error) {
 	start := end.Add(-7 * time.Hour) // 7 hours before the end time
 
 	query := `
-		SELECT * 
+		SELECT id, type, message, COALESCE(messageKey, ''), params, sensorId, userId, value, createdAt
 		FROM logs_sensor 
 		WHERE sensorId = ?
 		AND createdAt BETWEEN ? AND ?
@@ -53,9 +70,40 @@ func (s *Store) GetLogSensorsLast7HoursByFeedID(feedId int, end time.Time) ([]ty
 	return logs, nil
 }
 
+// GetLatestSensorData returns the newest reading of the sensor, or nil when
+// it has none yet.
+func (s *Store) GetLatestSensorData(feedId int) (*types.LogSensor, error) {
+	log := new(types.LogSensor)
+	err := s.db.QueryRow(`
+		SELECT id, type, message, COALESCE(messageKey, ''), params, sensorId, userId, value, createdAt
+		FROM logs_sensor
+		WHERE sensorId = ? AND type = 'data'
+		ORDER BY createdAt DESC, id DESC
+		LIMIT 1
+	`, feedId).Scan(
+		&log.ID,
+		&log.Type,
+		&log.Message,
+		&log.MessageKey,
+		&log.Params,
+		&log.SensorID,
+		&log.UserID,
+		&log.Value,
+		&log.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
 func (s *Store) GetLogSensorsByUserID(userId int) ([]types.LogSensor, error) {
 	query := `
-		SELECT * FROM logs_sensor WHERE userId = ?
+		SELECT id, type, message, COALESCE(messageKey, ''), params, sensorId, userId, value, createdAt
+		FROM logs_sensor WHERE userId = ?
 		ORDER BY logs_sensor.createdAt DESC
 	`
 	rows, err := s.db.Query(query, userId)
@@ -78,7 +126,7 @@ func (s *Store) GetLogSensorsByUserID(userId int) ([]types.LogSensor, error) {
 
 func (s *Store) GetSensorsByFeedIDBetween(feedId int, start time.Time, end time.Time) ([]types.LogSensor, error) {
 	query := `
-		SELECT id, type, message, sensorId, userId, value, CONVERT_TZ(logs_sensor.createdAt, '+00:00', '+07:00') AS createdAt_utc7 
+		SELECT id, type, message, COALESCE(messageKey, ''), params, sensorId, userId, value, createdAt
 		FROM logs_sensor 
 		WHERE sensorId = ? AND type = 'data'  AND createdAt BETWEEN ? AND ?
 		ORDER BY logs_sensor.createdAt DESC
@@ -104,6 +152,45 @@ func (s *Store) GetSensorsByFeedIDBetween(feedId int, start time.Time, end time.
 	return logs, nil
 }
 
+// StreamSensorsByFeedIDsBetween calls fn for every reading of the sensors in
+// [start, end), oldest first, without loading them all into memory.
+func (s *Store) StreamSensorsByFeedIDsBetween(feedIds []int, start time.Time, end time.Time, fn func(types.LogSensor) error) error {
+	if len(feedIds) == 0 {
+		return nil
+	}
+
+	args := []any{}
+	for _, id := range feedIds {
+		args = append(args, id)
+	}
+	args = append(args, start, end)
+
+	query := `
+		SELECT id, type, message, COALESCE(messageKey, ''), params, sensorId, userId, value, createdAt
+		FROM logs_sensor
+		WHERE sensorId IN (` + strings.TrimSuffix(strings.Repeat("?,", len(feedIds)), ",") + `)
+		AND type = 'data' AND createdAt >= ? AND createdAt < ?
+		ORDER BY createdAt, id
+	`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l, err := scanRowIntoLog(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 func scanRowIntoLog(rows *sql.Rows) (*types.LogSensor, error) {
 	log := new(types.LogSensor)
 
@@ -111,6 +198,8 @@ func scanRowIntoLog(rows *sql.Rows) (*types.LogSensor, error) {
 		&log.ID,
 		&log.Type,
 		&log.Message,
+		&log.MessageKey,
+		&log.Params,
 		&log.SensorID,
 		&log.UserID,
 		&log.Value,
@@ -0,0 +1,261 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+const (
+	maxAttempts = 3
+
+	// phones that didn't register their token for this long aren't pushed to
+	activeTokenAge = 90 * 24 * time.Hour
+)
+
+// Dispatcher keeps a notification in the user's inbox and sends it on every
+// channel they set up. Deliveries run in the background and are retried, a
+// channel that keeps failing is logged and skipped.
+type Dispatcher struct {
+	store     types.NotiStore
+	userStore types.UserStore
+	expo      pusher
+	notifiers map[string]Notifier
+
+	// wait before the second attempt, doubled for each next one
+	retryDelay time.Duration
+	wg         sync.WaitGroup
+}
+
+// pusher pushes to phones and tells whether the pushes got there, the
+// ExpoNotifier outside of tests.
+type pusher interface {
+	Push(token string, msg Message) (string, error)
+	Receipts(ticketIds []string) (map[string]Receipt, error)
+}
+
+func NewDispatcher(store types.NotiStore, userStore types.UserStore) *Dispatcher {
+	return &Dispatcher{
+		store:     store,
+		userStore: userStore,
+		expo:      NewExpoNotifier(),
+		notifiers: map[string]Notifier{
+			"email":    EmailFromEnv(),
+			"webhook":  WebhookNotifier{},
+			"telegram": TelegramFromEnv(),
+		},
+		retryDelay: 5 * time.Second,
+	}
+}
+
+// Notify stores the message for the user and, unless they are within their
+// quiet hours, sends it on the channels they chose for its event: each of
+// their phones and their channels that aren't kept for escalations. A
+// message held back during quiet hours goes out in the digest after them.
+func (d *Dispatcher) Notify(userId int, msg Message) {
+	allowed := d.channelsFor(userId, msg.Event)
+	if len(allowed) == 0 {
+		return
+	}
+	if msg.Category == "" {
+		msg.Category = categoryOf(msg.Event)
+	}
+	held := !msg.Critical && d.quiet(userId, time.Now())
+
+	notiId, err := d.store.CreateNoti(types.NotiPayload{
+		UserID:        userId,
+		Message:       msg.Body,
+		MessageKey:    msg.BodyKey,
+		Params:        msg.Params,
+		Category:      msg.Category,
+		HeldForDigest: held,
+		Event:         msg.Event,
+	})
+	if err != nil {
+		log.Println("notify:", err)
+	}
+	if held {
+		return
+	}
+
+	d.deliverAll(notiId, userId, msg, allowed)
+}
+
+// deliverAll sends the message on the allowed channels, in the user's
+// locale.
+func (d *Dispatcher) deliverAll(notiId int, userId int, msg Message, allowed map[string]bool) {
+	msg = msg.Localize(d.locale(userId))
+	if allowed["push"] {
+		tokens, err := d.store.GetPushTokensByUserID(userId)
+		if err != nil {
+			log.Println("notify:", err)
+		}
+		active := []string{}
+		for _, t := range tokens {
+			if time.Since(t.LastSeenAt) <= activeTokenAge {
+				active = append(active, t.Token)
+			}
+		}
+		if len(active) > 0 {
+			d.push(notiId, active, userId, msg)
+		}
+	}
+	d.sendToChannels(userId, msg, false, allowed)
+}
+
+// Escalate sends the message on the user's escalation channels, or emails
+// their account address when they set none up. Escalations are what the
+// alert's policy asks for, preferences and quiet hours don't hold them.
+func (d *Dispatcher) Escalate(userId int, msg Message) {
+	u, err := d.userStore.GetUserByID(userId)
+	if err != nil {
+		log.Println("escalate:", err)
+		return
+	}
+
+	msg = msg.Localize(u.Locale)
+	if d.sendToChannels(userId, msg, true, nil) > 0 {
+		return
+	}
+	d.deliver("email", u.Email, userId, msg)
+}
+
+// locale is the language the user reads their messages in.
+func (d *Dispatcher) locale(userId int) string {
+	u, err := d.userStore.GetUserByID(userId)
+	if err != nil {
+		log.Println("user locale:", err)
+		return i18n.DefaultLocale
+	}
+	return u.Locale
+}
+
+// Test delivers the message on the channel with a single attempt, so the
+// user trying the channel out hears back at once.
+func (d *Dispatcher) Test(c types.NotiChannel, msg Message) error {
+	n, ok := d.notifiers[c.Type]
+	if !ok {
+		return fmt.Errorf("no notifier for channel %s", c.Type)
+	}
+	return n.Send(c.Target, msg.Localize(d.locale(c.UserID)))
+}
+
+// sendToChannels sends the message on the enabled channels that are, or
+// aren't, kept for escalations, and returns how many there were. Only the
+// allowed types of channels are used, when given.
+func (d *Dispatcher) sendToChannels(userId int, msg Message, escalation bool, allowed map[string]bool) int {
+	channels, err := d.store.GetChannelsByUserID(userId)
+	if err != nil {
+		log.Println("notification channels:", err)
+		return 0
+	}
+
+	sent := 0
+	for _, c := range channels {
+		if !c.Enabled || c.EscalationOnly != escalation || allowed != nil && !allowed[c.Type] {
+			continue
+		}
+		d.deliver(c.Type, c.Target, userId, msg)
+		sent++
+	}
+	return sent
+}
+
+// push sends the notification to the phones, keeping a ticket of each push
+// so PollReceipts can tell whether it reached the phone.
+func (d *Dispatcher) push(notiId int, tokens []string, userId int, msg Message) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		tickets := []types.PushTicket{}
+		for _, token := range tokens {
+			t := types.PushTicket{NotiID: notiId, Token: token, Status: pushSent}
+			err := d.retry(func() error {
+				var err error
+				t.TicketID, err = d.expo.Push(token, msg)
+				return err
+			})
+			if err != nil {
+				log.Printf("push notification for user %d: %v\n", userId, err)
+				t.Status, t.Error = pushError, truncate(err.Error(), 255)
+			}
+			if errors.Is(err, ErrNotRegistered) {
+				if err := d.store.DeletePushTokenByValue(token); err != nil {
+					log.Println("remove push token:", err)
+				}
+			}
+			tickets = append(tickets, t)
+
+			if notiId == 0 {
+				continue
+			}
+			if err := d.store.CreatePushTicket(t); err != nil {
+				log.Println("push ticket:", err)
+			}
+		}
+
+		if notiId == 0 {
+			return
+		}
+		status, reason := deliveryStatus(tickets)
+		if err := d.store.SetNotiDelivery(notiId, status, reason); err != nil {
+			log.Println("push delivery:", err)
+		}
+	}()
+}
+
+func (d *Dispatcher) deliver(channel string, target string, userId int, msg Message) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		if err := d.send(channel, target, msg); err != nil {
+			log.Printf("%s notification for user %d: %v\n", channel, userId, err)
+		}
+	}()
+}
+
+func (d *Dispatcher) send(channel string, target string, msg Message) error {
+	n, ok := d.notifiers[channel]
+	if !ok {
+		return fmt.Errorf("no notifier for channel %s", channel)
+	}
+	return d.retry(func() error {
+		return n.Send(target, msg)
+	})
+}
+
+// retry tries to deliver up to maxAttempts times, a push token that is no
+// longer registered and the other permanent failures are not retried.
+func (d *Dispatcher) retry(deliver func() error) error {
+	delay := d.retryDelay
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = deliver()
+		if err == nil || errors.Is(err, ErrNotRegistered) || isPermanent(err) {
+			return err
+		}
+		if attempt < maxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// Wait blocks until the deliveries in flight are done.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
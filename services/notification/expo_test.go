@@ -0,0 +1,200 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	expo "github.com/oliveroneill/exponent-server-sdk-golang/sdk"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// expoDouble stands in for Expo's push service.
+type expoDouble struct {
+	*httptest.Server
+	mu       sync.Mutex
+	gone     map[string]bool    // tokens of uninstalled apps
+	pushed   []string           // tokens pushed to
+	receipts map[string]Receipt // by ticket id
+}
+
+func newExpoDouble(t *testing.T) *expoDouble {
+	e := &expoDouble{
+		gone:     map[string]bool{},
+		receipts: map[string]Receipt{},
+	}
+	e.Server = httptest.NewServer(http.HandlerFunc(e.serve))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func (e *expoDouble) serve(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch r.URL.Path {
+	case expo.DefaultBaseAPIURL + "/push/send":
+		var messages []expo.PushMessage
+		if err := json.NewDecoder(r.Body).Decode(&messages); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data := []map[string]any{}
+		for _, m := range messages {
+			token := string(m.To[0])
+			e.pushed = append(e.pushed, token)
+			if e.gone[token] {
+				data = append(data, map[string]any{
+					"status":  "error",
+					"message": token + " is not a registered push notification recipient",
+					"details": map[string]string{"error": expo.ErrorDeviceNotRegistered},
+				})
+				continue
+			}
+			data = append(data, map[string]any{"status": "ok", "id": "ticket-" + token})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+
+	case expo.DefaultBaseAPIURL + "/push/getReceipts":
+		var req struct {
+			IDs []string `json:"ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data := map[string]Receipt{}
+		for _, id := range req.IDs {
+			if receipt, ok := e.receipts[id]; ok {
+				data[id] = receipt
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (n *notis) CreatePushTicket(t types.PushTicket) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	t.ID = len(n.tickets) + 1
+	t.CreatedAt = time.Now()
+	n.tickets = append(n.tickets, t)
+	return nil
+}
+
+func (n *notis) GetPushTicketsByNotiID(notiId int) ([]types.PushTicket, error) {
+	tickets := []types.PushTicket{}
+	for _, t := range n.tickets {
+		if t.NotiID == notiId {
+			tickets = append(tickets, t)
+		}
+	}
+	return tickets, nil
+}
+
+func (n *notis) GetPendingPushTickets(from time.Time, to time.Time) ([]types.PushTicket, error) {
+	tickets := []types.PushTicket{}
+	for _, t := range n.tickets {
+		if t.Status == pushSent && t.TicketID != "" && !t.CreatedAt.Before(from) && t.CreatedAt.Before(to) {
+			tickets = append(tickets, t)
+		}
+	}
+	return tickets, nil
+}
+
+func (n *notis) UpdatePushTicket(t types.PushTicket) error {
+	n.tickets[t.ID-1] = t
+	return nil
+}
+
+func (n *notis) SetNotiDelivery(id int, status string, reason string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.stored[id-1].DeliveryStatus = status
+	n.stored[id-1].DeliveryError = reason
+	return nil
+}
+
+func TestPollReceipts(t *testing.T) {
+	store := &notis{tokens: []types.PushToken{
+		{Token: "ExponentPushToken[phone]", LastSeenAt: time.Now()},
+		{Token: "ExponentPushToken[tablet]", LastSeenAt: time.Now()},
+	}}
+	d, double := testDispatcher(t, store)
+
+	d.Notify(1, Message{Body: "hot"})
+	d.Notify(1, Message{Body: "cold"})
+	d.Wait()
+	if len(store.tickets) != 4 {
+		t.Fatalf("expected 4 tickets, got %+v", store.tickets)
+	}
+
+	// the first notification reached the tablet, the phone's app is gone
+	var phone, tablet *types.PushTicket
+	for i := range store.tickets {
+		switch t := &store.tickets[i]; {
+		case t.NotiID == 1 && t.Token == "ExponentPushToken[phone]":
+			t.TicketID, phone = "first-phone", t
+		case t.NotiID == 1:
+			t.TicketID, tablet = "first-tablet", t
+		}
+	}
+	double.receipts["first-phone"] = Receipt{Status: "error", Message: "gone", Details: struct {
+		Error string `json:"error"`
+	}{expo.ErrorDeviceNotRegistered}}
+	double.receipts["first-tablet"] = Receipt{Status: "ok"}
+
+	// receipts aren't looked up before they're ready
+	if err := d.PollReceipts(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if phone.Status != pushSent {
+		t.Fatalf("expected the receipts to be left for later, got %+v", *phone)
+	}
+
+	if err := d.PollReceipts(time.Now().Add(receiptDelay)); err != nil {
+		t.Fatal(err)
+	}
+
+	if phone.Status != pushError || phone.Error != expo.ErrorDeviceNotRegistered {
+		t.Errorf("expected the phone's push to have failed, got %+v", *phone)
+	}
+	if tablet.Status != pushDelivered {
+		t.Errorf("expected the tablet's push to be delivered, got %+v", *tablet)
+	}
+	if len(store.removed) != 1 || store.removed[0] != "ExponentPushToken[phone]" {
+		t.Errorf("expected the phone's token to be removed, got %v", store.removed)
+	}
+	if store.stored[0].DeliveryStatus != pushDelivered {
+		t.Errorf("expected the first notification delivered, got %+v", store.stored[0])
+	}
+	if store.stored[1].DeliveryStatus != pushSent {
+		t.Errorf("expected the second notification still waiting, got %+v", store.stored[1])
+	}
+}
+
+func TestDeliveryStatus(t *testing.T) {
+	for _, c := range []struct {
+		statuses []string
+		want     string
+	}{
+		{nil, ""},
+		{[]string{pushError, pushError}, pushError},
+		{[]string{pushError, pushSent}, pushSent},
+		{[]string{pushSent, pushDelivered, pushError}, pushDelivered},
+	} {
+		tickets := []types.PushTicket{}
+		for _, s := range c.statuses {
+			tickets = append(tickets, types.PushTicket{Status: s, Error: "reason"})
+		}
+		if got, _ := deliveryStatus(tickets); got != c.want {
+			t.Errorf("%v: expected %q, got %q", c.statuses, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	expo "github.com/oliveroneill/exponent-server-sdk-golang/sdk"
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/utils"
+)
+
+// categories of the notifications in the inbox
+const (
+	CategoryThreshold = "threshold"
+	CategoryDoor      = "door"
+	CategorySchedule  = "schedule"
+	CategorySystem    = "system"
+)
+
+// Message is what a user is told, whatever the channel.
+type Message struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+	// templates of the title and the body, rendered over them in the
+	// locale of each recipient
+	TitleKey string      `json:"-"`
+	BodyKey  string      `json:"-"`
+	Params   i18n.Params `json:"-"`
+	Category string      `json:"category,omitempty"` // of the event when empty
+	// the preferences of the event choose the channels, all of them when
+	// empty
+	Event string `json:"event,omitempty"`
+	// gets through quiet hours
+	Critical bool `json:"critical,omitempty"`
+}
+
+// Localize renders the templates of the message in the locale.
+func (msg Message) Localize(locale string) Message {
+	msg.Title = i18n.Localize(locale, msg.TitleKey, msg.Params, msg.Title)
+	msg.Body = i18n.Localize(locale, msg.BodyKey, msg.Params, msg.Body)
+	return msg
+}
+
+// Notifier delivers a message to a target of its channel: a push token, an
+// email address, a webhook url or a Telegram chat id.
+type Notifier interface {
+	Send(target string, msg Message) error
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// posts to the webhook urls of the users, which can only reach the internet
+var webhookClient = utils.PublicClient(10 * time.Second)
+
+// ErrNotRegistered is returned for a push token the phone no longer
+// answers to, because the app was uninstalled or its token changed.
+var ErrNotRegistered = errors.New("push token not registered")
+
+// permanentError is a failure that would happen again on every retry: a
+// refused login, a target the service rejects or a message it won't take.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// refused tells whether a status is the receiver turning the request down
+// for good, rather than being unavailable or busy.
+func refused(status int) bool {
+	return status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
+
+// ExpoNotifier pushes to the Expo push token of a phone.
+type ExpoNotifier struct {
+	client *expo.PushClient
+	host   string
+}
+
+func NewExpoNotifier() *ExpoNotifier {
+	return newExpoNotifier(expo.DefaultHost)
+}
+
+func newExpoNotifier(host string) *ExpoNotifier {
+	return &ExpoNotifier{
+		client: expo.NewPushClient(&expo.ClientConfig{Host: host, HTTPClient: httpClient}),
+		host:   host,
+	}
+}
+
+func (n *ExpoNotifier) Send(target string, msg Message) error {
+	_, err := n.Push(target, msg)
+	return err
+}
+
+// Push sends the message to the phone and returns the id of the ticket
+// Expo gave it, to look up its receipt later.
+func (n *ExpoNotifier) Push(token string, msg Message) (string, error) {
+	pushToken, err := expo.NewExponentPushToken(token)
+	if err != nil {
+		return "", permanent(err)
+	}
+
+	response, err := n.client.Publish(&expo.PushMessage{
+		To:       []expo.ExponentPushToken{pushToken},
+		Body:     msg.Body,
+		Data:     msg.Data,
+		Sound:    "default",
+		Title:    msg.Title,
+		Priority: expo.DefaultPriority,
+	})
+	if err != nil {
+		// the client reports a refused request, such as invalid
+		// credentials, only by its status in the message
+		var status int
+		if _, scanErr := fmt.Sscanf(err.Error(), "Invalid response (%d", &status); scanErr == nil && refused(status) {
+			return "", permanent(err)
+		}
+		return "", err
+	}
+
+	err = response.ValidateResponse()
+	var notRegistered *expo.DeviceNotRegisteredError
+	if errors.As(err, &notRegistered) {
+		return "", ErrNotRegistered
+	}
+	var tooBig *expo.MessageTooBigError
+	if errors.As(err, &tooBig) {
+		return "", permanent(err)
+	}
+	return response.ID, err
+}
+
+// EmailNotifier sends plain text emails through an SMTP server.
+type EmailNotifier struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+}
+
+// EmailFromEnv configures the SMTP server from SMTP_HOST, SMTP_PORT,
+// SMTP_USER, SMTP_PASSWORD and SMTP_FROM.
+func EmailFromEnv() *EmailNotifier {
+	n := &EmailNotifier{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		User:     os.Getenv("SMTP_USER"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if n.Port == "" {
+		n.Port = "587"
+	}
+	if n.From == "" {
+		n.From = n.User
+	}
+	return n
+}
+
+func (n *EmailNotifier) Send(target string, msg Message) error {
+	if n.Host == "" {
+		return permanent(fmt.Errorf("no SMTP server configured"))
+	}
+
+	var auth smtp.Auth
+	if n.User != "" {
+		auth = smtp.PlainAuth("", n.User, n.Password, n.Host)
+	}
+
+	body := "From: " + n.From + "\r\n" +
+		"To: " + target + "\r\n" +
+		"Subject: " + emailSubject(msg.Title) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + msg.Body
+	err := smtp.SendMail(n.Host+":"+n.Port, auth, n.From, []string{target}, []byte(body))
+
+	// 5xx replies, a failed login or an unknown mailbox, fail the same way
+	// every time
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return permanent(err)
+	}
+	return err
+}
+
+// emailSubject keeps the title on the header line, a CR or a LF would start
+// another header, and encodes it for the Vietnamese letters.
+func emailSubject(title string) string {
+	title = strings.NewReplacer("\r", " ", "\n", " ").Replace(title)
+	return mime.QEncoding.Encode("utf-8", title)
+}
+
+// WebhookNotifier posts the message as JSON to a url.
+type WebhookNotifier struct{}
+
+func (n WebhookNotifier) Send(target string, msg Message) error {
+	return postJSON(webhookClient, target, map[string]any{
+		"title":  msg.Title,
+		"body":   msg.Body,
+		"data":   msg.Data,
+		"sentAt": time.Now().UTC(),
+	})
+}
+
+// TelegramNotifier sends the message to a chat through a Telegram bot.
+type TelegramNotifier struct {
+	Token   string
+	BaseURL string
+}
+
+// TelegramFromEnv uses the bot of TELEGRAM_BOT_TOKEN.
+func TelegramFromEnv() *TelegramNotifier {
+	return &TelegramNotifier{
+		Token:   os.Getenv("TELEGRAM_BOT_TOKEN"),
+		BaseURL: "https://api.telegram.org",
+	}
+}
+
+func (n *TelegramNotifier) Send(target string, msg Message) error {
+	if n.Token == "" {
+		return permanent(fmt.Errorf("no Telegram bot configured"))
+	}
+
+	text := msg.Body
+	if msg.Title != "" {
+		text = msg.Title + "\n" + msg.Body
+	}
+	return postJSON(httpClient, n.BaseURL+"/bot"+n.Token+"/sendMessage", map[string]any{
+		"chat_id": target,
+		"text":    text,
+	})
+}
+
+// postJSON posts the payload to the url. The error has the status of a
+// refused post but not the body of the response, which is the receiver's
+// and may be shown to the user.
+func postJSON(client *http.Client, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("responded %s", resp.Status)
+		// a bad chat id or a url the receiver doesn't serve won't get better
+		if refused(resp.StatusCode) {
+			return permanent(err)
+		}
+		return err
+	}
+	return nil
+}
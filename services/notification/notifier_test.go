@@ -0,0 +1,294 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+type notis struct {
+	types.NotiStore
+	mu       sync.Mutex
+	stored   []types.NotiPayload
+	channels []types.NotiChannel
+	tokens   []types.PushToken
+	removed  []string
+	tickets  []types.PushTicket
+	prefs    map[string][]string // channels by event
+	quiet    *types.QuietHours
+}
+
+func (n *notis) GetPushTokensByUserID(userId int) ([]types.PushToken, error) {
+	return n.tokens, nil
+}
+
+func (n *notis) DeletePushTokenByValue(token string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.removed = append(n.removed, token)
+	return nil
+}
+
+func (n *notis) CreateNoti(noti types.NotiPayload) (int, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	noti.ID = len(n.stored) + 1
+	n.stored = append(n.stored, noti)
+	return noti.ID, nil
+}
+
+func (n *notis) GetChannelsByUserID(userId int) ([]types.NotiChannel, error) {
+	return n.channels, nil
+}
+
+type users struct{ types.UserStore }
+
+// users reads english when their id is even.
+func (users) GetUserByID(id int) (*types.User, error) {
+	locale := "vi"
+	if id%2 == 0 {
+		locale = "en"
+	}
+	return &types.User{ID: id, Email: "owner@example.com", Locale: locale}, nil
+}
+
+// flaky fails its first sends, and always for the gone targets.
+type flaky struct {
+	mu      sync.Mutex
+	fails   int
+	gone    string
+	targets []string
+	sent    []Message
+}
+
+func (f *flaky) Send(target string, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.targets = append(f.targets, target)
+	f.sent = append(f.sent, msg)
+	if target == f.gone {
+		return ErrNotRegistered
+	}
+	if len(f.targets) <= f.fails {
+		return fmt.Errorf("unavailable")
+	}
+	return nil
+}
+
+func testDispatcher(t *testing.T, store *notis) (*Dispatcher, *expoDouble) {
+	double := newExpoDouble(t)
+	d := NewDispatcher(store, users{})
+	d.expo = newExpoNotifier(double.URL)
+	d.retryDelay = 0
+	return d, double
+}
+
+func TestNotifySendsToChannels(t *testing.T) {
+	store := &notis{channels: []types.NotiChannel{
+		{Type: "webhook", Target: "hook", Enabled: true},
+		{Type: "webhook", Target: "off"},
+		{Type: "webhook", Target: "escalation", Enabled: true, EscalationOnly: true},
+	}}
+	d, _ := testDispatcher(t, store)
+	webhook := &flaky{}
+	d.notifiers["webhook"] = webhook
+
+	// the push token is invalid, which must be logged and not panic
+	store.tokens = []types.PushToken{{Token: "not a token", LastSeenAt: time.Now()}}
+	d.Notify(1, Message{Title: "title", Body: "hot", Category: CategoryThreshold})
+	d.Wait()
+
+	if len(store.stored) != 1 || store.stored[0].Message != "hot" || store.stored[0].Category != CategoryThreshold {
+		t.Errorf("expected the notification to be stored, got %+v", store.stored)
+	}
+	if len(webhook.targets) != 1 || webhook.targets[0] != "hook" {
+		t.Errorf("expected only the enabled channel, got %v", webhook.targets)
+	}
+	if store.stored[0].DeliveryStatus != pushError {
+		t.Errorf("expected the failed push to be recorded, got %q", store.stored[0].DeliveryStatus)
+	}
+}
+
+func TestNotifyRendersInUserLocale(t *testing.T) {
+	store := &notis{channels: []types.NotiChannel{{Type: "webhook", Target: "hook", Enabled: true}}}
+	d, _ := testDispatcher(t, store)
+	webhook := &flaky{}
+	d.notifiers["webhook"] = webhook
+
+	msg := Message{
+		TitleKey: "noti.threshold.title",
+		BodyKey:  "noti.threshold.upper",
+		Params:   i18n.Params{"type": "temperature", "value": "36", "threshold": "35"},
+	}
+	d.Notify(1, msg)
+	d.Notify(2, msg)
+	d.Wait()
+
+	titles := map[string]bool{}
+	for _, m := range webhook.sent {
+		titles[m.Title] = true
+	}
+	if !titles["Vượt ngưỡng cảm biến nhiệt độ"] || !titles["temperature sensor out of range"] {
+		t.Errorf("expected a title per locale, got %v", titles)
+	}
+	for _, n := range store.stored {
+		if n.MessageKey != "noti.threshold.upper" || n.Params["value"] != "36" {
+			t.Errorf("expected the template to be kept in the inbox, got %+v", n)
+		}
+	}
+}
+
+func TestNotifyPushesToEveryPhone(t *testing.T) {
+	now := time.Now()
+	store := &notis{tokens: []types.PushToken{
+		{Token: "ExponentPushToken[phone]", LastSeenAt: now},
+		{Token: "ExponentPushToken[tablet]", LastSeenAt: now.Add(-24 * time.Hour)},
+		{Token: "ExponentPushToken[uninstalled]", LastSeenAt: now},
+		{Token: "ExponentPushToken[drawer]", LastSeenAt: now.Add(-activeTokenAge - time.Hour)},
+	}}
+	d, double := testDispatcher(t, store)
+	double.gone["ExponentPushToken[uninstalled]"] = true
+
+	d.Notify(1, Message{Body: "hot"})
+	d.Wait()
+
+	if len(double.pushed) != 3 {
+		t.Errorf("expected the three active phones to be pushed once, got %v", double.pushed)
+	}
+	if len(store.removed) != 1 || store.removed[0] != "ExponentPushToken[uninstalled]" {
+		t.Errorf("expected the unregistered token to be removed, got %v", store.removed)
+	}
+	if len(store.tickets) != 3 || store.stored[0].DeliveryStatus != pushSent {
+		t.Errorf("expected a ticket per phone and the notification sent, got %+v", store.stored[0])
+	}
+}
+
+func TestEscalateFallsBackToEmail(t *testing.T) {
+	store := &notis{}
+	d, _ := testDispatcher(t, store)
+	email := &flaky{}
+	d.notifiers["email"] = email
+
+	d.Escalate(1, Message{Body: "hot"})
+	d.Wait()
+
+	if len(email.targets) != 1 || email.targets[0] != "owner@example.com" {
+		t.Errorf("expected the account address to be emailed, got %v", email.targets)
+	}
+	if len(store.stored) != 0 {
+		t.Errorf("expected escalations to stay out of the inbox")
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	d, _ := testDispatcher(t, &notis{})
+
+	webhook := &flaky{fails: 2}
+	d.notifiers["webhook"] = webhook
+	if err := d.send("webhook", "hook", Message{}); err != nil {
+		t.Errorf("expected the third attempt to succeed, got %v", err)
+	}
+
+	webhook = &flaky{fails: maxAttempts}
+	d.notifiers["webhook"] = webhook
+	if err := d.send("webhook", "hook", Message{}); err == nil {
+		t.Errorf("expected the error after %d attempts", maxAttempts)
+	}
+	if len(webhook.targets) != maxAttempts {
+		t.Errorf("expected %d attempts, got %d", maxAttempts, len(webhook.targets))
+	}
+
+	refused := 0
+	d.notifiers["webhook"] = notifierFunc(func(target string, msg Message) error {
+		refused++
+		return permanent(fmt.Errorf("400 Bad Request"))
+	})
+	if err := d.send("webhook", "hook", Message{}); err == nil || refused != 1 {
+		t.Errorf("expected a permanent error after a single attempt, got %v after %d", err, refused)
+	}
+}
+
+func TestTestMakesASingleAttempt(t *testing.T) {
+	d, _ := testDispatcher(t, &notis{})
+
+	webhook := &flaky{fails: 1}
+	d.notifiers["webhook"] = webhook
+	if err := d.Test(types.NotiChannel{Type: "webhook", Target: "hook"}, Message{}); err == nil {
+		t.Error("expected the error of the first attempt")
+	}
+	if len(webhook.targets) != 1 {
+		t.Errorf("expected a single attempt, got %d", len(webhook.targets))
+	}
+}
+
+type notifierFunc func(target string, msg Message) error
+
+func (f notifierFunc) Send(target string, msg Message) error {
+	return f(target, msg)
+}
+
+func TestTelegramNotifier(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/botsecret/sendMessage" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	n := &TelegramNotifier{Token: "secret", BaseURL: server.URL}
+	if err := n.Send("-100200", Message{Title: "Alert", Body: "too hot"}); err != nil {
+		t.Fatal(err)
+	}
+	if got["chat_id"] != "-100200" || got["text"] != "Alert\ntoo hot" {
+		t.Errorf("unexpected message %v", got)
+	}
+
+	n.Token = "wrong"
+	if err := n.Send("-100200", Message{Body: "too hot"}); err == nil || !isPermanent(err) {
+		t.Errorf("expected a permanent error for a refused request, got %v", err)
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	for _, c := range []struct {
+		channel, target string
+		ok              bool
+	}{
+		{"email", "an@example.com", true},
+		{"email", "an", false},
+		{"webhook", "https://93.184.216.34/x", true},
+		{"webhook", "ftp://93.184.216.34", false},
+		{"webhook", "http://127.0.0.1:8080/x", false},
+		{"webhook", "http://169.254.169.254/latest/meta-data", false},
+		{"webhook", "http://192.168.1.10/x", false},
+		{"webhook", "http://[::1]/x", false},
+		{"telegram", "-100200", true},
+		{"telegram", "@home_alerts", true},
+		{"telegram", "home", false},
+	} {
+		if err := validateTarget(c.channel, c.target); (err == nil) != c.ok {
+			t.Errorf("%s %q: got %v", c.channel, c.target, err)
+		}
+	}
+}
+
+func TestEmailSubjectStaysOneEncodedLine(t *testing.T) {
+	got := emailSubject("Cảnh báo\r\nBcc: someone@example.com")
+	if strings.ContainsAny(got, "\r\n") || !strings.HasPrefix(got, "=?utf-8?q?") {
+		t.Errorf("expected a single encoded line, got %q", got)
+	}
+	if got := emailSubject("Test notification"); got != "Test notification" {
+		t.Errorf("expected an ascii subject left as is, got %q", got)
+	}
+}
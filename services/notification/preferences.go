@@ -0,0 +1,245 @@
+package notification
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// events the user chooses the channels of
+const (
+	EventDoor     = "door"
+	EventSchedule = "schedule"
+	EventOrder    = "order"
+
+	thresholdEvent = "threshold."
+
+	defaultTimezone = "Asia/Ho_Chi_Minh"
+
+	digestInterval = time.Minute
+	// most notifications listed in a digest, the rest are counted
+	digestLines = 10
+)
+
+// channels an event can go out on, all of them unless the user chose
+var channelTypes = []string{"push", "email", "webhook", "telegram"}
+
+// ThresholdEvent is the event of the readings of a sensor type out of their
+// plan's bounds.
+func ThresholdEvent(sensorType string) string {
+	return thresholdEvent + sensorType
+}
+
+// Events lists the events the user can choose the channels of.
+func Events() []string {
+	events := []string{}
+	for _, t := range devicetype.Sensors() {
+		events = append(events, ThresholdEvent(t.Name))
+	}
+	return append(events, EventDoor, EventSchedule, EventOrder)
+}
+
+func isEvent(event string) bool {
+	for _, e := range Events() {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// categoryOf files the notification of an event in the inbox.
+func categoryOf(event string) string {
+	switch {
+	case strings.HasPrefix(event, thresholdEvent):
+		return CategoryThreshold
+	case event == EventDoor:
+		return CategoryDoor
+	case event == EventSchedule:
+		return CategorySchedule
+	}
+	return CategorySystem
+}
+
+// channelsFor returns the channels the user wants the event on.
+func (d *Dispatcher) channelsFor(userId int, event string) map[string]bool {
+	allowed := map[string]bool{}
+	channels := channelTypes
+
+	if event != "" {
+		p, err := d.store.GetNotiPreference(userId, event)
+		if err != nil {
+			log.Println("notification preference:", err)
+		}
+		if p != nil {
+			channels = p.Channels
+		}
+	}
+
+	for _, c := range channels {
+		allowed[c] = true
+	}
+	return allowed
+}
+
+// quiet tells whether the user is within their quiet hours.
+func (d *Dispatcher) quiet(userId int, now time.Time) bool {
+	q, err := d.store.GetQuietHours(userId)
+	if err != nil {
+		log.Println("quiet hours:", err)
+		return false
+	}
+	return inQuietHours(q, now)
+}
+
+// inQuietHours tells whether t falls within the quiet hours, which may run
+// past midnight.
+func inQuietHours(q *types.QuietHours, t time.Time) bool {
+	if q == nil {
+		return false
+	}
+	start, err1 := time.Parse("15:04", q.Start)
+	end, err2 := time.Parse("15:04", q.End)
+	if err1 != nil || err2 != nil || start.Equal(end) {
+		return false
+	}
+
+	timezone := q.Timezone
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return false
+	}
+
+	local := t.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+	if from < to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// StartDigests sends the digests of the users whose quiet hours are over,
+// every digestInterval.
+func (d *Dispatcher) StartDigests() {
+	ticker := time.NewTicker(digestInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := d.SendDigests(time.Now()); err != nil {
+			fmt.Println("Error sending notification digests:", err)
+		}
+	}
+}
+
+// SendDigests sums up the notifications held back during quiet hours in a
+// single message to each user whose quiet hours are over.
+func (d *Dispatcher) SendDigests(now time.Time) error {
+	held, err := d.store.GetHeldNotis()
+	if err != nil {
+		return err
+	}
+
+	byUser := map[int][]types.NotiPayload{}
+	users := []int{}
+	for _, n := range held {
+		if _, ok := byUser[n.UserID]; !ok {
+			users = append(users, n.UserID)
+		}
+		byUser[n.UserID] = append(byUser[n.UserID], n)
+	}
+
+	for _, userId := range users {
+		if d.quiet(userId, now) {
+			continue
+		}
+
+		notis := byUser[userId]
+		ids := make([]int, len(notis))
+		for i, n := range notis {
+			ids[i] = n.ID
+		}
+		if err := d.store.ReleaseHeldNotis(ids); err != nil {
+			return err
+		}
+
+		locale := d.locale(userId)
+		for _, g := range d.digestGroups(userId, notis) {
+			d.deliverAll(0, userId, digest(locale, g.notis), g.channels)
+		}
+	}
+	return nil
+}
+
+type digestGroup struct {
+	channels map[string]bool
+	notis    []types.NotiPayload
+}
+
+// digestGroups sorts the held notifications by the channels the user wants
+// their events on, each channel gets one digest of the notifications it's
+// allowed for. Channels allowed for the same notifications share it.
+func (d *Dispatcher) digestGroups(userId int, notis []types.NotiPayload) []digestGroup {
+	allowed := map[string]map[string]bool{} // by event
+	byChannel := map[string][]types.NotiPayload{}
+	for _, n := range notis {
+		if _, ok := allowed[n.Event]; !ok {
+			allowed[n.Event] = d.channelsFor(userId, n.Event)
+		}
+		for channel := range allowed[n.Event] {
+			byChannel[channel] = append(byChannel[channel], n)
+		}
+	}
+
+	groups := []digestGroup{}
+	byNotis := map[string]int{} // ids of the notifications -> group
+	for _, channel := range channelTypes {
+		held, ok := byChannel[channel]
+		if !ok {
+			continue
+		}
+		ids := []string{}
+		for _, n := range held {
+			ids = append(ids, strconv.Itoa(n.ID))
+		}
+		key := strings.Join(ids, ",")
+
+		if i, ok := byNotis[key]; ok {
+			groups[i].channels[channel] = true
+			continue
+		}
+		byNotis[key] = len(groups)
+		groups = append(groups, digestGroup{channels: map[string]bool{channel: true}, notis: held})
+	}
+	return groups
+}
+
+// digest lists the notifications in one message, in the user's locale.
+func digest(locale string, notis []types.NotiPayload) Message {
+	lines := []string{}
+	for i, n := range notis {
+		if i == digestLines {
+			more := i18n.Params{"count": strconv.Itoa(len(notis) - digestLines)}
+			lines = append(lines, i18n.Render(locale, "noti.digest.more", more))
+			break
+		}
+		lines = append(lines, "- "+i18n.Localize(locale, n.MessageKey, n.Params, n.Message))
+	}
+
+	return Message{
+		TitleKey: "noti.digest.title",
+		Body:     strings.Join(lines, "\n"),
+		Params:   i18n.Params{"count": strconv.Itoa(len(notis))},
+		Category: CategorySystem,
+	}
+}
@@ -0,0 +1,197 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+func (n *notis) GetNotiPreference(userId int, event string) (*types.NotiPreference, error) {
+	channels, ok := n.prefs[event]
+	if !ok {
+		return nil, nil
+	}
+	return &types.NotiPreference{Event: event, Channels: channels}, nil
+}
+
+func (n *notis) GetQuietHours(userId int) (*types.QuietHours, error) {
+	return n.quiet, nil
+}
+
+func (n *notis) GetHeldNotis() ([]types.NotiPayload, error) {
+	held := []types.NotiPayload{}
+	for _, noti := range n.stored {
+		if noti.HeldForDigest {
+			held = append(held, noti)
+		}
+	}
+	return held, nil
+}
+
+func (n *notis) ReleaseHeldNotis(ids []int) error {
+	for _, id := range ids {
+		n.stored[id-1].HeldForDigest = false
+	}
+	return nil
+}
+
+func TestInQuietHours(t *testing.T) {
+	vn := time.FixedZone("ICT", 7*3600)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, 6, 1, hour, minute, 0, 0, vn)
+	}
+	night := &types.QuietHours{Start: "22:00", End: "07:00", Timezone: "Asia/Ho_Chi_Minh"}
+	nap := &types.QuietHours{Start: "13:00", End: "14:30"}
+
+	for _, c := range []struct {
+		q     *types.QuietHours
+		t     time.Time
+		quiet bool
+	}{
+		{night, at(23, 0), true},
+		{night, at(3, 0), true},
+		{night, at(7, 0), false},
+		{night, at(12, 0), false},
+		{night, at(22, 0).UTC(), true},
+		{nap, at(14, 0), true},
+		{nap, at(14, 30), false},
+		{nil, at(3, 0), false},
+		{&types.QuietHours{Start: "08:00", End: "08:00"}, at(8, 0), false},
+	} {
+		if got := inQuietHours(c.q, c.t); got != c.quiet {
+			t.Errorf("%+v at %v: expected %v", c.q, c.t, c.quiet)
+		}
+	}
+}
+
+func TestNotifyFollowsPreferences(t *testing.T) {
+	store := &notis{
+		tokens: []types.PushToken{{Token: "ExponentPushToken[phone]", LastSeenAt: time.Now()}},
+		channels: []types.NotiChannel{
+			{Type: "webhook", Target: "hook", Enabled: true},
+		},
+		prefs: map[string][]string{
+			ThresholdEvent("humidity"):    {},
+			ThresholdEvent("temperature"): {"webhook"},
+		},
+	}
+	d, double := testDispatcher(t, store)
+	webhook := &flaky{}
+	d.notifiers["webhook"] = webhook
+
+	d.Notify(1, Message{Body: "damp", Event: ThresholdEvent("humidity")})
+	d.Notify(1, Message{Body: "hot", Event: ThresholdEvent("temperature")})
+	d.Wait()
+
+	if len(store.stored) != 1 || store.stored[0].Category != CategoryThreshold {
+		t.Errorf("expected the muted event to be dropped, got %+v", store.stored)
+	}
+	if len(double.pushed) != 0 || len(webhook.targets) != 1 {
+		t.Errorf("expected only the webhook, got %v pushes and %v webhooks", double.pushed, webhook.targets)
+	}
+}
+
+func TestQuietHoursDigest(t *testing.T) {
+	now := time.Now()
+	store := &notis{
+		tokens: []types.PushToken{{Token: "ExponentPushToken[phone]", LastSeenAt: now}},
+		// quiet for the next hour
+		quiet: &types.QuietHours{
+			Start:    now.UTC().Add(-time.Hour).Format("15:04"),
+			End:      now.UTC().Add(time.Hour).Format("15:04"),
+			Timezone: "UTC",
+		},
+	}
+	d, double := testDispatcher(t, store)
+
+	d.Notify(1, Message{Body: "door opened", Event: EventDoor})
+	d.Notify(1, Message{Body: "schedule failed", Event: EventSchedule})
+	d.Notify(1, Message{Body: "fire", Critical: true})
+	d.Wait()
+
+	if len(double.pushed) != 1 {
+		t.Fatalf("expected only the critical alert to get through, got %v", double.pushed)
+	}
+	if !store.stored[0].HeldForDigest || store.stored[0].Category != CategoryDoor {
+		t.Errorf("expected the door event to be held, got %+v", store.stored[0])
+	}
+
+	if err := d.SendDigests(now); err != nil {
+		t.Fatal(err)
+	}
+	d.Wait()
+	if len(double.pushed) != 1 {
+		t.Errorf("expected no digest during quiet hours")
+	}
+
+	if err := d.SendDigests(now.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	d.Wait()
+	if len(double.pushed) != 2 {
+		t.Fatalf("expected a single digest, got %v", double.pushed)
+	}
+	if store.stored[0].HeldForDigest || store.stored[1].HeldForDigest {
+		t.Errorf("expected the held notifications to be released")
+	}
+}
+
+func TestDigestFollowsPreferences(t *testing.T) {
+	now := time.Now()
+	store := &notis{
+		tokens:   []types.PushToken{{Token: "ExponentPushToken[phone]", LastSeenAt: now}},
+		channels: []types.NotiChannel{{Type: "webhook", Target: "hook", Enabled: true}},
+		prefs: map[string][]string{
+			EventDoor:     {"push"},
+			EventSchedule: {"webhook"},
+		},
+		quiet: &types.QuietHours{
+			Start:    now.UTC().Add(-time.Hour).Format("15:04"),
+			End:      now.UTC().Add(time.Hour).Format("15:04"),
+			Timezone: "UTC",
+		},
+	}
+	d, double := testDispatcher(t, store)
+	webhook := &flaky{}
+	d.notifiers["webhook"] = webhook
+
+	d.Notify(1, Message{Body: "door opened", Event: EventDoor})
+	d.Notify(1, Message{Body: "schedule failed", Event: EventSchedule})
+	if err := d.SendDigests(now.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	d.Wait()
+
+	if len(double.pushed) != 1 {
+		t.Errorf("expected a digest on the phone, got %v", double.pushed)
+	}
+	if len(webhook.sent) != 1 || strings.Contains(webhook.sent[0].Body, "door") || !strings.Contains(webhook.sent[0].Body, "schedule failed") {
+		t.Errorf("expected the webhook digest to leave out the door event, got %+v", webhook.sent)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	notis := []types.NotiPayload{{
+		Message:    "[Cửa chính] đã mở",
+		MessageKey: "log.device.door_opened",
+		Params:     i18n.Params{"title": "Front door"},
+	}}
+	for i := 1; i < digestLines+3; i++ {
+		notis = append(notis, types.NotiPayload{Message: "door opened"})
+	}
+
+	msg := digest("en", notis).Localize("en")
+	if msg.Title != "13 notifications during quiet hours" {
+		t.Errorf("expected the count in the title, got %q", msg.Title)
+	}
+	lines := strings.Split(msg.Body, "\n")
+	if len(lines) != digestLines+1 || lines[digestLines] != "... and 3 more" {
+		t.Errorf("expected %d lines and the rest counted, got %q", digestLines, msg.Body)
+	}
+	if lines[0] != "- [Front door] got opened" || lines[1] != "- door opened" {
+		t.Errorf("expected the notifications in the user's locale, got %q", lines[:2])
+	}
+}
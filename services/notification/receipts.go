@@ -0,0 +1,159 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	expo "github.com/oliveroneill/exponent-server-sdk-golang/sdk"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+const (
+	// Expo has the receipt of a push ready within about 15 minutes and
+	// keeps it for a day
+	receiptDelay = 15 * time.Minute
+	receiptTTL   = 24 * time.Hour
+
+	receiptPollInterval = 5 * time.Minute
+	// most ticket ids Expo takes in one request
+	receiptBatch = 1000
+
+	// status of a push
+	pushSent      = "sent"
+	pushDelivered = "delivered"
+	pushError     = "error"
+)
+
+// Receipt is Expo's outcome of a push it accepted.
+type Receipt struct {
+	Status  string `json:"status"` // ok or error
+	Message string `json:"message"`
+	Details struct {
+		Error string `json:"error"`
+	} `json:"details"`
+}
+
+// Receipts looks up the receipts of the tickets, those Expo hasn't got yet
+// are missing from the result.
+func (n *ExpoNotifier) Receipts(ticketIds []string) (map[string]Receipt, error) {
+	body, err := json.Marshal(map[string][]string{"ids": ticketIds})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Post(n.host+expo.DefaultBaseAPIURL+"/push/getReceipts", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("get receipts: %s", resp.Status)
+	}
+
+	var r struct {
+		Data   map[string]Receipt  `json:"data"`
+		Errors []map[string]string `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	if len(r.Errors) > 0 {
+		return nil, fmt.Errorf("get receipts: %v", r.Errors)
+	}
+	return r.Data, nil
+}
+
+// StartReceiptPolling checks the receipts of the pushes every
+// receiptPollInterval.
+func (d *Dispatcher) StartReceiptPolling() {
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := d.PollReceipts(time.Now()); err != nil {
+			fmt.Println("Error polling push receipts:", err)
+		}
+	}
+}
+
+// PollReceipts records whether the pushes still waiting for their receipt
+// reached the phones, and updates the delivery status of their
+// notifications.
+func (d *Dispatcher) PollReceipts(now time.Time) error {
+	tickets, err := d.store.GetPendingPushTickets(now.Add(-receiptTTL), now.Add(-receiptDelay))
+	if err != nil {
+		return err
+	}
+
+	changed := map[int]bool{}
+	for start := 0; start < len(tickets); start += receiptBatch {
+		batch := tickets[start:min(start+receiptBatch, len(tickets))]
+
+		ids := make([]string, len(batch))
+		for i, t := range batch {
+			ids[i] = t.TicketID
+		}
+		receipts, err := d.expo.Receipts(ids)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range batch {
+			r, ok := receipts[t.TicketID]
+			if !ok {
+				continue
+			}
+
+			t.Status, t.Error = pushDelivered, ""
+			if r.Status != expo.SuccessStatus {
+				t.Status, t.Error = pushError, r.Details.Error
+				if t.Error == "" {
+					t.Error = r.Message
+				}
+			}
+			if r.Details.Error == expo.ErrorDeviceNotRegistered {
+				if err := d.store.DeletePushTokenByValue(t.Token); err != nil {
+					log.Println("remove push token:", err)
+				}
+			}
+
+			if err := d.store.UpdatePushTicket(t); err != nil {
+				return err
+			}
+			changed[t.NotiID] = true
+		}
+	}
+
+	for notiId := range changed {
+		tickets, err := d.store.GetPushTicketsByNotiID(notiId)
+		if err != nil {
+			return err
+		}
+		status, reason := deliveryStatus(tickets)
+		if err := d.store.SetNotiDelivery(notiId, status, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliveryStatus sums up the pushes of a notification: delivered once a
+// phone got it, an error when none will.
+func deliveryStatus(tickets []types.PushTicket) (string, string) {
+	status, reason := "", ""
+	for _, t := range tickets {
+		switch {
+		case t.Status == pushDelivered:
+			return pushDelivered, ""
+		case t.Status == pushSent:
+			status, reason = pushSent, ""
+		case status == "":
+			status, reason = pushError, t.Error
+		}
+	}
+	return status, reason
+}
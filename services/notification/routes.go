@@ -1,23 +1,33 @@
 package notification
 
 import (
+	"fmt"
 	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"time"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	expo "github.com/oliveroneill/exponent-server-sdk-golang/sdk"
 	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/services/i18n"
 	"github.com/quanghia24/mySmartHome/types"
 	"github.com/quanghia24/mySmartHome/utils"
 )
 
 type Handler struct {
-	store     types.NotiStore
-	userStore types.UserStore
+	store      types.NotiStore
+	userStore  types.UserStore
+	dispatcher *Dispatcher
 }
 
-func NewHandler(store types.NotiStore, userStore types.UserStore) *Handler {
+func NewHandler(store types.NotiStore, userStore types.UserStore, dispatcher *Dispatcher) *Handler {
 	return &Handler{
-		store:     store,
-		userStore: userStore,
+		store:      store,
+		userStore:  userStore,
+		dispatcher: dispatcher,
 	}
 }
 
@@ -25,20 +35,66 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/noti-ip", auth.WithJWTAuth(h.handleGetNotiIp, h.userStore)).Methods(http.MethodGet)
 	router.HandleFunc("/noti-ip", auth.WithJWTAuth(h.handleCreateNotiIp, h.userStore)).Methods(http.MethodPost)
 
+	router.HandleFunc("/noti/tokens", auth.WithJWTAuth(h.handleGetPushTokens, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/noti/tokens", auth.WithJWTAuth(h.handleRegisterPushToken, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/noti/tokens/{id}", auth.WithJWTAuth(h.handleDeletePushToken, h.userStore)).Methods(http.MethodDelete)
+
 	router.HandleFunc("/noti", auth.WithJWTAuth(h.handleGetNoti, h.userStore)).Methods(http.MethodGet)
 	router.HandleFunc("/noti", auth.WithJWTAuth(h.handleCreateNoti, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/noti/unread-count", auth.WithJWTAuth(h.handleGetUnreadCount, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/noti/categories", auth.WithJWTAuth(h.handleGetNotiCounts, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/noti/preferences", auth.WithJWTAuth(h.handleGetPreferences, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/noti/preferences/{event}", auth.WithJWTAuth(h.handleSetPreference, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/noti/quiet-hours", auth.WithJWTAuth(h.handleGetQuietHours, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/noti/quiet-hours", auth.WithJWTAuth(h.handleSetQuietHours, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/noti/quiet-hours", auth.WithJWTAuth(h.handleDeleteQuietHours, h.userStore)).Methods(http.MethodDelete)
+	router.HandleFunc("/noti/read-all", auth.WithJWTAuth(h.handleMarkAllRead, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/noti/{id:[0-9]+}/read", auth.WithJWTAuth(h.handleMarkRead, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/noti/{id:[0-9]+}/unread", auth.WithJWTAuth(h.handleMarkUnread, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/noti/{id:[0-9]+}", auth.WithJWTAuth(h.handleDeleteNoti, h.userStore)).Methods(http.MethodDelete)
+
+	router.HandleFunc("/noti/channels", auth.WithJWTAuth(h.handleGetChannels, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/noti/channels", auth.WithJWTAuth(h.handleCreateChannel, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/noti/channels/{id}", auth.WithJWTAuth(h.handleUpdateChannel, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/noti/channels/{id}", auth.WithJWTAuth(h.handleDeleteChannel, h.userStore)).Methods(http.MethodDelete)
+	router.HandleFunc("/noti/channels/{id}/test", auth.WithJWTAuth(h.handleTestChannel, h.userStore)).Methods(http.MethodPost)
 }
 
+// handleGetNoti lists the user's notifications newest first, a page at a
+// time: ?before= takes the id of the last one of the previous page. They can
+// be narrowed to a ?category= and to the &unread=true ones.
 func (h *Handler) handleGetNoti(w http.ResponseWriter, r *http.Request) {
 	userId := auth.GetUserIDFromContext(r.Context())
-	notis, err := h.store.GetNotiByUserId(userId)
+	filter, err := parseNotiFilter(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	notis, err := h.store.GetNotiByUserId(userId, filter)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return 
 	}
+
+	locale := h.readerLocale(r, userId)
+	for i, n := range notis {
+		notis[i].Message = i18n.Localize(locale, n.MessageKey, n.Params, n.Message)
+	}
 	utils.WriteJSON(w, http.StatusOK, notis)
 }
 
+// readerLocale is the locale the user reads their notifications in.
+func (h *Handler) readerLocale(r *http.Request, userId int) string {
+	u, err := h.userStore.GetUserByID(userId)
+	if err != nil {
+		return i18n.FromRequest(r, "")
+	}
+	return i18n.FromRequest(r, u.Locale)
+}
+
+// handleCreateNoti notifies the user like the events of their home do, so
+// the notification follows their preferences and quiet hours.
 func (h *Handler) handleCreateNoti(w http.ResponseWriter, r *http.Request) {
 	userId := auth.GetUserIDFromContext(r.Context())
 	var payload types.NotiPayload
@@ -46,34 +102,313 @@ func (h *Handler) handleCreateNoti(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
-	ip, err := h.store.GetNotiIpByUserId(userId)
+	payload.UserID = userId
+	if err := utils.Validate.Struct(payload); err != nil {
+		error := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", error))
+		return
+	}
+
+	msg := Message{Body: payload.Message, BodyKey: payload.MessageKey, Params: payload.Params, Category: payload.Category}
+	switch payload.Category {
+	case CategoryDoor:
+		msg.Event = EventDoor
+	case CategorySchedule:
+		msg.Event = EventSchedule
+	}
+	h.dispatcher.Notify(userId, msg)
+
+	utils.WriteJSON(w, http.StatusOK, payload)
+}
+
+const (
+	defaultNotiPage = 50
+	maxNotiPage     = 200
+)
+
+var categories = map[string]bool{
+	CategoryThreshold: true,
+	CategoryDoor:      true,
+	CategorySchedule:  true,
+	CategorySystem:    true,
+}
+
+func parseNotiFilter(r *http.Request) (types.NotiFilter, error) {
+	q := r.URL.Query()
+	filter := types.NotiFilter{
+		Category: q.Get("category"),
+		Unread:   q.Get("unread") == "true",
+		Limit:    defaultNotiPage,
+	}
+	if filter.Category != "" && !categories[filter.Category] {
+		return filter, fmt.Errorf("unknown category %q", filter.Category)
+	}
+
+	if v := q.Get("before"); v != "" {
+		before, err := strconv.Atoi(v)
+		if err != nil || before <= 0 {
+			return filter, fmt.Errorf("invalid before %q", v)
+		}
+		filter.Before = before
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxNotiPage {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxNotiPage)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// handleGetUnreadCount is the badge of the app icon.
+func (h *Handler) handleGetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	unread, err := h.store.CountUnreadNoti(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]int{"unread": unread})
+}
+
+// handleGetNotiCounts counts the notifications, and the unread ones, of
+// each category.
+func (h *Handler) handleGetNotiCounts(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	counts, err := h.store.GetNotiCounts(userId)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
-	payload.Ip = ip.Ip
-	payload.UserID = userId
 
-	if err := h.store.CreateNoti(payload); err != nil {
+	// every category is listed, even without notifications
+	all := []types.NotiCount{}
+	for _, category := range []string{CategoryThreshold, CategoryDoor, CategorySchedule, CategorySystem} {
+		c := types.NotiCount{Category: category}
+		for _, counted := range counts {
+			if counted.Category == category {
+				c = counted
+			}
+		}
+		all = append(all, c)
+	}
+	utils.WriteJSON(w, http.StatusOK, all)
+}
+
+// handleMarkAllRead marks the notifications of the ?category=, or all of
+// them, read.
+func (h *Handler) handleMarkAllRead(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	category := r.URL.Query().Get("category")
+	if category != "" && !categories[category] {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown category %q", category))
+		return
+	}
+
+	marked, err := h.store.MarkAllNotiRead(userId, category)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]int64{"marked": marked})
+}
+
+func (h *Handler) handleMarkRead(w http.ResponseWriter, r *http.Request) {
+	h.setRead(w, r, true)
+}
+
+func (h *Handler) handleMarkUnread(w http.ResponseWriter, r *http.Request) {
+	h.setRead(w, r, false)
+}
+
+func (h *Handler) setRead(w http.ResponseWriter, r *http.Request, read bool) {
+	noti, ok := h.ownedNoti(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.SetNotiRead(noti.ID, read); err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
-		return	
+		return
+	}
+
+	noti, err := h.store.GetNoti(noti.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, noti)
+}
+
+func (h *Handler) handleDeleteNoti(w http.ResponseWriter, r *http.Request) {
+	noti, ok := h.ownedNoti(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteNoti(noti.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, "notification deleted")
+}
+
+// ownedNoti loads the notification of the path, writing the error response
+// when it isn't the user's.
+func (h *Handler) ownedNoti(w http.ResponseWriter, r *http.Request) (*types.NotiPayload, bool) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid notification id"))
+		return nil, false
+	}
+
+	noti, err := h.store.GetNoti(id)
+	if err != nil || noti.UserID != userId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("notification %d not found", id))
+		return nil, false
+	}
+	return noti, true
+}
+
+// handleGetPreferences lists the channels of every event, and the quiet
+// hours.
+func (h *Handler) handleGetPreferences(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	saved, err := h.store.GetNotiPreferences(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	quietHours, err := h.store.GetQuietHours(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	prefs := []types.NotiPreference{}
+	for _, event := range Events() {
+		p := types.NotiPreference{Event: event, Channels: channelTypes}
+		for _, s := range saved {
+			if s.Event == event {
+				p = s
+			}
+		}
+		prefs = append(prefs, p)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"events":     prefs,
+		"quietHours": quietHours,
+	})
+}
+
+// handleSetPreference sets the channels of an event, none mutes it.
+func (h *Handler) handleSetPreference(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	event := mux.Vars(r)["event"]
+	if !isEvent(event) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown event %q", event))
+		return
+	}
+
+	var payload types.SetNotiPreferencePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		error := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", error))
+		return
+	}
+
+	p := types.NotiPreference{Event: event, Channels: payload.Channels}
+	if err := h.store.SaveNotiPreference(userId, p); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, p)
+}
+
+func (h *Handler) handleGetQuietHours(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	quietHours, err := h.store.GetQuietHours(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, quietHours)
+}
+
+// handleSetQuietHours sets the quiet hours, in Vietnam's time unless
+// another timezone is given.
+func (h *Handler) handleSetQuietHours(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+
+	var payload types.QuietHours
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		error := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", error))
+		return
+	}
+	if payload.Start == payload.End {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("quiet hours can't start and end at the same time"))
+		return
+	}
+	if payload.Timezone == "" {
+		payload.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(payload.Timezone); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown timezone %q", payload.Timezone))
+		return
+	}
+
+	if err := h.store.SaveQuietHours(userId, payload); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, payload)
 }
 
+func (h *Handler) handleDeleteQuietHours(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	if err := h.store.DeleteQuietHours(userId); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, "quiet hours removed")
+}
+
+// handleGetNotiIp returns the most recently seen push token, for app
+// versions from before a user could have several.
 func (h *Handler) handleGetNotiIp(w http.ResponseWriter, r *http.Request) {
 	userId := auth.GetUserIDFromContext(r.Context())
-	noti, err := h.store.GetNotiIpByUserId(userId)
+	tokens, err := h.store.GetPushTokensByUserID(userId)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
+	if len(tokens) == 0 {
+		utils.WriteJSON(w, http.StatusOK, nil)
+		return
+	}
 
-	// var payload types.
-	utils.WriteJSON(w, http.StatusOK, noti)
+	utils.WriteJSON(w, http.StatusOK, types.NotiIpPayload{
+		UserID: userId,
+		Ip:     tokens[0].Token,
+	})
 }
 
+// handleCreateNotiIp registers the push token of app versions from before
+// /noti/tokens.
 func (h *Handler) handleCreateNotiIp(w http.ResponseWriter, r *http.Request) {
 	userId := auth.GetUserIDFromContext(r.Context())
 
@@ -83,16 +418,228 @@ func (h *Handler) handleCreateNotiIp(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
+	if _, err := expo.NewExponentPushToken(payload.Ip); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
 
-	err := h.store.CreateNotiIp(types.NotiIpPayload{
+	err := h.store.SavePushToken(types.PushToken{
 		UserID: userId,
-		Ip:     payload.Ip,
+		Token:  payload.Ip,
 	})
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
-	// var payload types.
 	utils.WriteJSON(w, http.StatusOK, "ip updated")
 }
 
+func (h *Handler) handleGetPushTokens(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	tokens, err := h.store.GetPushTokensByUserID(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, tokens)
+}
+
+// handleRegisterPushToken adds the phone's token, the app calls it on every
+// start so the token stays active.
+func (h *Handler) handleRegisterPushToken(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+
+	var payload types.RegisterPushTokenPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		error := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", error))
+		return
+	}
+	if _, err := expo.NewExponentPushToken(payload.Token); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.store.SavePushToken(types.PushToken{
+		UserID:     userId,
+		Token:      payload.Token,
+		Platform:   payload.Platform,
+		AppVersion: payload.AppVersion,
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, "token registered")
+}
+
+// handleDeletePushToken stops pushing to a phone, on logout.
+func (h *Handler) handleDeletePushToken(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid token id"))
+		return
+	}
+
+	t, err := h.store.GetPushToken(id)
+	if err != nil || t.UserID != userId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("push token %d not found", id))
+		return
+	}
+
+	if err := h.store.DeletePushToken(id); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, "token deleted")
+}
+
+func (h *Handler) handleGetChannels(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	channels, err := h.store.GetChannelsByUserID(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, channels)
+}
+
+func (h *Handler) handleCreateChannel(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+
+	var payload types.CreateNotiChannelPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		error := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", error))
+		return
+	}
+	if err := validateTarget(payload.Type, payload.Target); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.store.CreateChannel(types.NotiChannel{
+		UserID:         userId,
+		Type:           payload.Type,
+		Target:         payload.Target,
+		EscalationOnly: payload.EscalationOnly,
+		Enabled:        true,
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, "channel added")
+}
+
+func (h *Handler) handleUpdateChannel(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.ownedChannel(w, r)
+	if !ok {
+		return
+	}
+
+	var payload types.UpdateNotiChannelPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if payload.EscalationOnly != nil {
+		c.EscalationOnly = *payload.EscalationOnly
+	}
+	if payload.Enabled != nil {
+		c.Enabled = *payload.Enabled
+	}
+
+	if err := h.store.UpdateChannel(*c); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, c)
+}
+
+func (h *Handler) handleDeleteChannel(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.ownedChannel(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteChannel(c.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, "channel deleted")
+}
+
+// handleTestChannel sends a test message on the channel, so the user can
+// check it reaches them.
+func (h *Handler) handleTestChannel(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.ownedChannel(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.dispatcher.Test(*c, Message{
+		TitleKey: "noti.test.title",
+		BodyKey:  "noti.test.body",
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusBadGateway, fmt.Errorf("%s channel failed: %v", c.Type, err))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, "test sent")
+}
+
+// ownedChannel loads the channel of the path, writing the error response
+// when it isn't the user's.
+func (h *Handler) ownedChannel(w http.ResponseWriter, r *http.Request) (*types.NotiChannel, bool) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid channel id"))
+		return nil, false
+	}
+
+	c, err := h.store.GetChannel(id)
+	if err != nil || c.UserID != userId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("channel %d not found", id))
+		return nil, false
+	}
+	return c, true
+}
+
+// Telegram chat ids are numbers, negative for groups, or @channelname
+var telegramChat = regexp.MustCompile(`^(-?[0-9]+|@[A-Za-z0-9_]{5,})$`)
+
+// validateTarget checks the target fits its channel.
+func validateTarget(channel string, target string) error {
+	switch channel {
+	case "email":
+		if _, err := mail.ParseAddress(target); err != nil {
+			return fmt.Errorf("invalid email address %q", target)
+		}
+	case "webhook":
+		if err := utils.ValidatePublicURL(target); err != nil {
+			return fmt.Errorf("invalid webhook url: %v", err)
+		}
+	case "telegram":
+		if !telegramChat.MatchString(target) {
+			return fmt.Errorf("invalid telegram chat id %q", target)
+		}
+	}
+	return nil
+}
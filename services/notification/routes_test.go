@@ -0,0 +1,28 @@
+package notification
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseNotiFilter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/noti?category=door&unread=true&before=120&limit=20", nil)
+	filter, err := parseNotiFilter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.Category != CategoryDoor || !filter.Unread || filter.Before != 120 || filter.Limit != 20 {
+		t.Errorf("unexpected filter %+v", filter)
+	}
+
+	filter, err = parseNotiFilter(httptest.NewRequest("GET", "/noti", nil))
+	if err != nil || filter.Limit != defaultNotiPage || filter.Before != 0 || filter.Unread {
+		t.Errorf("expected the first page by default, got %+v, %v", filter, err)
+	}
+
+	for _, query := range []string{"category=weather", "before=abc", "before=-1", "limit=0", "limit=1000"} {
+		if _, err := parseNotiFilter(httptest.NewRequest("GET", "/noti?"+query, nil)); err == nil {
+			t.Errorf("expected %s to be rejected", query)
+		}
+	}
+}
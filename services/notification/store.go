@@ -3,7 +3,10 @@ package notification
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/quanghia24/mySmartHome/services/i18n"
 	"github.com/quanghia24/mySmartHome/types"
 )
 
@@ -17,36 +20,153 @@ func NewStore(db *sql.DB) *Store {
 	}
 }
 
-func (s *Store) CreateNotiIp(noti types.NotiIpPayload) error {
-	_, err := s.db.Exec("INSERT INTO `noti-ip` (userId, ip) VALUES (?, ?) ON DUPLICATE KEY UPDATE ip = VALUES(ip)", noti.UserID, noti.Ip)
+// SavePushToken registers the token, or refreshes it when the phone
+// registered before, even for another account.
+func (s *Store) SavePushToken(t types.PushToken) error {
+	_, err := s.db.Exec(`INSERT INTO push_tokens (userId, token, platform, appVersion) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE userId = VALUES(userId), platform = VALUES(platform), appVersion = VALUES(appVersion), lastSeenAt = CURRENT_TIMESTAMP`,
+		t.UserID, t.Token, t.Platform, t.AppVersion)
 	return err
 }
 
-func (s *Store) GetNotiIpByUserId(userId int) (*types.NotiIpPayload, error) {
-	row := s.db.QueryRow("SELECT userId, ip FROM `noti-ip` WHERE userId = ? LIMIT 1", userId)
+func (s *Store) GetPushToken(id int) (*types.PushToken, error) {
+	rows, err := s.db.Query("SELECT id, userId, token, platform, appVersion, lastSeenAt, createdAt FROM push_tokens WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	noti := new(types.NotiIpPayload)
-	err := row.Scan(&noti.UserID, &noti.Ip)
-	if err == sql.ErrNoRows {
-		fmt.Println("no rows have been found")
-		return nil, nil
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("push token %d not found", id)
 	}
+	return scanRowIntoPushToken(rows)
+}
+
+// GetPushTokensByUserID lists the user's tokens, the most recently seen
+// first.
+func (s *Store) GetPushTokensByUserID(userId int) ([]types.PushToken, error) {
+	rows, err := s.db.Query("SELECT id, userId, token, platform, appVersion, lastSeenAt, createdAt FROM push_tokens WHERE userId = ? ORDER BY lastSeenAt DESC", userId)
 	if err != nil {
 		return nil, err
 	}
-	return noti, nil
+	defer rows.Close()
+
+	tokens := []types.PushToken{}
+	for rows.Next() {
+		t, err := scanRowIntoPushToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *Store) DeletePushToken(id int) error {
+	_, err := s.db.Exec("DELETE FROM push_tokens WHERE id = ?", id)
+	return err
 }
 
-func (s *Store) CreateNoti(noti types.NotiPayload) error {
-	_, err := s.db.Exec("insert into noti (userId, ip, message) values (?, ?, ?)", noti.UserID, noti.Ip, noti.Message)
+func (s *Store) DeletePushTokenByValue(token string) error {
+	_, err := s.db.Exec("DELETE FROM push_tokens WHERE token = ?", token)
 	return err
 }
 
-func (s *Store) GetNotiByUserId(userId int) ([]types.NotiPayload, error) {
-	rows, err := s.db.Query("select * from noti where userId = ?", userId)
+func scanRowIntoPushToken(rows *sql.Rows) (*types.PushToken, error) {
+	t := new(types.PushToken)
+	err := rows.Scan(
+		&t.ID,
+		&t.UserID,
+		&t.Token,
+		&t.Platform,
+		&t.AppVersion,
+		&t.LastSeenAt,
+		&t.CreatedAt,
+	)
 	if err != nil {
 		return nil, err
 	}
+	return t, nil
+}
+
+func (s *Store) CreateNoti(noti types.NotiPayload) (int, error) {
+	if noti.Category == "" {
+		noti.Category = "system"
+	}
+	// the default locale is kept for the readers of the plain message
+	noti.Message = i18n.Localize(i18n.DefaultLocale, noti.MessageKey, noti.Params, noti.Message)
+	res, err := s.db.Exec("insert into noti (userId, ip, message, messageKey, params, category, heldForDigest, event) values (?, ?, ?, nullif(?, ''), ?, ?, ?, nullif(?, ''))", noti.UserID, noti.Ip, noti.Message, noti.MessageKey, noti.Params, noti.Category, noti.HeldForDigest, noti.Event)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+const selectNoti = "select id, userId, ip, message, coalesce(messageKey, ''), params, category, readAt, heldForDigest, coalesce(event, ''), createdAt, deliveryStatus, deliveryError from noti"
+
+func (s *Store) GetNoti(id int) (*types.NotiPayload, error) {
+	rows, err := s.db.Query(selectNoti+" where id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("notification %d not found", id)
+	}
+	return scanRowIntoNoti(rows)
+}
+
+func (s *Store) GetNotiByUserId(userId int, filter types.NotiFilter) ([]types.NotiPayload, error) {
+	query := selectNoti + " where userId = ?"
+	args := []any{userId}
+	if filter.Category != "" {
+		query += " and category = ?"
+		args = append(args, filter.Category)
+	}
+	if filter.Unread {
+		query += " and readAt is null"
+	}
+	if filter.Before > 0 {
+		query += " and id < ?"
+		args = append(args, filter.Before)
+	}
+	query += " order by id desc limit ?"
+	args = append(args, filter.Limit)
+
+	return s.queryNotis(query, args...)
+}
+
+func (s *Store) GetHeldNotis() ([]types.NotiPayload, error) {
+	return s.queryNotis(selectNoti + " where heldForDigest order by id")
+}
+
+func (s *Store) ReleaseHeldNotis(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	_, err := s.db.Exec("update noti set heldForDigest = false where id in (?"+strings.Repeat(", ?", len(ids)-1)+")", args...)
+	return err
+}
+
+func (s *Store) queryNotis(query string, args ...any) ([]types.NotiPayload, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	notis := []types.NotiPayload{}
 	for rows.Next() {
@@ -57,17 +177,274 @@ func (s *Store) GetNotiByUserId(userId int) ([]types.NotiPayload, error) {
 		notis = append(notis, *n)
 	}
 
-	return notis, nil
+	return notis, rows.Err()
+}
+
+func (s *Store) GetNotiCounts(userId int) ([]types.NotiCount, error) {
+	rows, err := s.db.Query("select category, count(*), count(*) - count(readAt) from noti where userId = ? group by category order by category", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []types.NotiCount{}
+	for rows.Next() {
+		var c types.NotiCount
+		if err := rows.Scan(&c.Category, &c.Total, &c.Unread); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+func (s *Store) CountUnreadNoti(userId int) (int, error) {
+	var unread int
+	err := s.db.QueryRow("select count(*) from noti where userId = ? and readAt is null", userId).Scan(&unread)
+	return unread, err
+}
+
+func (s *Store) SetNotiRead(id int, read bool) error {
+	if read {
+		_, err := s.db.Exec("update noti set readAt = coalesce(readAt, current_timestamp) where id = ?", id)
+		return err
+	}
+	_, err := s.db.Exec("update noti set readAt = null where id = ?", id)
+	return err
+}
+
+func (s *Store) MarkAllNotiRead(userId int, category string) (int64, error) {
+	query := "update noti set readAt = current_timestamp where userId = ? and readAt is null"
+	args := []any{userId}
+	if category != "" {
+		query += " and category = ?"
+		args = append(args, category)
+	}
+
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *Store) DeleteNoti(id int) error {
+	_, err := s.db.Exec("delete from noti where id = ?", id)
+	return err
+}
+
+func (s *Store) CreateChannel(c types.NotiChannel) error {
+	_, err := s.db.Exec("INSERT INTO notification_channels (userId, type, target, escalationOnly, enabled) VALUES (?, ?, ?, ?, ?)", c.UserID, c.Type, c.Target, c.EscalationOnly, c.Enabled)
+	return err
+}
+
+func (s *Store) GetChannel(id int) (*types.NotiChannel, error) {
+	rows, err := s.db.Query("SELECT id, userId, type, target, escalationOnly, enabled, createdAt FROM notification_channels WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("channel %d not found", id)
+	}
+	return scanRowIntoChannel(rows)
+}
+
+func (s *Store) GetChannelsByUserID(userId int) ([]types.NotiChannel, error) {
+	rows, err := s.db.Query("SELECT id, userId, type, target, escalationOnly, enabled, createdAt FROM notification_channels WHERE userId = ? ORDER BY id", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []types.NotiChannel{}
+	for rows.Next() {
+		c, err := scanRowIntoChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, *c)
+	}
+	return channels, rows.Err()
+}
+
+func (s *Store) UpdateChannel(c types.NotiChannel) error {
+	_, err := s.db.Exec("UPDATE notification_channels SET escalationOnly = ?, enabled = ? WHERE id = ?", c.EscalationOnly, c.Enabled, c.ID)
+	return err
+}
+
+func (s *Store) DeleteChannel(id int) error {
+	_, err := s.db.Exec("DELETE FROM notification_channels WHERE id = ?", id)
+	return err
+}
+
+func scanRowIntoChannel(rows *sql.Rows) (*types.NotiChannel, error) {
+	c := new(types.NotiChannel)
+	err := rows.Scan(
+		&c.ID,
+		&c.UserID,
+		&c.Type,
+		&c.Target,
+		&c.EscalationOnly,
+		&c.Enabled,
+		&c.CreatedAt,
+	)
+	return c, err
+}
+
+func (s *Store) GetNotiPreferences(userId int) ([]types.NotiPreference, error) {
+	rows, err := s.db.Query("SELECT event, channels FROM notification_preferences WHERE userId = ? ORDER BY event", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := []types.NotiPreference{}
+	for rows.Next() {
+		var p types.NotiPreference
+		var channels string
+		if err := rows.Scan(&p.Event, &channels); err != nil {
+			return nil, err
+		}
+		p.Channels = splitSet(channels)
+		prefs = append(prefs, p)
+	}
+	return prefs, rows.Err()
+}
+
+// GetNotiPreference returns nil when the user kept the event's defaults.
+func (s *Store) GetNotiPreference(userId int, event string) (*types.NotiPreference, error) {
+	var channels string
+	err := s.db.QueryRow("SELECT channels FROM notification_preferences WHERE userId = ? AND event = ?", userId, event).Scan(&channels)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &types.NotiPreference{Event: event, Channels: splitSet(channels)}, nil
+}
+
+func (s *Store) SaveNotiPreference(userId int, p types.NotiPreference) error {
+	_, err := s.db.Exec("INSERT INTO notification_preferences (userId, event, channels) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE channels = VALUES(channels)",
+		userId, p.Event, strings.Join(p.Channels, ","))
+	return err
+}
+
+// splitSet reads the value of a SET column.
+func splitSet(v string) []string {
+	if v == "" {
+		return []string{}
+	}
+	return strings.Split(v, ",")
+}
+
+// GetQuietHours returns nil when the user has none.
+func (s *Store) GetQuietHours(userId int) (*types.QuietHours, error) {
+	q := new(types.QuietHours)
+	err := s.db.QueryRow("SELECT TIME_FORMAT(startTime, '%H:%i'), TIME_FORMAT(endTime, '%H:%i'), timezone FROM quiet_hours WHERE userId = ?", userId).
+		Scan(&q.Start, &q.End, &q.Timezone)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (s *Store) SaveQuietHours(userId int, q types.QuietHours) error {
+	_, err := s.db.Exec(`INSERT INTO quiet_hours (userId, startTime, endTime, timezone) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE startTime = VALUES(startTime), endTime = VALUES(endTime), timezone = VALUES(timezone)`,
+		userId, q.Start, q.End, q.Timezone)
+	return err
+}
+
+func (s *Store) DeleteQuietHours(userId int) error {
+	_, err := s.db.Exec("DELETE FROM quiet_hours WHERE userId = ?", userId)
+	return err
+}
+
+func (s *Store) SetNotiDelivery(id int, status string, reason string) error {
+	_, err := s.db.Exec("UPDATE noti SET deliveryStatus = ?, deliveryError = NULLIF(?, '') WHERE id = ?", status, reason, id)
+	return err
 }
 
 func scanRowIntoNoti(rows *sql.Rows) (*types.NotiPayload, error) {
 	noti := new(types.NotiPayload)
+	var status, reason sql.NullString
+	var readAt sql.NullTime
 	err := rows.Scan(
 		&noti.ID,
 		&noti.UserID,
 		&noti.Ip,
 		&noti.Message,
+		&noti.MessageKey,
+		&noti.Params,
+		&noti.Category,
+		&readAt,
+		&noti.HeldForDigest,
+		&noti.Event,
 		&noti.CreatedAt,
+		&status,
+		&reason,
 	)
+	if readAt.Valid {
+		noti.Read = true
+		noti.ReadAt = &readAt.Time
+	}
+	noti.DeliveryStatus = status.String
+	noti.DeliveryError = reason.String
 	return noti, err
 }
+
+func (s *Store) CreatePushTicket(t types.PushTicket) error {
+	_, err := s.db.Exec("INSERT INTO push_tickets (notiId, token, ticketId, status, error) VALUES (?, ?, NULLIF(?, ''), ?, NULLIF(?, ''))",
+		t.NotiID, t.Token, t.TicketID, t.Status, t.Error)
+	return err
+}
+
+const selectPushTicket = "SELECT id, notiId, token, ticketId, status, error, createdAt, checkedAt FROM push_tickets"
+
+func (s *Store) GetPushTicketsByNotiID(notiId int) ([]types.PushTicket, error) {
+	return s.queryPushTickets(selectPushTicket+" WHERE notiId = ?", notiId)
+}
+
+func (s *Store) GetPendingPushTickets(from time.Time, to time.Time) ([]types.PushTicket, error) {
+	return s.queryPushTickets(selectPushTicket+" WHERE status = 'sent' AND ticketId IS NOT NULL AND createdAt >= ? AND createdAt < ? ORDER BY id", from, to)
+}
+
+func (s *Store) UpdatePushTicket(t types.PushTicket) error {
+	_, err := s.db.Exec("UPDATE push_tickets SET status = ?, error = NULLIF(?, ''), checkedAt = CURRENT_TIMESTAMP WHERE id = ?", t.Status, t.Error, t.ID)
+	return err
+}
+
+func (s *Store) queryPushTickets(query string, args ...any) ([]types.PushTicket, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tickets := []types.PushTicket{}
+	for rows.Next() {
+		var t types.PushTicket
+		var ticketId, reason sql.NullString
+		var checkedAt sql.NullTime
+		err := rows.Scan(&t.ID, &t.NotiID, &t.Token, &ticketId, &t.Status, &reason, &t.CreatedAt, &checkedAt)
+		if err != nil {
+			return nil, err
+		}
+		t.TicketID = ticketId.String
+		t.Error = reason.String
+		if checkedAt.Valid {
+			t.CheckedAt = &checkedAt.Time
+		}
+		tickets = append(tickets, t)
+	}
+	return tickets, rows.Err()
+}
@@ -0,0 +1,52 @@
+package notification
+
+import (
+	"fmt"
+
+	"github.com/quanghia24/mySmartHome/services/alert"
+	"github.com/quanghia24/mySmartHome/services/events"
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/services/plan"
+)
+
+// Subscribe tells the owners of the sensors about the threshold breaches and
+// the unusual readings on the bus, on the channels they chose for the
+// sensor's type. Critical breaches get through their quiet hours.
+func (d *Dispatcher) Subscribe(bus *events.Bus) {
+	bus.ThresholdCrossed.Subscribe(func(e events.ThresholdCrossed) {
+		if !e.Event.Notify {
+			return
+		}
+
+		msg := Message{
+			TitleKey: "noti.threshold.title",
+			BodyKey:  "noti.threshold." + e.Event.Bound,
+			Params: i18n.Params{
+				"type":      e.Sensor.Type,
+				"value":     fmt.Sprint(e.Event.Value),
+				"threshold": fmt.Sprint(e.Event.Threshold),
+				"bound":     e.Event.Bound,
+			},
+			Event:    ThresholdEvent(e.Sensor.Type),
+			Critical: e.Event.Severity == alert.SeverityCritical,
+		}
+		if e.Event.Kind == plan.AlertCleared {
+			msg.TitleKey, msg.BodyKey, msg.Critical = "noti.cleared.title", "noti.cleared.body", false
+		}
+		d.Notify(e.Sensor.UserID, msg)
+	})
+
+	bus.AnomalyDetected.Subscribe(func(e events.AnomalyDetected) {
+		d.Notify(e.Sensor.UserID, Message{
+			TitleKey: "noti.anomaly.title",
+			BodyKey:  "noti.anomaly.body",
+			Params: i18n.Params{
+				"type":     e.Sensor.Type,
+				"value":    fmt.Sprint(e.Value),
+				"expected": fmt.Sprintf("%.1f", e.Expected),
+				"stddev":   fmt.Sprintf("%.1f", e.StdDev),
+			},
+			Event: ThresholdEvent(e.Sensor.Type),
+		})
+	})
+}
@@ -0,0 +1,134 @@
+package plan
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+type EventKind string
+
+const (
+	AlertStarted EventKind = "started"
+	AlertCleared EventKind = "cleared"
+)
+
+// Event is the start or the end of an episode in which a sensor stayed past
+// one of its plan's bounds.
+type Event struct {
+	Kind      EventKind
+	Bound     string // "lower" or "upper"
+	Threshold float64
+	Value     float64
+	Since     time.Time // when the bound was first crossed
+	Severity  string    // of the plan
+	// false when the alert started within the plan's cooldown, its end is
+	// not notified either
+	Notify bool
+}
+
+// episode is the state of a sensor's plan between readings.
+type episode struct {
+	bound        string // the bound being crossed, empty when within bounds
+	threshold    float64
+	since        time.Time
+	active       bool // the crossing lasted long enough to alert
+	notified     bool
+	lastNotified time.Time
+}
+
+// Evaluator turns the readings of a sensor into alert episodes, so a value
+// hovering around a bound alerts once instead of on every message. Episodes
+// are kept by sensor, a plan that is edited replaces the sensor's plan and
+// carries its episode on.
+type Evaluator struct {
+	mu     sync.Mutex
+	states map[int]*episode // by sensor feed id
+}
+
+func NewEvaluator() *Evaluator {
+	return &Evaluator{
+		states: map[int]*episode{},
+	}
+}
+
+// Observe feeds a reading to the plan and returns the event it causes, if
+// any.
+func (e *Evaluator) Observe(p types.Plan, value float64, at time.Time) *Event {
+	lower, hasLower := parseBound(p.Lower)
+	upper, hasUpper := parseBound(p.Upper)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	st, ok := e.states[p.SensorID]
+	if !ok {
+		st = &episode{}
+		e.states[p.SensorID] = st
+	}
+
+	// the plan may have been edited since the episode started, it goes on
+	// against the new bound or ends with the bound
+	ended := st.bound == "upper" && !hasUpper || st.bound == "lower" && !hasLower
+	if st.bound == "upper" && hasUpper {
+		st.threshold = upper
+	}
+	if st.bound == "lower" && hasLower {
+		st.threshold = lower
+	}
+
+	// an episode lasts until the value is back past the bound by the
+	// hysteresis, a crossing that ends before alerting is dropped
+	if ended || st.bound == "upper" && value <= st.threshold-p.Hysteresis ||
+		st.bound == "lower" && value >= st.threshold+p.Hysteresis {
+		active := st.active
+		ev := &Event{Kind: AlertCleared, Bound: st.bound, Threshold: st.threshold, Value: value, Since: st.since, Severity: p.Severity, Notify: st.notified}
+		st.bound, st.active, st.notified = "", false, false
+		if active {
+			return ev
+		}
+	}
+
+	if st.bound == "" {
+		switch {
+		case hasUpper && value > upper:
+			st.bound, st.threshold = "upper", upper
+		case hasLower && value < lower:
+			st.bound, st.threshold = "lower", lower
+		default:
+			return nil
+		}
+		st.since = at
+	}
+
+	minDuration := time.Duration(p.MinDurationSeconds) * time.Second
+	if st.active || at.Sub(st.since) < minDuration {
+		return nil
+	}
+
+	cooldown := time.Duration(p.CooldownSeconds) * time.Second
+	st.active = true
+	st.notified = st.lastNotified.IsZero() || at.Sub(st.lastNotified) >= cooldown
+	if st.notified {
+		st.lastNotified = at
+	}
+	return &Event{Kind: AlertStarted, Bound: st.bound, Threshold: st.threshold, Value: value, Since: st.since, Severity: p.Severity, Notify: st.notified}
+}
+
+// Forget drops the episode of a sensor whose plan was removed.
+func (e *Evaluator) Forget(sensorId int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.states, sensorId)
+}
+
+// parseBound reads a bound of a plan, an empty bound is not set.
+func parseBound(v string) (float64, bool) {
+	if v == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	return f, err == nil
+}
@@ -0,0 +1,102 @@
+package plan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+var start = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// feed observes one reading a minute and returns the events by minute.
+func feed(e *Evaluator, p types.Plan, values ...float64) map[int]Event {
+	events := map[int]Event{}
+	for i, v := range values {
+		if ev := e.Observe(p, v, start.Add(time.Duration(i)*time.Minute)); ev != nil {
+			events[i] = *ev
+		}
+	}
+	return events
+}
+
+func TestHoveringAlertsOnce(t *testing.T) {
+	p := types.Plan{ID: 1, Upper: "30", Hysteresis: 1}
+
+	events := feed(NewEvaluator(), p, 29, 30.2, 29.8, 30.4, 29.5, 30.1, 28.9, 29.5)
+	if len(events) != 2 {
+		t.Fatalf("expected one start and one clear, got %+v", events)
+	}
+	if ev := events[1]; ev.Kind != AlertStarted || ev.Bound != "upper" || !ev.Notify {
+		t.Errorf("expected the alert to start at the first crossing, got %+v", ev)
+	}
+	if ev := events[6]; ev.Kind != AlertCleared || !ev.Since.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected the alert to clear below 29, got %+v", ev)
+	}
+}
+
+func TestMinDuration(t *testing.T) {
+	p := types.Plan{ID: 1, Lower: "100", MinDurationSeconds: 180}
+	e := NewEvaluator()
+
+	// a short dip is ignored without a clear event
+	if events := feed(e, p, 90, 95, 120); len(events) != 0 {
+		t.Fatalf("expected a two minute dip to be ignored, got %+v", events)
+	}
+
+	events := feed(e, p, 90, 95, 80, 85, 90)
+	if len(events) != 1 || events[3].Kind != AlertStarted || events[3].Bound != "lower" {
+		t.Errorf("expected the alert after three minutes below the bound, got %+v", events)
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	p := types.Plan{ID: 1, Upper: "30", CooldownSeconds: 600}
+	e := NewEvaluator()
+
+	events := feed(e, p, 31, 29, 31, 29)
+	if !events[0].Notify || !events[1].Notify {
+		t.Errorf("expected the first episode to be notified, got %+v", events)
+	}
+	if events[2].Kind != AlertStarted || events[2].Notify || events[3].Notify {
+		t.Errorf("expected the second episode within the cooldown to be silent, got %+v", events)
+	}
+
+	if ev := e.Observe(p, 31, start.Add(20*time.Minute)); ev == nil || !ev.Notify {
+		t.Errorf("expected an alert after the cooldown to be notified, got %+v", ev)
+	}
+}
+
+func TestEditedPlanCarriesTheEpisodeOn(t *testing.T) {
+	p := types.Plan{ID: 1, SensorID: 7, Upper: "30"}
+	e := NewEvaluator()
+
+	if events := feed(e, p, 31); events[0].Kind != AlertStarted {
+		t.Fatalf("expected the alert to start, got %+v", events)
+	}
+
+	// editing the plan replaces it with a new id
+	edited := types.Plan{ID: 2, SensorID: 7, Upper: "31.5"}
+	if ev := e.Observe(edited, 32, start.Add(time.Minute)); ev != nil {
+		t.Errorf("expected the episode to go on against the new bound, got %+v", ev)
+	}
+	if ev := e.Observe(edited, 31, start.Add(2*time.Minute)); ev == nil || ev.Kind != AlertCleared || ev.Threshold != 31.5 {
+		t.Errorf("expected the episode to clear below the new bound, got %+v", ev)
+	}
+
+	feed(e, edited, 33)
+	if ev := e.Observe(types.Plan{ID: 3, SensorID: 7, Lower: "10"}, 33, start.Add(time.Minute)); ev == nil || ev.Kind != AlertCleared {
+		t.Errorf("expected the episode to end with its bound, got %+v", ev)
+	}
+}
+
+func TestForget(t *testing.T) {
+	p := types.Plan{ID: 1, SensorID: 7, Upper: "30"}
+	e := NewEvaluator()
+
+	feed(e, p, 31)
+	e.Forget(7)
+	if ev := e.Observe(p, 29, start.Add(time.Minute)); ev != nil {
+		t.Errorf("expected a forgotten episode not to clear, got %+v", ev)
+	}
+}
@@ -2,21 +2,28 @@ package plan
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
+	"github.com/quanghia24/mySmartHome/services/alert"
 	"github.com/quanghia24/mySmartHome/types"
 	"github.com/quanghia24/mySmartHome/utils"
 )
 
 type Handler struct {
-	store types.PlanStore
+	store      types.PlanStore
+	alertStore types.AlertStore
+	evaluator  *Evaluator
 }
 
-func NewHandler(store types.PlanStore) *Handler {
+func NewHandler(store types.PlanStore, alertStore types.AlertStore, evaluator *Evaluator) *Handler {
 	return &Handler{
-		store: store,
+		store:      store,
+		alertStore: alertStore,
+		evaluator:  evaluator,
 	}
 }
 
@@ -48,6 +55,12 @@ func (h *Handler) createPlan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
 	// remove existing plan -> only exist at a time
 	err := h.store.RemovePlan(feed_id)
 	if err != nil {
@@ -55,6 +68,9 @@ func (h *Handler) createPlan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	payload.SensorID = feed_id
+	if payload.Severity == "" {
+		payload.Severity = "warning"
+	}
 
 	fmt.Println(payload)
 	if err := h.store.CreatePlan(payload); err != nil {
@@ -75,5 +91,11 @@ func (h *Handler) removePlan(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
+
+	// without a plan the sensor can't cross a bound, nor get back within it
+	h.evaluator.Forget(feed_id)
+	if err := alert.Clear(h.alertStore, "sensor", feed_id, alert.KindThreshold); err != nil {
+		log.Println("resolve alert:", err)
+	}
 	utils.WriteJSON(w, http.StatusOK, fmt.Sprintf("plan of %d has been removed", feed_id))
 }
\ No newline at end of file
@@ -17,7 +17,7 @@ func NewStore(db *sql.DB) *Store {
 }
 
 func (s *Store) CreatePlan(plan types.Plan) error {
-	_, err := s.db.Exec("INSERT INTO plans (sensorId, lower, upper) VALUES (?,?,?)", plan.SensorID, plan.Lower, plan.Upper)
+	_, err := s.db.Exec("INSERT INTO plans (sensorId, lower, upper, hysteresis, minDurationSeconds, cooldownSeconds, severity) VALUES (?,?,?,?,?,?,?)", plan.SensorID, plan.Lower, plan.Upper, plan.Hysteresis, plan.MinDurationSeconds, plan.CooldownSeconds, plan.Severity)
 	return err
 }
 
@@ -28,7 +28,7 @@ func (s *Store) RemovePlan(sensorID int) error {
 
 func (s *Store) GetPlansByFeedID(sensorID int) (*types.Plan, error) {
 	row := s.db.QueryRow(`
-        SELECT id, sensorId, lower, upper, createdAt
+        SELECT id, sensorId, lower, upper, hysteresis, minDurationSeconds, cooldownSeconds, severity, createdAt
         FROM plans
         WHERE sensorId = ?
         ORDER BY createdAt DESC
@@ -36,7 +36,7 @@ func (s *Store) GetPlansByFeedID(sensorID int) (*types.Plan, error) {
     `, sensorID)
 
 	var p types.Plan
-    err := row.Scan(&p.ID, &p.SensorID, &p.Lower, &p.Upper, &p.CreatedAt)
+    err := row.Scan(&p.ID, &p.SensorID, &p.Lower, &p.Upper, &p.Hysteresis, &p.MinDurationSeconds, &p.CooldownSeconds, &p.Severity, &p.CreatedAt)
     if err != nil {
         return nil, err
     }
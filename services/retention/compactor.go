@@ -0,0 +1,89 @@
+package retention
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// how often the compactor enforces the retention
+const compactInterval = time.Hour
+
+// Compactor drops raw readings and rollup buckets once they are older than
+// the retention allows. The rollups are kept up to date as logs come in, so
+// dropping is all that's left to do.
+type Compactor struct {
+	config      Config
+	rollups     types.RollupStore
+	sensorStore types.SensorStore
+}
+
+func NewCompactor(config Config, rollups types.RollupStore, sensorStore types.SensorStore) *Compactor {
+	return &Compactor{
+		config:      config,
+		rollups:     rollups,
+		sensorStore: sensorStore,
+	}
+}
+
+// Start runs the compactor right away and then every compactInterval.
+func (c *Compactor) Start() {
+	ticker := time.NewTicker(compactInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Run(time.Now()); err != nil {
+			fmt.Println("Error compacting sensor data:", err)
+		}
+		<-ticker.C
+	}
+}
+
+// Run enforces the retention once.
+func (c *Compactor) Run(now time.Time) error {
+	sensors, err := c.sensorStore.GetAllSensor()
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, sensor := range sensors {
+		if seen[sensor.Type] {
+			continue
+		}
+		seen[sensor.Type] = true
+
+		policy := c.config.Sensor(sensor.Type)
+		if cutoff, ok := Cutoff(policy.RawDays, now); ok {
+			n, err := c.rollups.PruneSensorLogs(sensor.Type, cutoff)
+			if err != nil {
+				return fmt.Errorf("prune %s readings: %v", sensor.Type, err)
+			}
+			if n > 0 {
+				fmt.Printf("Pruned %d %s readings before %v\n", n, sensor.Type, cutoff)
+			}
+		}
+
+		for _, res := range []types.Resolution{types.FiveMinutes, types.Hourly, types.Daily} {
+			cutoff, ok := Cutoff(policy.Days(res), now)
+			if !ok {
+				continue
+			}
+			if _, err := c.rollups.PruneSensorStats(sensor.Type, res, cutoff); err != nil {
+				return fmt.Errorf("prune %s %s stats: %v", sensor.Type, res, err)
+			}
+		}
+	}
+
+	for _, res := range []types.Resolution{types.Hourly, types.Daily} {
+		cutoff, ok := Cutoff(c.config.Devices.Days(res), now)
+		if !ok {
+			continue
+		}
+		if _, err := c.rollups.PruneDeviceUsage(res, cutoff); err != nil {
+			return fmt.Errorf("prune %s device usage: %v", res, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package retention
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// Policy says how many days each resolution is kept, 0 keeps it forever.
+// Devices have no raw or 5 minute retention: their logs are the on/off
+// history the usage rollups are built from.
+type Policy struct {
+	RawDays        int `json:"rawDays"`
+	FiveMinuteDays int `json:"fiveMinuteDays"`
+	HourlyDays     int `json:"hourlyDays"`
+	DailyDays      int `json:"dailyDays"`
+}
+
+// Config holds the sensor policies by sensor type, with "default" used for
+// every type that has none of its own, and the device usage policy.
+type Config struct {
+	Sensors map[string]Policy `json:"sensors"`
+	Devices Policy            `json:"devices"`
+}
+
+const defaultType = "default"
+
+// Default keeps raw readings 30 days, 5 minute averages a year, hourly
+// stats two years and daily stats forever.
+func Default() Config {
+	return Config{
+		Sensors: map[string]Policy{
+			defaultType: {RawDays: 30, FiveMinuteDays: 365, HourlyDays: 730},
+		},
+		Devices: Policy{HourlyDays: 730},
+	}
+}
+
+// Load returns the retention configured through RETENTION_FILE, or Default.
+// A sensor type in the file only needs the fields it changes, the rest come
+// from the default policy.
+func Load() (Config, error) {
+	config := Default()
+
+	path := os.Getenv("RETENTION_FILE")
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read retention file: %v", err)
+	}
+	return parse(data)
+}
+
+func parse(data []byte) (Config, error) {
+	config := Default()
+
+	var file struct {
+		Sensors map[string]json.RawMessage `json:"sensors"`
+		Devices json.RawMessage            `json:"devices"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Config{}, fmt.Errorf("parse retention file: %v", err)
+	}
+
+	if raw, ok := file.Sensors[defaultType]; ok {
+		p := config.Sensors[defaultType]
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return Config{}, fmt.Errorf("parse retention for %s: %v", defaultType, err)
+		}
+		config.Sensors[defaultType] = p
+	}
+	for mtype, raw := range file.Sensors {
+		if mtype == defaultType {
+			continue
+		}
+		p := config.Sensors[defaultType]
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return Config{}, fmt.Errorf("parse retention for %s: %v", mtype, err)
+		}
+		config.Sensors[mtype] = p
+	}
+	if file.Devices != nil {
+		if err := json.Unmarshal(file.Devices, &config.Devices); err != nil {
+			return Config{}, fmt.Errorf("parse device retention: %v", err)
+		}
+	}
+
+	return config, config.Validate()
+}
+
+func (c Config) Validate() error {
+	for mtype, p := range c.Sensors {
+		if p.RawDays < 0 || p.FiveMinuteDays < 0 || p.HourlyDays < 0 || p.DailyDays < 0 {
+			return fmt.Errorf("retention for %s can't be negative", mtype)
+		}
+	}
+	if c.Devices.HourlyDays < 0 || c.Devices.DailyDays < 0 {
+		return fmt.Errorf("device retention can't be negative")
+	}
+	return nil
+}
+
+// Sensor returns the policy of a sensor type.
+func (c Config) Sensor(mtype string) Policy {
+	if p, ok := c.Sensors[mtype]; ok {
+		return p
+	}
+	return c.Sensors[defaultType]
+}
+
+// Days returns how many days a resolution is kept.
+func (p Policy) Days(res types.Resolution) int {
+	switch res {
+	case types.FiveMinutes:
+		return p.FiveMinuteDays
+	case types.Hourly:
+		return p.HourlyDays
+	case types.Daily:
+		return p.DailyDays
+	}
+	return 0
+}
+
+// Cutoff returns the instant before which data kept for the given days is
+// dropped, on an hour boundary so no rollup bucket is cut in half. ok is
+// false when it's kept forever.
+func Cutoff(days int, now time.Time) (time.Time, bool) {
+	if days == 0 {
+		return time.Time{}, false
+	}
+	return now.UTC().AddDate(0, 0, -days).Truncate(time.Hour), true
+}
+
+// bucket widths of the resolutions, finest first
+var widths = []struct {
+	res   types.Resolution
+	width time.Duration
+}{
+	{types.FiveMinutes, 5 * time.Minute},
+	{types.Hourly, time.Hour},
+	{types.Daily, 24 * time.Hour},
+}
+
+// Resolution picks the sensor rollup to read for a range starting at start,
+// when buckets of up to step are fine grained enough.
+func (p Policy) Resolution(start time.Time, step time.Duration, now time.Time) types.Resolution {
+	return p.pick([]types.Resolution{types.FiveMinutes, types.Hourly, types.Daily}, start, step, now)
+}
+
+// DeviceResolution is Resolution for device usage, which has no 5 minute
+// buckets.
+func (c Config) DeviceResolution(start time.Time, step time.Duration, now time.Time) types.Resolution {
+	return c.Devices.pick([]types.Resolution{types.Hourly, types.Daily}, start, step, now)
+}
+
+// pick prefers the coarsest resolution within step that is still kept back
+// to start, then the finest one kept back to start, and falls back to daily
+// when none reach that far.
+func (p Policy) pick(available []types.Resolution, start time.Time, step time.Duration, now time.Time) types.Resolution {
+	var finest, chosen types.Resolution
+
+	for _, w := range widths {
+		if !slices.Contains(available, w.res) {
+			continue
+		}
+		if cutoff, ok := Cutoff(p.Days(w.res), now); ok && start.Before(cutoff) {
+			continue
+		}
+		if finest == "" {
+			finest = w.res
+		}
+		if w.width <= step {
+			chosen = w.res
+		}
+	}
+
+	if chosen != "" {
+		return chosen
+	}
+	if finest != "" {
+		return finest
+	}
+	return types.Daily
+}
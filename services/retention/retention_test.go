@@ -0,0 +1,76 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+func TestParseMergesWithDefault(t *testing.T) {
+	config, err := parse([]byte(`{
+		"sensors": {
+			"default": {"rawDays": 14},
+			"brightness": {"fiveMinuteDays": 30}
+		},
+		"devices": {"dailyDays": 3650}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	brightness := config.Sensor("brightness")
+	if brightness.RawDays != 14 || brightness.FiveMinuteDays != 30 || brightness.HourlyDays != 730 {
+		t.Errorf("expected brightness to override only its 5 minute retention, got %+v", brightness)
+	}
+	if config.Sensor("humidity").RawDays != 14 {
+		t.Errorf("expected types without a policy to use the default, got %+v", config.Sensor("humidity"))
+	}
+	if config.Devices.HourlyDays != 730 || config.Devices.DailyDays != 3650 {
+		t.Errorf("expected device retention to merge with the default, got %+v", config.Devices)
+	}
+
+	if _, err := parse([]byte(`{"sensors": {"default": {"rawDays": -1}}}`)); err == nil {
+		t.Errorf("expected negative retention to be rejected")
+	}
+}
+
+func TestResolution(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	policy := Default().Sensor("temperature")
+
+	tests := []struct {
+		name     string
+		start    time.Time
+		step     time.Duration
+		expected types.Resolution
+	}{
+		{"recent fine range", now.AddDate(0, 0, -1), 0, types.FiveMinutes},
+		{"recent daily chart", now.AddDate(0, 0, -7), 24 * time.Hour, types.Daily},
+		{"recent hourly chart", now.AddDate(0, 0, -7), time.Hour, types.Hourly},
+		{"5 minute buckets pruned", now.AddDate(-1, -1, 0), 0, types.Hourly},
+		{"hourly buckets pruned", now.AddDate(-3, 0, 0), time.Hour, types.Daily},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Resolution(tt.start, tt.step, now); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, got)
+		}
+	}
+
+	if got := Default().DeviceResolution(now.AddDate(0, 0, -1), 0, now); got != types.Hourly {
+		t.Errorf("expected device usage to never use 5 minute buckets, got %s", got)
+	}
+}
+
+func TestCutoff(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 34, 0, 0, time.UTC)
+
+	if _, ok := Cutoff(0, now); ok {
+		t.Errorf("expected 0 days to keep data forever")
+	}
+	cutoff, ok := Cutoff(30, now)
+	if !ok || !cutoff.Equal(time.Date(2025, 5, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the cutoff on the hour 30 days back, got %v", cutoff)
+	}
+}
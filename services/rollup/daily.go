@@ -0,0 +1,67 @@
+package rollup
+
+import (
+	"math"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+	"github.com/quanghia24/mySmartHome/utils"
+)
+
+// DayStats are the readings of a sensor on one local day.
+type DayStats struct {
+	Sum      float64
+	Count    float64
+	Min      float64
+	Max      float64
+	Readings int
+}
+
+// Avg is the average reading of the day.
+func (d DayStats) Avg() float64 {
+	return d.Sum / d.Count
+}
+
+// DailyOnHours sums the on-time of the usage buckets per local day. A bucket
+// that straddles midnight, as hours do in half-hour offset zones, is split
+// proportionally.
+func DailyOnHours(usage []types.DeviceUsage, loc *time.Location) map[string]float64 {
+	dayHours := map[string]float64{}
+	for _, u := range usage {
+		for day, share := range dayShares(u.Start, u.End, loc) {
+			dayHours[day] += u.OnSeconds / 3600 * share
+		}
+	}
+	return dayHours
+}
+
+// DailySensorStats groups the stats buckets per local day, splitting the
+// ones that straddle midnight proportionally.
+func DailySensorStats(stats []types.SensorStats, loc *time.Location) map[string]*DayStats {
+	days := map[string]*DayStats{}
+	for _, st := range stats {
+		for day, share := range dayShares(st.Start, st.End, loc) {
+			ds, ok := days[day]
+			if !ok {
+				ds = &DayStats{Min: st.Min, Max: st.Max}
+				days[day] = ds
+			}
+			ds.Sum += st.Sum * share
+			ds.Count += float64(st.Count) * share
+			ds.Min = math.Min(ds.Min, st.Min)
+			ds.Max = math.Max(ds.Max, st.Max)
+			ds.Readings += int(math.Round(float64(st.Count) * share))
+		}
+	}
+	return days
+}
+
+// dayShares returns the part of [start, end) that falls on each local day.
+func dayShares(start, end time.Time, loc *time.Location) map[string]float64 {
+	total := end.Sub(start).Hours()
+	shares := utils.SplitDurationByDay(start, end, loc)
+	for day, hours := range shares {
+		shares[day] = hours / total
+	}
+	return shares
+}
@@ -0,0 +1,45 @@
+package rollup
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+func TestDailyOnHoursSplitsAtLocalMidnight(t *testing.T) {
+	// India is UTC+5:30, so the UTC hour 18:00 straddles local midnight
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip("tzdata not available")
+	}
+
+	start := time.Date(2026, 3, 1, 18, 0, 0, 0, time.UTC)
+	usage := []types.DeviceUsage{{Start: start, End: start.Add(time.Hour), OnSeconds: 3600}}
+
+	got := DailyOnHours(usage, loc)
+	if math.Abs(got["2026-03-01"]-0.5) > 1e-9 || math.Abs(got["2026-03-02"]-0.5) > 1e-9 {
+		t.Errorf("got %v, want half an hour on each day", got)
+	}
+}
+
+func TestDailySensorStats(t *testing.T) {
+	loc := time.UTC
+	start := time.Date(2026, 3, 1, 22, 0, 0, 0, loc)
+	stats := []types.SensorStats{
+		{Start: start, End: start.Add(time.Hour), Min: 20, Max: 24, Sum: 88, Count: 4},
+		{Start: start.Add(time.Hour), End: start.Add(2 * time.Hour), Min: 18, Max: 22, Sum: 40, Count: 2},
+		{Start: start.Add(2 * time.Hour), End: start.Add(3 * time.Hour), Min: 16, Max: 17, Sum: 33, Count: 2},
+	}
+
+	got := DailySensorStats(stats, loc)
+	day := got["2026-03-01"]
+	if day == nil || day.Avg() != 128.0/6 || day.Min != 18 || day.Max != 24 || day.Readings != 6 {
+		t.Errorf("2026-03-01: got %+v", day)
+	}
+	next := got["2026-03-02"]
+	if next == nil || next.Avg() != 16.5 || next.Readings != 2 {
+		t.Errorf("2026-03-02: got %+v", next)
+	}
+}
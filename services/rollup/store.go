@@ -0,0 +1,714 @@
+package rollup
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/types"
+	"github.com/quanghia24/mySmartHome/utils"
+)
+
+// rows deleted per statement while pruning, so the tables aren't locked for
+// long
+const pruneBatch = 5000
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// querier is what the rollups are written through, the database or the
+// transaction of a rebuild.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// inTx runs fn in a transaction, committed when it returns no error.
+func (s *Store) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// sensorTable describes where the sensor stats of a resolution are stored.
+// Daily buckets follow the owner's calendar days, so their bounds are stored
+// with them instead of being derived from a fixed width.
+type sensorTable struct {
+	name  string
+	start string
+	width time.Duration
+}
+
+var sensorTables = map[types.Resolution]sensorTable{
+	types.FiveMinutes: {"sensor_stats_5min", "bucket", 5 * time.Minute},
+	types.Hourly:      {"sensor_stats_hourly", "hour", time.Hour},
+	types.Daily:       {"sensor_stats_daily", "dayStart", 0},
+}
+
+// AddDeviceLog credits the on-time that ended with the given log entry: if
+// the entry before it left the device on, the gap between both is spread
+// over the hours and days it covers, along with the load it was left at.
+func (s *Store) AddDeviceLog(logId int) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		return s.addDeviceLog(tx, logId)
+	})
+}
+
+func (s *Store) addDeviceLog(tx *sql.Tx, logId int) error {
+	var deviceId int
+	var value, mtype, timezone string
+	var createdAt time.Time
+	// the device's row is shared with the other logs and held by a rebuild
+	err := tx.QueryRow(`
+		SELECT l.deviceId, l.value, d.type, u.timezone, l.createdAt
+		FROM logs l
+		JOIN devices d ON d.feedId = l.deviceId
+		JOIN users u ON u.id = d.userId
+		WHERE l.id = ?
+		FOR SHARE OF d
+	`, logId).Scan(&deviceId, &value, &mtype, &timezone, &createdAt)
+	if err != nil {
+		return err
+	}
+
+	var prevValue string
+	var prevAt time.Time
+	err = tx.QueryRow(`
+		SELECT value, createdAt
+		FROM logs
+		WHERE deviceId = ? AND (createdAt < ? OR (createdAt = ? AND id < ?))
+		ORDER BY createdAt DESC, id DESC
+		LIMIT 1
+	`, deviceId, createdAt, createdAt, logId).Scan(&prevValue, &prevAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if prevValue == devicetype.Off(mtype) {
+		return nil
+	}
+
+	return s.addOnTime(tx, deviceId, prevAt, createdAt, devicetype.LoadAt(mtype, prevValue), utils.LoadLocation(timezone))
+}
+
+// AddSensorLog folds a sensor data entry into its 5 minute, hourly and daily
+// min/max/avg.
+func (s *Store) AddSensorLog(logId int) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		return s.addSensorLog(tx, logId)
+	})
+}
+
+func (s *Store) addSensorLog(tx *sql.Tx, logId int) error {
+	var sensorId int
+	var mtype, value, timezone string
+	var createdAt time.Time
+	// the sensor's row is shared with the other logs and held by a rebuild
+	err := tx.QueryRow(`
+		SELECT l.sensorId, l.type, l.value, u.timezone, l.createdAt
+		FROM logs_sensor l
+		JOIN sensors s ON s.feedId = l.sensorId
+		JOIN users u ON u.id = s.userId
+		WHERE l.id = ?
+		FOR SHARE OF s
+	`, logId).Scan(&sensorId, &mtype, &value, &timezone, &createdAt)
+	if err != nil {
+		return err
+	}
+
+	if mtype != "data" {
+		return nil
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil // not a numeric reading
+	}
+
+	st := types.SensorStats{SensorID: sensorId, Min: v, Max: v, Sum: v, Count: 1}
+	for _, res := range []types.Resolution{types.FiveMinutes, types.Hourly} {
+		st.Start = createdAt.UTC().Truncate(sensorTables[res].width)
+		if err := s.upsertSensorStats(tx, res, st, nil, false); err != nil {
+			return err
+		}
+	}
+
+	loc := utils.LoadLocation(timezone)
+	st.Start = utils.StartOfDay(createdAt, loc)
+	st.End = utils.NextDayStart(createdAt, loc)
+	return s.upsertSensorStats(tx, types.Daily, st, loc, false)
+}
+
+// GetDeviceUsage returns the on-time and load of each device per bucket of the given
+// resolution between start and end, the buckets at the edges cut down to the
+// part inside. Devices that are still on get their
+// running period up to now added in hourly buckets, even though it isn't
+// stored yet. There is no 5 minute device table, hourly is used instead.
+func (s *Store) GetDeviceUsage(feedIds []int, res types.Resolution, start time.Time, end time.Time) ([]types.DeviceUsage, error) {
+	usage := []types.DeviceUsage{}
+	if len(feedIds) == 0 {
+		return usage, nil
+	}
+
+	in, args := inClause(feedIds)
+	var query string
+	if res == types.Daily {
+		args = append(args, start.UTC(), end.UTC())
+		query = `
+			SELECT deviceId, dayStart, dayEnd, onSeconds, loadSeconds
+			FROM device_usage_daily
+			WHERE deviceId IN (` + in + `) AND dayEnd > ? AND dayStart < ?
+			ORDER BY dayStart
+		`
+	} else {
+		args = append(args, start.UTC().Truncate(time.Hour), end.UTC())
+		query = `
+			SELECT deviceId, hour, DATE_ADD(hour, INTERVAL 1 HOUR), onSeconds, loadSeconds
+			FROM device_usage_hourly
+			WHERE deviceId IN (` + in + `) AND hour >= ? AND hour < ?
+			ORDER BY hour
+		`
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u types.DeviceUsage
+		if err := rows.Scan(&u.DeviceID, &u.Start, &u.End, &u.OnSeconds, &u.LoadSeconds); err != nil {
+			return nil, err
+		}
+		usage = append(usage, clipUsage(u, start, end))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// the period since the last log of a device that is still on
+	in, args = inClause(feedIds)
+	lrows, err := s.db.Query(`
+		SELECT d.feedId, d.type, l.value, l.createdAt
+		FROM devices d
+		JOIN logs l ON l.id = (
+			SELECT l2.id FROM logs l2
+			WHERE l2.deviceId = d.feedId
+			ORDER BY l2.createdAt DESC, l2.id DESC
+			LIMIT 1
+		)
+		WHERE d.feedId IN (`+in+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer lrows.Close()
+
+	now := time.Now().UTC()
+	for lrows.Next() {
+		var deviceId int
+		var mtype, value string
+		var since time.Time
+		if err := lrows.Scan(&deviceId, &mtype, &value, &since); err != nil {
+			return nil, err
+		}
+		if value == devicetype.Off(mtype) {
+			continue
+		}
+
+		from, to := since, now
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		load := devicetype.LoadAt(mtype, value)
+		for hour, seconds := range splitByHour(from, to) {
+			usage = append(usage, types.DeviceUsage{DeviceID: deviceId, Start: hour, End: hour.Add(time.Hour), OnSeconds: seconds, LoadSeconds: seconds * load})
+		}
+	}
+
+	return usage, lrows.Err()
+}
+
+// GetSensorStats returns the min/max/avg of a sensor per bucket of the given
+// resolution between start and end.
+func (s *Store) GetSensorStats(feedId int, res types.Resolution, start time.Time, end time.Time) ([]types.SensorStats, error) {
+	table, ok := sensorTables[res]
+	if !ok {
+		return nil, fmt.Errorf("unknown resolution %s", res)
+	}
+
+	var query string
+	var args []any
+	if res == types.Daily {
+		query = `
+			SELECT sensorId, dayStart, dayEnd, minValue, maxValue, sumValue, count
+			FROM sensor_stats_daily
+			WHERE sensorId = ? AND dayEnd > ? AND dayStart < ?
+			ORDER BY dayStart
+		`
+		args = []any{feedId, start.UTC(), end.UTC()}
+	} else {
+		query = fmt.Sprintf(`
+			SELECT sensorId, %[2]s, %[2]s, minValue, maxValue, sumValue, count
+			FROM %[1]s
+			WHERE sensorId = ? AND %[2]s >= ? AND %[2]s < ?
+			ORDER BY %[2]s
+		`, table.name, table.start)
+		args = []any{feedId, start.UTC().Truncate(table.width), end.UTC()}
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []types.SensorStats{}
+	for rows.Next() {
+		var st types.SensorStats
+		if err := rows.Scan(&st.SensorID, &st.Start, &st.End, &st.Min, &st.Max, &st.Sum, &st.Count); err != nil {
+			return nil, err
+		}
+		if res != types.Daily {
+			st.End = st.Start.Add(table.width)
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// PruneSensorLogs deletes the raw readings of every sensor of the given type
+// taken before the cutoff. Creation and warning entries are kept.
+func (s *Store) PruneSensorLogs(sensorType string, before time.Time) (int64, error) {
+	return s.deleteBatched(`
+		DELETE FROM logs_sensor
+		WHERE type = 'data' AND createdAt < ?
+		AND sensorId IN (SELECT feedId FROM sensors WHERE type = ?)
+		LIMIT ?
+	`, before.UTC(), sensorType)
+}
+
+// PruneSensorStats deletes the buckets of a resolution that start before the
+// cutoff, for every sensor of the given type.
+func (s *Store) PruneSensorStats(sensorType string, res types.Resolution, before time.Time) (int64, error) {
+	table, ok := sensorTables[res]
+	if !ok {
+		return 0, fmt.Errorf("unknown resolution %s", res)
+	}
+
+	return s.deleteBatched(fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE %s < ?
+		AND sensorId IN (SELECT feedId FROM sensors WHERE type = ?)
+		LIMIT ?
+	`, table.name, table.start), before.UTC(), sensorType)
+}
+
+// PruneDeviceUsage deletes the device usage buckets of a resolution that
+// start before the cutoff.
+func (s *Store) PruneDeviceUsage(res types.Resolution, before time.Time) (int64, error) {
+	switch res {
+	case types.Hourly:
+		return s.deleteBatched("DELETE FROM device_usage_hourly WHERE hour < ? LIMIT ?", before.UTC())
+	case types.Daily:
+		return s.deleteBatched("DELETE FROM device_usage_daily WHERE dayStart < ? LIMIT ?", before.UTC())
+	default:
+		return 0, fmt.Errorf("device usage isn't stored at %s", res)
+	}
+}
+
+func (s *Store) deleteBatched(query string, args ...any) (int64, error) {
+	args = append(args, pruneBatch)
+
+	var total int64
+	for {
+		res, err := s.db.Exec(query, args...)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < pruneBatch {
+			return total, nil
+		}
+	}
+}
+
+// Backfill rebuilds the rollups from the raw logs. Device usage is rebuilt
+// entirely. Sensor stats are only rebuilt from each sensor's oldest raw
+// reading onwards, older buckets are all that's left of pruned readings.
+// Each device and sensor is rebuilt in a transaction holding its row, so a
+// failure keeps its previous rollups and the live logs wait for it.
+func (s *Store) Backfill() error {
+	drows, err := s.db.Query(`
+		SELECT d.feedId, d.type, u.timezone
+		FROM devices d
+		JOIN users u ON u.id = d.userId
+	`)
+	if err != nil {
+		return err
+	}
+	type feed struct {
+		mtype string
+		loc   *time.Location
+	}
+	devices := map[int]feed{}
+	for drows.Next() {
+		var feedId int
+		var mtype, timezone string
+		if err := drows.Scan(&feedId, &mtype, &timezone); err != nil {
+			drows.Close()
+			return err
+		}
+		devices[feedId] = feed{mtype, utils.LoadLocation(timezone)}
+	}
+	drows.Close()
+
+	for feedId, d := range devices {
+		err := s.inTx(func(tx *sql.Tx) error {
+			return s.backfillDevice(tx, feedId, d.mtype, d.loc)
+		})
+		if err != nil {
+			return fmt.Errorf("backfill device %d: %v", feedId, err)
+		}
+	}
+
+	srows, err := s.db.Query(`
+		SELECT s.feedId, u.timezone
+		FROM sensors s
+		JOIN users u ON u.id = s.userId
+	`)
+	if err != nil {
+		return err
+	}
+	sensors := map[int]*time.Location{}
+	for srows.Next() {
+		var feedId int
+		var timezone string
+		if err := srows.Scan(&feedId, &timezone); err != nil {
+			srows.Close()
+			return err
+		}
+		sensors[feedId] = utils.LoadLocation(timezone)
+	}
+	srows.Close()
+
+	for feedId, loc := range sensors {
+		err := s.inTx(func(tx *sql.Tx) error {
+			return s.backfillSensor(tx, feedId, loc)
+		})
+		if err != nil {
+			return fmt.Errorf("backfill sensor %d: %v", feedId, err)
+		}
+	}
+	return nil
+}
+
+// backfillDevice credits every gap between two logs of the device that
+// the first one left it on, at the load it was left at.
+func (s *Store) backfillDevice(tx *sql.Tx, feedId int, mtype string, loc *time.Location) error {
+	err := tx.QueryRow("SELECT feedId FROM devices WHERE feedId = ? FOR UPDATE", feedId).Scan(&feedId)
+	if err == sql.ErrNoRows {
+		return nil // removed since
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT value, createdAt FROM logs WHERE deviceId = ? ORDER BY createdAt, id", feedId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var prevValue string
+	var prevAt *time.Time
+	hours := map[time.Time]usage{}
+	days := map[time.Time]usage{}
+
+	for rows.Next() {
+		var value string
+		var createdAt time.Time
+		if err := rows.Scan(&value, &createdAt); err != nil {
+			return err
+		}
+
+		if prevAt != nil && prevValue != devicetype.Off(mtype) {
+			load := devicetype.LoadAt(mtype, prevValue)
+			for hour, seconds := range splitByHour(*prevAt, createdAt) {
+				hours[hour] = hours[hour].add(seconds, load)
+			}
+			for day, seconds := range splitByDay(*prevAt, createdAt, loc) {
+				days[day] = days[day].add(seconds, load)
+			}
+		}
+		prevValue, prevAt = value, &createdAt
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM device_usage_hourly WHERE deviceId = ?", feedId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM device_usage_daily WHERE deviceId = ?", feedId); err != nil {
+		return err
+	}
+
+	for hour, u := range hours {
+		if err := s.upsertHourlyOnTime(tx, feedId, hour, u); err != nil {
+			return err
+		}
+	}
+	for day, u := range days {
+		if err := s.upsertDailyOnTime(tx, feedId, day, loc, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) backfillSensor(tx *sql.Tx, feedId int, loc *time.Location) error {
+	err := tx.QueryRow("SELECT feedId FROM sensors WHERE feedId = ? FOR UPDATE", feedId).Scan(&feedId)
+	if err == sql.ErrNoRows {
+		return nil // removed since
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT value, createdAt FROM logs_sensor WHERE sensorId = ? AND type = 'data' ORDER BY createdAt, id", feedId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var first time.Time
+	buckets := map[types.Resolution]map[time.Time]*types.SensorStats{
+		types.FiveMinutes: {},
+		types.Hourly:      {},
+		types.Daily:       {},
+	}
+
+	for rows.Next() {
+		var value string
+		var createdAt time.Time
+		if err := rows.Scan(&value, &createdAt); err != nil {
+			return err
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue // not a numeric reading
+		}
+		if first.IsZero() {
+			first = createdAt
+		}
+
+		for res, table := range sensorTables {
+			start := createdAt.UTC().Truncate(table.width)
+			end := start.Add(table.width)
+			if res == types.Daily {
+				start, end = utils.StartOfDay(createdAt, loc), utils.NextDayStart(createdAt, loc)
+			}
+
+			st, ok := buckets[res][start]
+			if !ok {
+				st = &types.SensorStats{SensorID: feedId, Start: start, End: end, Min: v, Max: v}
+				buckets[res][start] = st
+			}
+			st.Min = min(st.Min, v)
+			st.Max = max(st.Max, v)
+			st.Sum += v
+			st.Count++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if first.IsZero() {
+		return nil
+	}
+
+	for res, table := range sensorTables {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE sensorId = ? AND %s >= ?", table.name, table.start), feedId, first.UTC()); err != nil {
+			return err
+		}
+
+		for _, st := range buckets[res] {
+			// a bucket that started before the oldest raw reading may have
+			// lost some of them to pruning, keep what's stored for it
+			if err := s.upsertSensorStats(tx, res, *st, loc, st.Start.Before(first)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// usage is the on-time credited to a bucket and its load-weighted share.
+type usage struct {
+	on   float64
+	load float64
+}
+
+func (u usage) add(seconds float64, load float64) usage {
+	return usage{u.on + seconds, u.load + seconds*load}
+}
+
+func (s *Store) addOnTime(q querier, deviceId int, from, to time.Time, load float64, loc *time.Location) error {
+	for hour, seconds := range splitByHour(from, to) {
+		if err := s.upsertHourlyOnTime(q, deviceId, hour, usage{}.add(seconds, load)); err != nil {
+			return err
+		}
+	}
+	for day, seconds := range splitByDay(from, to, loc) {
+		if err := s.upsertDailyOnTime(q, deviceId, day, loc, usage{}.add(seconds, load)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) upsertHourlyOnTime(q querier, deviceId int, hour time.Time, u usage) error {
+	_, err := q.Exec(`
+		INSERT INTO device_usage_hourly (deviceId, hour, onSeconds, loadSeconds)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			onSeconds = onSeconds + VALUES(onSeconds),
+			loadSeconds = loadSeconds + VALUES(loadSeconds)
+	`, deviceId, hour, u.on, u.load)
+	return err
+}
+
+func (s *Store) upsertDailyOnTime(q querier, deviceId int, day time.Time, loc *time.Location, u usage) error {
+	_, err := q.Exec(`
+		INSERT INTO device_usage_daily (deviceId, day, dayStart, dayEnd, onSeconds, loadSeconds)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			onSeconds = onSeconds + VALUES(onSeconds),
+			loadSeconds = loadSeconds + VALUES(loadSeconds)
+	`, deviceId, utils.DayKey(day, loc), day.UTC(), utils.NextDayStart(day, loc).UTC(), u.on, u.load)
+	return err
+}
+
+// upsertSensorStats merges st into its bucket, or with keep set only inserts
+// it when the bucket doesn't exist yet. loc names the day of daily buckets.
+func (s *Store) upsertSensorStats(q querier, res types.Resolution, st types.SensorStats, loc *time.Location, keep bool) error {
+	table := sensorTables[res]
+
+	columns := "sensorId, " + table.start
+	placeholders := "?, ?"
+	args := []any{st.SensorID, st.Start.UTC()}
+	if res == types.Daily {
+		columns = "sensorId, day, dayStart, dayEnd"
+		placeholders = "?, ?, ?, ?"
+		args = []any{st.SensorID, utils.DayKey(st.Start, loc), st.Start.UTC(), st.End.UTC()}
+	}
+	args = append(args, st.Min, st.Max, st.Sum, st.Count)
+
+	query := fmt.Sprintf("INSERT INTO %s (%s, minValue, maxValue, sumValue, count) VALUES (%s, ?, ?, ?, ?)", table.name, columns, placeholders)
+	if keep {
+		query = strings.Replace(query, "INSERT", "INSERT IGNORE", 1)
+	} else {
+		query += `
+			ON DUPLICATE KEY UPDATE
+				minValue = LEAST(minValue, VALUES(minValue)),
+				maxValue = GREATEST(maxValue, VALUES(maxValue)),
+				sumValue = sumValue + VALUES(sumValue),
+				count = count + VALUES(count)`
+	}
+
+	_, err := q.Exec(query, args...)
+	return err
+}
+
+// splitByHour spreads [from, to) over the UTC hours it covers.
+// clipUsage cuts a bucket down to its part in [start, end), taking the
+// on-time as spread evenly over the bucket.
+func clipUsage(u types.DeviceUsage, start, end time.Time) types.DeviceUsage {
+	width := u.End.Sub(u.Start)
+	if u.Start.Before(start) {
+		u.Start = start
+	}
+	if u.End.After(end) {
+		u.End = end
+	}
+	if width <= 0 || !u.End.After(u.Start) {
+		u.OnSeconds, u.LoadSeconds = 0, 0
+		return u
+	}
+
+	share := float64(u.End.Sub(u.Start)) / float64(width)
+	u.OnSeconds *= share
+	u.LoadSeconds *= share
+	return u
+}
+
+func splitByHour(from, to time.Time) map[time.Time]float64 {
+	result := make(map[time.Time]float64)
+
+	curr := from.UTC()
+	end := to.UTC()
+	for curr.Before(end) {
+		next := curr.Truncate(time.Hour).Add(time.Hour)
+		if next.After(end) {
+			next = end
+		}
+		result[curr.Truncate(time.Hour)] += next.Sub(curr).Seconds()
+		curr = next
+	}
+	return result
+}
+
+// splitByDay spreads [from, to) over the local days it covers, keyed by the
+// start of each day.
+func splitByDay(from, to time.Time, loc *time.Location) map[time.Time]float64 {
+	result := make(map[time.Time]float64)
+
+	for curr := from; curr.Before(to); {
+		next := utils.NextDayStart(curr, loc)
+		if next.After(to) {
+			next = to
+		}
+		result[utils.StartOfDay(curr, loc)] += next.Sub(curr).Seconds()
+		curr = next
+	}
+	return result
+}
+
+func inClause(ids []int) (string, []any) {
+	placeholders := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return strings.Join(placeholders, ","), args
+}
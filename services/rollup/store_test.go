@@ -0,0 +1,22 @@
+package rollup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+func TestClipUsageProRatesTheEdges(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	u := types.DeviceUsage{Start: day, End: day.Add(24 * time.Hour), OnSeconds: 12 * 3600, LoadSeconds: 6 * 3600}
+
+	got := clipUsage(u, day.Add(10*time.Hour), day.Add(14*time.Hour))
+	if got.OnSeconds != 2*3600 || got.LoadSeconds != 3600 || !got.Start.Equal(day.Add(10*time.Hour)) {
+		t.Errorf("expected 4 of the 24 hours, got %+v", got)
+	}
+
+	if got := clipUsage(u, day, day.Add(48*time.Hour)); got.OnSeconds != u.OnSeconds {
+		t.Errorf("expected a bucket inside the range kept whole, got %+v", got)
+	}
+}
@@ -2,7 +2,10 @@ package room
 
 import (
 	"database/sql"
+	"fmt"
 
+	"github.com/quanghia24/mySmartHome/services/comfort"
+	"github.com/quanghia24/mySmartHome/services/devicetype"
 	"github.com/quanghia24/mySmartHome/types"
 )
 
@@ -21,6 +24,23 @@ func (s *Store) CreateRoom(room types.Room) error {
 	return err
 }
 
+func (s *Store) GetRoomByID(roomId int) (*types.Room, error) {
+	room := new(types.Room)
+	err := s.db.QueryRow("SELECT id, title, userId, image FROM rooms WHERE id = ?", roomId).Scan(
+		&room.ID,
+		&room.Title,
+		&room.UserID,
+		&room.Image,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("room %d not found", roomId)
+		}
+		return nil, err
+	}
+	return room, nil
+}
+
 func (s *Store) DeleteRoom(roomId int, userId int) error {
 	query := `DELETE FROM rooms WHERE rooms.id = ? AND rooms.userId = ?`
 	_, err := s.db.Exec(query, roomId, userId)
@@ -33,27 +53,25 @@ func (s *Store) GetRoomsByUserID(userId int) ([]types.RoomInfoPayload, error) {
 			r.id,
 			r.title,
 
-			COUNT(CASE WHEN d.type = 'fan' THEN 1 END) AS fanC,
-			MAX(CASE WHEN d.type = 'fan' AND l.value > 0 THEN 1 ELSE 0 END) AS fanS,
-
-			COUNT(CASE WHEN d.type = 'light' THEN 1 END) AS lightC,
-			MAX(CASE WHEN d.type = 'light' AND l.value > 0 THEN 1 ELSE 0 END) AS lightS,
-
-			COUNT(CASE WHEN d.type = 'door' THEN 1 END) AS doorC,
-			MAX(CASE WHEN d.type = 'door' AND l.value > 0 THEN 1 ELSE 0 END) AS doorS,
-      
-      		(SELECT (COUNT(*)) FROM sensors s WHERE s.roomId = r.id) as sensorC 
+      		(SELECT (COUNT(*)) FROM sensors s WHERE s.roomId = r.id AND NOT s.isVirtual) as sensorC,
+
+			(SELECT l.value FROM sensors s JOIN logs_sensor l ON l.sensorId = s.feedId
+				WHERE s.roomId = r.id AND s.type = ? AND l.type = 'data'
+				ORDER BY l.createdAt DESC LIMIT 1) AS heatIndex,
+			(SELECT l.value FROM sensors s JOIN logs_sensor l ON l.sensorId = s.feedId
+				WHERE s.roomId = r.id AND s.type = ? AND l.type = 'data'
+				ORDER BY l.createdAt DESC LIMIT 1) AS dewPoint,
+			(SELECT l.value FROM sensors s JOIN logs_sensor l ON l.sensorId = s.feedId
+				WHERE s.roomId = r.id AND s.type = ? AND l.type = 'data'
+				ORDER BY l.createdAt DESC LIMIT 1) AS absoluteHumidity,
+			(SELECT l.value FROM sensors s JOIN logs_sensor l ON l.sensorId = s.feedId
+				WHERE s.roomId = r.id AND s.type = ? AND l.type = 'data'
+				ORDER BY l.createdAt DESC LIMIT 1) AS comfort
       
     	FROM rooms r 
-		LEFT JOIN devices d ON r.id = d.roomId
-		LEFT JOIN logs l ON d.feedId = l.deviceId
-		AND l.createdAt = (
-			SELECT MAX(l2.createdAt) FROM logs l2 WHERE l2.deviceId = d.feedId
-		)
-		WHERE r.userId = ?
-		GROUP BY r.id;
+		WHERE r.userId = ?;
 	`
-	rows, err := s.db.Query(query, userId)
+	rows, err := s.db.Query(query, comfort.HeatIndexType, comfort.DewPointType, comfort.AbsoluteHumidityType, comfort.ScoreType, userId)
 	if err != nil {
 		return nil, err
 	}
@@ -68,9 +86,67 @@ func (s *Store) GetRoomsByUserID(userId int) ([]types.RoomInfoPayload, error) {
 		rooms = append(rooms, *r)
 	}
 
+	if err := s.countDevices(userId, rooms); err != nil {
+		return nil, err
+	}
 	return rooms, nil
 }
 
+// countDevices counts the devices of each type in the rooms, and whether any
+// of them is on by their latest value.
+func (s *Store) countDevices(userId int, rooms []types.RoomInfoPayload) error {
+	query := `
+		SELECT d.roomId, d.type, l.value
+		FROM devices d
+		LEFT JOIN logs l ON d.feedId = l.deviceId
+		AND l.createdAt = (
+			SELECT MAX(l2.createdAt) FROM logs l2 WHERE l2.deviceId = d.feedId
+		)
+		WHERE d.userId = ?
+	`
+	rows, err := s.db.Query(query, userId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	counts := map[int]map[string]types.DeviceTypeCount{}
+	for rows.Next() {
+		var roomId int
+		var mtype string
+		var value sql.NullString
+		if err := rows.Scan(&roomId, &mtype, &value); err != nil {
+			return err
+		}
+
+		if counts[roomId] == nil {
+			counts[roomId] = map[string]types.DeviceTypeCount{}
+		}
+		c := counts[roomId][mtype]
+		c.Count++
+		if t, ok := devicetype.Lookup(mtype); ok && value.Valid && !t.IsOff(value.String) {
+			c.Status = 1
+		}
+		counts[roomId][mtype] = c
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range rooms {
+		room := &rooms[i]
+		room.Devices = map[string]types.DeviceTypeCount{}
+		for _, t := range devicetype.Devices() {
+			room.Devices[t.Name] = counts[room.ID][t.Name]
+		}
+
+		room.FanCount, room.FanStatus = room.Devices["fan"].Count, room.Devices["fan"].Status
+		room.LightCount, room.LightStatus = room.Devices["light"].Count, room.Devices["light"].Status
+		room.DoorCount, room.DoorStatus = room.Devices["door"].Count, room.Devices["door"].Status
+	}
+	return nil
+}
+
 func (s *Store) GetDevicesByRoomId(roomId int) ([]int, error) {
 	query := `
 		SELECT feedId 
@@ -102,21 +178,29 @@ func (s *Store) GetDevicesByRoomId(roomId int) ([]int, error) {
 
 func scanRowsIntoRoom(rows *sql.Rows) (*types.RoomInfoPayload, error) {
 	room := new(types.RoomInfoPayload)
+	var heatIndex, dewPoint, absoluteHumidity, score sql.NullFloat64
 
 	err := rows.Scan(
 		&room.ID,
 		&room.Title,
-		&room.FanCount,
-		&room.FanStatus,
-		&room.LightCount,
-		&room.LightStatus,
-		&room.DoorCount,
-		&room.DoorStatus,
 		&room.SensorCount,
+		&heatIndex,
+		&dewPoint,
+		&absoluteHumidity,
+		&score,
 	)
 	if err != nil {
 		return nil, err
 	}
+
+	if heatIndex.Valid && dewPoint.Valid && absoluteHumidity.Valid && score.Valid {
+		room.Comfort = &types.RoomComfort{
+			HeatIndex:        heatIndex.Float64,
+			DewPoint:         dewPoint.Float64,
+			AbsoluteHumidity: absoluteHumidity.Float64,
+			Score:            score.Float64,
+		}
+	}
 	return room, nil
 }
 
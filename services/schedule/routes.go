@@ -1,17 +1,18 @@
 package schedule
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/services/notification"
+	"github.com/quanghia24/mySmartHome/services/shadow"
+	"github.com/quanghia24/mySmartHome/services/webhook"
 	"github.com/quanghia24/mySmartHome/types"
 	"github.com/quanghia24/mySmartHome/utils"
 	"github.com/robfig/cron/v3"
@@ -23,15 +24,21 @@ type Handler struct {
 	logStore    types.LogDeviceStore
 	doorStore   types.DoorStore
 	userStore   types.UserStore
+	shadows     *shadow.Tracker
+	webhooks    *webhook.Sender
+	dispatcher  *notification.Dispatcher
 }
 
-func NewHandler(store types.ScheduleStore, deviceStore types.DeviceStore, logStore types.LogDeviceStore, doorStore types.DoorStore, userStore types.UserStore) *Handler {
+func NewHandler(store types.ScheduleStore, deviceStore types.DeviceStore, logStore types.LogDeviceStore, doorStore types.DoorStore, userStore types.UserStore, shadows *shadow.Tracker, webhooks *webhook.Sender, dispatcher *notification.Dispatcher) *Handler {
 	return &Handler{
 		store:       store,
 		deviceStore: deviceStore,
 		logStore:    logStore,
 		doorStore:   doorStore,
 		userStore:   userStore,
+		shadows:     shadows,
+		webhooks:    webhooks,
+		dispatcher:  dispatcher,
 	}
 }
 
@@ -158,7 +165,23 @@ func (h *Handler) checkAndRunSchedules() {
 		day := now.Weekday().String()[:3] // "Monday" → "Mon"
 
 		if nowStr == schedStr && h.containsDay(s.RepeatDays, day) {
-			h.CreateDeviceData(s.DeviceID, s.Action, s.UserID)
+			err := h.CreateDeviceData(s.DeviceID, s.Action, s.UserID)
+			run := map[string]any{
+				"scheduleId": s.ID,
+				"feedId":     s.DeviceID,
+				"action":     s.Action,
+				"ok":         err == nil,
+			}
+			if err != nil {
+				run["error"] = err.Error()
+				h.dispatcher.Notify(s.UserID, notification.Message{
+					TitleKey: "noti.schedule.title",
+					BodyKey:  "noti.schedule.body",
+					Params:   i18n.Params{"action": s.Action, "time": schedStr, "error": err.Error()},
+					Event:    notification.EventSchedule,
+				})
+			}
+			h.webhooks.Emit(s.UserID, webhook.EventScheduleRun, run)
 		} 
 		// else {
 		// 	fmt.Println("Same day:", h.containsDay(s.RepeatDays, day))
@@ -203,51 +226,16 @@ func (h *Handler) CreateDeviceData(feedId int, value string, userId int) error {
 		return err
 	}
 
-	url := os.Getenv("AIOAPI") + device.FeedKey + "/data"
-	log.Println("adding data to", url)
-
-	// convert value for fan levels
-	if device.Type == "fan" {
-		switch value {
-		case "1":
-			value = "50"
-		case "2":
-			value = "75"
-		case "3":
-			value = "100"
-		default:
-			value = "0"
-		}
-	}
-
-	payload := types.DeviceDataPayload{
-		Value:     value,
-		CreatedAt: time.Now().In(time.FixedZone("UTC+7", 7*3600)),
-	}
-
-	// send request to Adafruit
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return err
+	// convert the command to the feed's value, e.g. fan levels
+	t, ok := devicetype.ForDevice(device.Type, device.Levels)
+	if !ok {
+		return fmt.Errorf("unknown device type %s", device.Type)
 	}
-
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
+	value, err = t.ToFeed(value)
 	if err != nil {
 		return err
 	}
 
-	apiKey := os.Getenv("AIOKey")
-	if apiKey == "" {
-		return fmt.Errorf("missing AIO Key")
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-AIO-Key", apiKey)
-
-	client := &http.Client{}
-	if _, err := client.Do(req); err != nil {
-		return err
-	}
-	
-	return nil
+	_, err = h.shadows.Send(feedId, device.FeedKey, value)
+	return err
 }
- 
\ No newline at end of file
@@ -0,0 +1,112 @@
+package sensor
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/alert"
+	"github.com/quanghia24/mySmartHome/services/comfort"
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// provisionComfortSensors adds the virtual sensors of a room once it has both
+// a temperature and a humidity sensor.
+func (h *Handler) provisionComfortSensors(roomId int) error {
+	sensors, err := h.store.GetSensorsByRoomId(roomId)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range comfort.Missing(sensors) {
+		if err := h.store.CreateSensor(s); err != nil {
+			return err
+		}
+
+		err = h.logSensorStore.CreateLogSensor(types.LogSensor{
+			Type:       "creation",
+			MessageKey: "log.sensor.added",
+			Params:     i18n.Params{"title": s.Title},
+			SensorID:   s.FeedId,
+			UserID:     s.UserID,
+			Value:      "0",
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retireComfortSensors removes the virtual sensors of a room that lost its
+// pair, their plans and history with them, and resolves their alerts.
+func (h *Handler) retireComfortSensors(roomId int) error {
+	sensors, err := h.store.GetSensorsByRoomId(roomId)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range comfort.Orphaned(sensors) {
+		if err := h.store.RemoveSensor(s.FeedId); err != nil {
+			return err
+		}
+		h.evaluator.Forget(s.FeedId)
+		for _, kind := range []string{alert.KindThreshold, alert.KindAnomaly} {
+			if err := alert.Clear(h.alertStore, "sensor", s.FeedId, kind); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updateComfortSensors records the derived metrics of every room with paired
+// sensors from the readings polled last.
+func (h *Handler) updateComfortSensors(sensors []types.Sensor) {
+	rooms := map[int][]types.Sensor{}
+	for _, s := range sensors {
+		rooms[s.RoomID] = append(rooms[s.RoomID], s)
+	}
+
+	for roomId, roomSensors := range rooms {
+		if len(comfort.Missing(roomSensors)) > 0 {
+			if err := h.provisionComfortSensors(roomId); err != nil {
+				log.Println("comfort sensors:", err)
+				continue
+			}
+			if roomSensors, _ = h.store.GetSensorsByRoomId(roomId); roomSensors == nil {
+				continue
+			}
+		}
+
+		m, err := comfort.Derive(roomSensors, h.logSensorStore, nil, time.Now())
+		if err != nil {
+			log.Println("comfort metrics:", err)
+			continue
+		}
+		if m == nil {
+			continue
+		}
+
+		values := m.Values()
+		for _, s := range roomSensors {
+			if !s.Virtual {
+				continue
+			}
+
+			value := fmt.Sprintf("%.1f", values[s.Type])
+			err = h.logSensorStore.CreateLogSensor(types.LogSensor{
+				Type:       "data",
+				MessageKey: "log.sensor.data",
+				Params:     i18n.Params{"value": value},
+				SensorID:   s.FeedId,
+				UserID:     s.UserID,
+				Value:      value,
+			})
+			if err != nil {
+				log.Println("sensor log create:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package sensor
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/quanghia24/mySmartHome/services/alert"
+	"github.com/quanghia24/mySmartHome/services/anomaly"
+	"github.com/quanghia24/mySmartHome/services/comfort"
+	"github.com/quanghia24/mySmartHome/services/events"
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/services/plan"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// Monitor checks the readings of the sensors against their plans and their
+// usual values, keeping the warnings in the sensor's log and its alerts, and
+// derives the comfort metrics of their rooms.
+type Monitor struct {
+	bus        *events.Bus
+	store      types.SensorStore
+	logStore   types.LogSensorStore
+	planStore  types.PlanStore
+	alertStore types.AlertStore
+	detector   *anomaly.Detector
+	evaluator  *plan.Evaluator
+}
+
+func NewMonitor(bus *events.Bus, store types.SensorStore, logStore types.LogSensorStore, planStore types.PlanStore, alertStore types.AlertStore, detector *anomaly.Detector, evaluator *plan.Evaluator) *Monitor {
+	return &Monitor{
+		bus:        bus,
+		store:      store,
+		logStore:   logStore,
+		planStore:  planStore,
+		alertStore: alertStore,
+		detector:   detector,
+		evaluator:  evaluator,
+	}
+}
+
+// Subscribe checks every reading published on the bus.
+func (m *Monitor) Subscribe() {
+	m.bus.SensorReading.Subscribe(m.checkPlan)
+	m.bus.SensorReading.Subscribe(m.checkAnomaly)
+	m.bus.SensorReading.Subscribe(m.updateComfort)
+}
+
+// checkPlan records the start or the end of a threshold alert and publishes
+// it for the devices and the notifications.
+func (m *Monitor) checkPlan(e events.SensorReading) {
+	if !e.Numeric {
+		return
+	}
+
+	p, err := m.planStore.GetPlansByFeedID(e.Sensor.FeedId)
+	if err != nil {
+		fmt.Println("Failed to get plans:", err)
+	}
+	if p == nil {
+		return
+	}
+	ev := m.evaluator.Observe(*p, e.Value, e.At)
+	if ev == nil {
+		return
+	}
+
+	params := i18n.Params{
+		"value":     fmt.Sprint(ev.Value),
+		"threshold": fmt.Sprint(ev.Threshold),
+		"bound":     ev.Bound,
+	}
+	l := types.LogSensor{
+		Type:       "warning",
+		MessageKey: "log.sensor." + ev.Bound,
+		Params:     params,
+		SensorID:   e.Sensor.FeedId,
+		UserID:     e.Sensor.UserID,
+		Value:      e.Raw,
+	}
+	if ev.Kind == plan.AlertCleared {
+		l.Type, l.MessageKey = "cleared", "log.sensor.cleared"
+	}
+
+	fmt.Println("WARNING!!!", ev.Kind, ev.Bound)
+	if err := m.logStore.CreateLogSensor(l); err != nil {
+		log.Println("sensor log create:", err)
+	}
+
+	if ev.Kind == plan.AlertCleared {
+		if err := alert.Clear(m.alertStore, "sensor", e.Sensor.FeedId, alert.KindThreshold); err != nil {
+			log.Println("resolve alert:", err)
+		}
+	} else {
+		_, err := alert.Raise(m.alertStore, types.Alert{
+			UserID:     e.Sensor.UserID,
+			SourceType: "sensor",
+			SourceID:   e.Sensor.FeedId,
+			Kind:       alert.KindThreshold,
+			Severity:   ev.Severity,
+			Message:    i18n.Render(i18n.DefaultLocale, l.MessageKey, params),
+			Value:      e.Raw,
+		})
+		if err != nil {
+			log.Println("raise alert:", err)
+		}
+	}
+
+	m.bus.ThresholdCrossed.Publish(events.ThresholdCrossed{Sensor: e.Sensor, Event: *ev, Raw: e.Raw})
+}
+
+// checkAnomaly compares a reading against the sensor's usual values and
+// warns about it the same way a threshold breach does.
+func (m *Monitor) checkAnomaly(e events.SensorReading) {
+	if !e.Numeric {
+		return
+	}
+
+	// looked up with the reading, so its sensitivity is the current one
+	sensor := e.Sensor
+
+	a, recovered, err := m.detector.Check(sensor, e.Value, e.At)
+	if err != nil {
+		log.Println("anomaly check:", err)
+		return
+	}
+	if recovered {
+		if err := alert.Clear(m.alertStore, "sensor", sensor.FeedId, alert.KindAnomaly); err != nil {
+			log.Println("resolve alert:", err)
+		}
+	}
+	if a == nil {
+		return
+	}
+	if a.Repeat {
+		return
+	}
+
+	params := i18n.Params{
+		"value":    fmt.Sprint(e.Value),
+		"expected": fmt.Sprintf("%.1f", a.Expected),
+		"stddev":   fmt.Sprintf("%.1f", a.StdDev),
+	}
+	log.Println("unusual reading of sensor", sensor.FeedId)
+	err = m.logStore.CreateLogSensor(types.LogSensor{
+		Type:       "warning",
+		MessageKey: "log.sensor.anomalous",
+		Params:     params,
+		SensorID:   sensor.FeedId,
+		UserID:     sensor.UserID,
+		Value:      e.Raw,
+	})
+	if err != nil {
+		log.Println("sensor log create:", err)
+	}
+
+	_, err = alert.Raise(m.alertStore, types.Alert{
+		UserID:     sensor.UserID,
+		SourceType: "sensor",
+		SourceID:   sensor.FeedId,
+		Kind:       alert.KindAnomaly,
+		Severity:   alert.SeverityInfo,
+		Message:    i18n.Render(i18n.DefaultLocale, "log.sensor.anomalous", params),
+		Value:      e.Raw,
+	})
+	if err != nil {
+		log.Println("raise alert:", err)
+	}
+
+	m.bus.AnomalyDetected.Publish(events.AnomalyDetected{
+		Sensor:   sensor,
+		Value:    e.Value,
+		Raw:      e.Raw,
+		Expected: a.Expected,
+		StdDev:   a.StdDev,
+	})
+}
+
+// updateComfort derives the room's comfort metrics when one of its paired
+// sensors reports, and publishes them like any other reading.
+func (m *Monitor) updateComfort(e events.SensorReading) {
+	if e.Sensor.Type != "temperature" && e.Sensor.Type != "humidity" || !e.Numeric {
+		return
+	}
+
+	sensors, err := m.store.GetSensorsByRoomId(e.Sensor.RoomID)
+	if err != nil {
+		log.Println("error get sensors in room:", err)
+		return
+	}
+
+	reading := &types.LogSensor{SensorID: e.Sensor.FeedId, Value: e.Raw, CreatedAt: e.At}
+	metrics, err := comfort.Derive(sensors, m.logStore, reading, e.At)
+	if err != nil {
+		log.Println("comfort metrics:", err)
+		return
+	}
+	if metrics == nil {
+		return
+	}
+
+	values := metrics.Values()
+	for _, s := range sensors {
+		if s.Virtual {
+			m.bus.SensorReading.Publish(events.NewSensorReading(s, fmt.Sprintf("%.1f", values[s.Type]), e.At))
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package sensor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/events"
+	"github.com/quanghia24/mySmartHome/services/plan"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+type plans struct {
+	types.PlanStore
+	plan *types.Plan
+}
+
+func (s *plans) GetPlansByFeedID(feedId int) (*types.Plan, error) {
+	return s.plan, nil
+}
+
+type sensorLogs struct {
+	types.LogSensorStore
+	logs []types.LogSensor
+}
+
+func (s *sensorLogs) CreateLogSensor(l types.LogSensor) error {
+	s.logs = append(s.logs, l)
+	return nil
+}
+
+type alerts struct {
+	types.AlertStore
+	alerts   []types.Alert
+	resolved int
+}
+
+func (s *alerts) GetActiveAlert(sourceType string, sourceId int, kind string) (*types.Alert, error) {
+	return nil, nil
+}
+
+func (s *alerts) CreateAlert(a types.Alert) error {
+	s.alerts = append(s.alerts, a)
+	return nil
+}
+
+func (s *alerts) ResolveActiveAlerts(sourceType string, sourceId int, kind string) (int64, error) {
+	s.resolved++
+	return 1, nil
+}
+
+type sensors struct {
+	types.SensorStore
+}
+
+func (s *sensors) GetSensor(feedId int) (*types.Sensor, error) {
+	return nil, fmt.Errorf("sensor %d not found", feedId)
+}
+
+func TestMonitorPublishesThresholdCrossings(t *testing.T) {
+	bus := events.NewBus()
+	logs, alertStore := &sensorLogs{}, &alerts{}
+	p := &plans{plan: &types.Plan{ID: 1, SensorID: 3, Lower: "20"}}
+	NewMonitor(bus, &sensors{}, logs, p, alertStore, nil, plan.NewEvaluator()).Subscribe()
+
+	crossed := []events.ThresholdCrossed{}
+	bus.ThresholdCrossed.Subscribe(func(e events.ThresholdCrossed) {
+		crossed = append(crossed, e)
+	})
+
+	s := types.Sensor{FeedId: 3, UserID: 7, Type: "brightness"}
+	now := time.Now()
+	// a reading that isn't a number is no crossing of a bound
+	bus.SensorReading.Publish(events.NewSensorReading(s, "offline", now.Add(-time.Minute)))
+	bus.SensorReading.Publish(events.NewSensorReading(s, "12", now))
+	bus.SensorReading.Publish(events.NewSensorReading(s, "30", now.Add(time.Minute)))
+
+	if len(crossed) != 2 || crossed[0].Event.Kind != plan.AlertStarted || crossed[1].Event.Kind != plan.AlertCleared {
+		t.Fatalf("expected the alert to start then clear, got %+v", crossed)
+	}
+	if crossed[0].Event.Bound != "lower" || crossed[0].Sensor.UserID != 7 || crossed[0].Raw != "12" {
+		t.Errorf("unexpected crossing %+v", crossed[0])
+	}
+	if len(logs.logs) != 2 || logs.logs[0].MessageKey != "log.sensor.lower" || logs.logs[1].Type != "cleared" {
+		t.Errorf("expected a warning then a cleared log, got %+v", logs.logs)
+	}
+	if len(alertStore.alerts) != 1 || alertStore.resolved != 1 {
+		t.Errorf("expected an alert raised then resolved, got %+v and %d resolved", alertStore.alerts, alertStore.resolved)
+	}
+}
@@ -9,38 +9,284 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/services/comfort"
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/services/forecast"
+	"github.com/quanghia24/mySmartHome/services/i18n"
+	"github.com/quanghia24/mySmartHome/services/plan"
 	"github.com/quanghia24/mySmartHome/types"
 	"github.com/quanghia24/mySmartHome/utils"
-
-	MQTT "github.com/eclipse/paho.mqtt.golang"
 )
 
 type Handler struct {
 	store          types.SensorStore
+	deviceStore    types.DeviceStore
 	userStore      types.UserStore
+	roomStore      types.RoomStore
 	logSensorStore types.LogSensorStore
-	planStore      types.PlanStore
-	mqttClient     MQTT.Client
+	rollups        types.RollupStore
+	alertStore     types.AlertStore
+	evaluator      *plan.Evaluator
+	feeds          types.FeedSubscriptions
 }
 
-func NewHandler(store types.SensorStore, userStore types.UserStore, logSensorStore types.LogSensorStore, planStore types.PlanStore, mqttClient MQTT.Client) *Handler {
+func NewHandler(store types.SensorStore, deviceStore types.DeviceStore, userStore types.UserStore, roomStore types.RoomStore, logSensorStore types.LogSensorStore, rollups types.RollupStore, alertStore types.AlertStore, evaluator *plan.Evaluator, feeds types.FeedSubscriptions) *Handler {
 	return &Handler{
 		store:          store,
+		deviceStore:    deviceStore,
 		userStore:      userStore,
+		roomStore:      roomStore,
 		logSensorStore: logSensorStore,
-		planStore:      planStore,
-		mqttClient:     mqttClient,
+		rollups:        rollups,
+		alertStore:     alertStore,
+		evaluator:      evaluator,
+		feeds:          feeds,
 	}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/sensors", auth.WithJWTAuth(h.createSensor, h.userStore)).Methods(http.MethodPost)
 	router.HandleFunc("/sensors/{feed_id}", h.getSensorInfo).Methods(http.MethodGet)
+	router.HandleFunc("/sensors/{feed_id}", auth.WithJWTAuth(h.updateSensor, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/sensors/{feed_id}/anomaly", auth.WithJWTAuth(h.updateAnomalySensitivity, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/sensors/{feed_id}/forecast", auth.WithJWTAuth(h.getForecast, h.userStore)).Methods(http.MethodGet)
+}
+
+const (
+	// history the forecast model is fitted on
+	forecastHistory = 14 * 24 * time.Hour
+	// hours forecast by default and at most
+	defaultForecastHours = 6
+	maxForecastHours     = 48
+)
+
+type historyPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+type forecastPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	Lower float64   `json:"lower"`
+	Upper float64   `json:"upper"`
+}
+
+// getForecast predicts the hourly average of a sensor for the next hours
+// with a Holt-Winters model fitted on its own history, with a daily season.
+func (h *Handler) getForecast(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	feedId, err := strconv.Atoi(mux.Vars(r)["feed_id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid feed_id"))
+		return
+	}
+
+	hours := defaultForecastHours
+	if v := r.URL.Query().Get("hours"); v != "" {
+		hours, err = strconv.Atoi(v)
+		if err != nil || hours < 1 || hours > maxForecastHours {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("hours must be between 1 and %d", maxForecastHours))
+			return
+		}
+	}
+
+	sensor, err := h.store.GetSensor(feedId)
+	if err != nil || sensor.UserID != userId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("sensor %d not found", feedId))
+		return
+	}
+
+	// the current hour is still filling up, forecast from the last full one
+	now := time.Now().UTC().Truncate(time.Hour)
+	stats, err := h.rollups.GetSensorStats(feedId, types.Hourly, now.Add(-forecastHistory), now)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	series, start, err := forecast.HourlySeries(stats, now.Add(-forecastHistory), now)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	model, err := forecast.Fit(series, 24)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnprocessableEntity, fmt.Errorf("not enough history to forecast: %v", err))
+		return
+	}
+
+	// the last day of history for the trend line on the chart
+	history := []historyPoint{}
+	for i := max(0, len(series)-24); i < len(series); i++ {
+		history = append(history, historyPoint{
+			Time:  start.Add(time.Duration(i) * time.Hour),
+			Value: math.Round(series[i]*10) / 10,
+		})
+	}
+
+	// the forecast can't go past what the sensor is able to read
+	t, _ := devicetype.Lookup(sensor.Type)
+	lowest, highest, bounded := t.Schema.Bounds()
+	clamp := func(v float64) float64 {
+		if bounded {
+			v = math.Min(math.Max(v, lowest), highest)
+		}
+		return math.Round(v*10) / 10
+	}
+
+	points := []forecastPoint{}
+	for i, p := range model.Forecast(hours) {
+		points = append(points, forecastPoint{
+			Time:  now.Add(time.Duration(i) * time.Hour),
+			Value: clamp(p.Value),
+			Lower: clamp(p.Lower),
+			Upper: clamp(p.Upper),
+		})
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]any{
+		"feedId":   feedId,
+		"type":     sensor.Type,
+		"interval": "1h",
+		"model":    model,
+		"history":  history,
+		"forecast": points,
+	})
+}
+
+// updateSensor renames the sensor, moves it to another room, changes its
+// feed key or its type. Its history and plan stay attached to its feedId,
+// and the MQTT subscription follows a new feed key.
+func (h *Handler) updateSensor(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	feedId, err := strconv.Atoi(mux.Vars(r)["feed_id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid feed_id"))
+		return
+	}
+
+	var payload types.UpdateSensorPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	sensor, err := h.store.GetSensor(feedId)
+	if err != nil || sensor.UserID != userId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("sensor %d not found", feedId))
+		return
+	}
+	// derived metrics follow their room's pair
+	if sensor.Virtual {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s sensors can't be changed by hand", sensor.Type))
+		return
+	}
+	updated := *sensor
+
+	if payload.Title != nil {
+		updated.Title = *payload.Title
+	}
+	if payload.Type != nil {
+		if !devicetype.IsSensor(*payload.Type) || comfort.IsType(*payload.Type) {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown sensor type %s", *payload.Type))
+			return
+		}
+		updated.Type = *payload.Type
+	}
+	if payload.RoomID != nil {
+		room, err := h.roomStore.GetRoomByID(*payload.RoomID)
+		if err != nil || room.UserID != userId {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("room %d not found", *payload.RoomID))
+			return
+		}
+		updated.RoomID = room.ID
+	}
+	if payload.FeedKey != nil && *payload.FeedKey != sensor.FeedKey {
+		if other, err := h.store.GetSensorByFeedKey(*payload.FeedKey); err == nil && other.FeedId != feedId {
+			utils.WriteError(w, http.StatusConflict, fmt.Errorf("feed %s is used by sensor %d", *payload.FeedKey, other.FeedId))
+			return
+		}
+		// nor a device's, the subscriptions hand a feed's messages to one
+		if other, err := h.deviceStore.GetDeviceByFeedKey(*payload.FeedKey); err == nil {
+			utils.WriteError(w, http.StatusConflict, fmt.Errorf("feed %s is used by device %d", *payload.FeedKey, other.FeedId))
+			return
+		}
+		updated.FeedKey = *payload.FeedKey
+	}
+
+	if err := h.store.UpdateSensor(updated); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// the room it left may have lost its pair, the room it joined gained one
+	if updated.RoomID != sensor.RoomID || updated.Type != sensor.Type {
+		if err := h.retireComfortSensors(sensor.RoomID); err != nil {
+			log.Println("comfort sensors:", err)
+		}
+		if err := h.provisionComfortSensors(updated.RoomID); err != nil {
+			log.Println("comfort sensors:", err)
+		}
+	}
+
+	if updated.FeedKey != sensor.FeedKey {
+		if err := h.feeds.Unsubscribe(sensor.FeedKey); err != nil {
+			log.Println("mqtt unsubscribe:", err)
+		}
+		if err := h.feeds.Subscribe(updated.FeedKey); err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("mqtt subscribe error: %v", err))
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handler) updateAnomalySensitivity(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	feedId, err := strconv.Atoi(mux.Vars(r)["feed_id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid feed_id"))
+		return
+	}
+
+	var payload types.UpdateAnomalySensitivityPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload: %v", errors))
+		return
+	}
+
+	sensor, err := h.store.GetSensor(feedId)
+	if err != nil || sensor.UserID != userId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("sensor %d not found", feedId))
+		return
+	}
+
+	if err := h.store.UpdateAnomalySensitivity(feedId, payload.Sensitivity); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"anomalySensitivity": payload.Sensitivity})
 }
 
 func (h *Handler) getSensorInfo(w http.ResponseWriter, r *http.Request) {
@@ -71,6 +317,20 @@ func (h *Handler) createSensor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// derived metrics are added by the server once a room has its pair
+	if comfort.IsType(payload.Type) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%s sensors can't be added by hand", payload.Type))
+		return
+	}
+	if !devicetype.IsSensor(payload.Type) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown sensor type %s", payload.Type))
+		return
+	}
+	if comfort.IsVirtualFeedID(payload.FeedID) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid feedId %d", payload.FeedID))
+		return
+	}
+
 	err := h.store.CreateSensor(types.Sensor{
 		Title:   payload.Title,
 		FeedKey: payload.FeedKey,
@@ -86,77 +346,26 @@ func (h *Handler) createSensor(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = h.logSensorStore.CreateLogSensor(types.LogSensor{
-		Type:     "creation",
-		Message:  fmt.Sprintf("[%s] got added", payload.Title),
-		SensorID: payload.FeedID,
-		UserID:   userId,
-		Value: "0",
+		Type:       "creation",
+		MessageKey: "log.sensor.added",
+		Params:     i18n.Params{"title": payload.Title},
+		SensorID:   payload.FeedID,
+		UserID:     userId,
+		Value:      "0",
 	})
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return 
 	}
+
+	if err := h.provisionComfortSensors(payload.RoomID); err != nil {
+		log.Println("comfort sensors:", err)
+	}
 	
 
 	// mqtt
-	topic := fmt.Sprintf("%s/feeds/%s", os.Getenv("AIOUSER"), payload.FeedKey)
-	fmt.Println(topic)
-
-	if token := h.mqttClient.Subscribe(topic, 0, func(client MQTT.Client, msg MQTT.Message) {
-		fmt.Printf("New message on %s: %s\n", msg.Topic(), msg.Payload())
-		// check for plan
-
-		f, _ := strconv.ParseFloat(string(msg.Payload()), 32)
-
-		// Round to 1 decimal place
-		value := math.Round(f*10) / 10
-
-		// check for plan -> threshold
-		// fmt.Println("Check threshold for", payload.FeedID, "with value of", value)
-		plan, _ := h.planStore.GetPlansByFeedID(payload.FeedID)
-		// if err != nil {
-		// 	fmt.Println("Failed to get plans:", err)
-		// }
-		if plan != nil {
-			fmt.Println(*plan)
-			if plan.Lower != "" {
-				lower, _ := strconv.ParseFloat(plan.Lower, 32)
-				if lower > value {
-					fmt.Println("WARNING!!! lower")
-					err = h.logSensorStore.CreateLogSensor(types.LogSensor{
-						Type:     "warning",
-						Message:  fmt.Sprintf("%f below the %f lower bound", value, lower),
-						SensorID: payload.FeedID,
-						UserID:   userId,
-						Value:    string(msg.Payload()),
-					})
-
-					if err != nil {
-						log.Println("sensor log create:", err)
-					}
-				}
-			}
-			if plan.Upper != "" {
-				upper, _ := strconv.ParseFloat(plan.Upper, 32)
-				if upper < value {
-					fmt.Println("WARNING!!! upper")
-					err = h.logSensorStore.CreateLogSensor(types.LogSensor{
-						Type:     "warning",
-						Message:  fmt.Sprintf("%f exceed the %f upper bound", value, upper),
-						SensorID: payload.FeedID,
-						UserID:   userId,
-						Value:    string(msg.Payload()),
-					})
-
-					if err != nil {
-						log.Println("sensor log create:", err)
-					}
-				}
-			}
-		}
-
-	}); token.Wait() && token.Error() != nil {
-		fmt.Println("Failed to subscribe:", token.Error())
+	if err := h.feeds.Subscribe(payload.FeedKey); err != nil {
+		fmt.Println("Failed to subscribe:", err)
 	}
 
 	utils.WriteJSON(w, http.StatusCreated, nil)
@@ -174,9 +383,21 @@ func (h *Handler) StartSensorDataPolling() {
 			continue
 		}
 
+		var wg sync.WaitGroup
 		for _, sensor := range sensors {
-			go h.updateSensorData(sensor)
+			// virtual sensors are derived from the others below
+			if sensor.Virtual {
+				continue
+			}
+			wg.Add(1)
+			go func(sensor types.Sensor) {
+				defer wg.Done()
+				h.updateSensorData(sensor)
+			}(sensor)
 		}
+		wg.Wait()
+
+		h.updateComfortSensors(sensors)
 	}
 }
 
@@ -204,11 +425,12 @@ func (h *Handler) updateSensorData(sensor types.Sensor) {
 	}
 
 	err = h.logSensorStore.CreateLogSensor(types.LogSensor{
-		Type:     "data",
-		Message:  fmt.Sprintf("%s data recored", payload[0].Value),
-		SensorID: payload[0].FeedId,
-		UserID:   sensor.UserID,
-		Value:    payload[0].Value,
+		Type:       "data",
+		MessageKey: "log.sensor.data",
+		Params:     i18n.Params{"value": payload[0].Value},
+		SensorID:   payload[0].FeedId,
+		UserID:     sensor.UserID,
+		Value:      payload[0].Value,
 	})
 
 	if err != nil {
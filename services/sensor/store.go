@@ -18,10 +18,52 @@ func NewStore(db *sql.DB) *Store {
 }
 
 func (s *Store) CreateSensor(sensor types.Sensor) error {
-	_, err := s.db.Exec("INSERT INTO sensors (feedId, feedKey, title, type, userID, roomID) VALUES (?, ?, ?, ?, ?, ?)", sensor.FeedId, sensor.FeedKey, sensor.Title, sensor.Type, sensor.UserID, sensor.RoomID)
+	_, err := s.db.Exec("INSERT INTO sensors (feedId, feedKey, title, type, userID, roomID, isVirtual) VALUES (?, ?, ?, ?, ?, ?, ?)", sensor.FeedId, sensor.FeedKey, sensor.Title, sensor.Type, sensor.UserID, sensor.RoomID, sensor.Virtual)
 	return err
 }
 
+func (s *Store) GetSensor(feedId int) (*types.Sensor, error) {
+	sensor := new(types.Sensor)
+	err := s.db.QueryRow("SELECT feedId, feedKey, title, type, userId, roomId, anomalySensitivity, isVirtual FROM sensors WHERE feedId = ?", feedId).Scan(
+		&sensor.FeedId,
+		&sensor.FeedKey,
+		&sensor.Title,
+		&sensor.Type,
+		&sensor.UserID,
+		&sensor.RoomID,
+		&sensor.AnomalySensitivity,
+		&sensor.Virtual,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no sensor found for feedId %d", feedId)
+		}
+		return nil, err
+	}
+	return sensor, nil
+}
+
+func (s *Store) GetSensorByFeedKey(feedKey string) (*types.Sensor, error) {
+	sensor := new(types.Sensor)
+	err := s.db.QueryRow("SELECT feedId, feedKey, title, type, userId, roomId, anomalySensitivity, isVirtual FROM sensors WHERE feedKey = ? AND NOT isVirtual LIMIT 1", feedKey).Scan(
+		&sensor.FeedId,
+		&sensor.FeedKey,
+		&sensor.Title,
+		&sensor.Type,
+		&sensor.UserID,
+		&sensor.RoomID,
+		&sensor.AnomalySensitivity,
+		&sensor.Virtual,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no sensor found for feedKey %s", feedKey)
+		}
+		return nil, err
+	}
+	return sensor, nil
+}
+
 func (s *Store) GetSensorByFeedID(feedId int) (*types.DeviceDataPayload, error) {
 	query := `
 		SELECT s.feedId, s.feedKey, l.value, s.type, s.title, l.createdAt 
@@ -51,7 +93,7 @@ func (s *Store) GetSensorByFeedID(feedId int) (*types.DeviceDataPayload, error)
 }
 
 func (s *Store) GetAllSensor() ([]types.Sensor, error) {
-	rows, err := s.db.Query("SELECT * FROM sensors")
+	rows, err := s.db.Query("SELECT feedId, feedKey, title, type, userId, roomId, anomalySensitivity, isVirtual FROM sensors")
 	if err != nil {
 		return nil, err
 	}
@@ -70,7 +112,7 @@ func (s *Store) GetAllSensor() ([]types.Sensor, error) {
 }
 
 func (s *Store) GetSensorsByRoomId(roomId int) ([]types.Sensor, error) {
-	rows, err := s.db.Query("SELECT * FROM sensors where roomId = ?", roomId)
+	rows, err := s.db.Query("SELECT feedId, feedKey, title, type, userId, roomId, anomalySensitivity, isVirtual FROM sensors where roomId = ?", roomId)
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +130,22 @@ func (s *Store) GetSensorsByRoomId(roomId int) ([]types.Sensor, error) {
 	return sensors, nil
 }
 
+func (s *Store) UpdateSensor(sensor types.Sensor) error {
+	_, err := s.db.Exec("UPDATE sensors SET feedKey = ?, title = ?, type = ?, roomId = ? WHERE feedId = ?", sensor.FeedKey, sensor.Title, sensor.Type, sensor.RoomID, sensor.FeedId)
+	return err
+}
+
+// RemoveSensor deletes the sensor, its logs, stats and plan go with it.
+func (s *Store) RemoveSensor(feedId int) error {
+	_, err := s.db.Exec("DELETE FROM sensors WHERE feedId = ?", feedId)
+	return err
+}
+
+func (s *Store) UpdateAnomalySensitivity(feedId int, sensitivity string) error {
+	_, err := s.db.Exec("UPDATE sensors SET anomalySensitivity = ? WHERE feedId = ?", sensitivity, feedId)
+	return err
+}
+
 func scanIntoSensor(row *sql.Rows) (*types.Sensor, error) {
 	sensor := new(types.Sensor)
 
@@ -98,6 +156,8 @@ func scanIntoSensor(row *sql.Rows) (*types.Sensor, error) {
 		&sensor.Type,
 		&sensor.UserID,
 		&sensor.RoomID,
+		&sensor.AnomalySensitivity,
+		&sensor.Virtual,
 	)
 
 	if err != nil {
@@ -0,0 +1,121 @@
+package shadow
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// GetShadows returns the shadows of the devices that have one.
+func (s *Store) GetShadows(deviceIds []int) ([]types.DeviceShadow, error) {
+	shadows := []types.DeviceShadow{}
+	if len(deviceIds) == 0 {
+		return shadows, nil
+	}
+
+	placeholders := make([]string, len(deviceIds))
+	args := make([]any, len(deviceIds))
+	for i, id := range deviceIds {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	rows, err := s.db.Query(`
+		SELECT deviceId, desired, desiredAt, reported, reportedAt, status, error
+		FROM device_shadows
+		WHERE deviceId IN (`+strings.Join(placeholders, ", ")+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sh, err := scanRowIntoShadow(rows)
+		if err != nil {
+			return nil, err
+		}
+		shadows = append(shadows, *sh)
+	}
+	return shadows, rows.Err()
+}
+
+// GetPendingShadows returns the shadows of the commands still pending that
+// were sent before the given time.
+func (s *Store) GetPendingShadows(before time.Time) ([]types.DeviceShadow, error) {
+	rows, err := s.db.Query(`
+		SELECT deviceId, desired, desiredAt, reported, reportedAt, status, error
+		FROM device_shadows
+		WHERE status = ? AND desiredAt < ?
+	`, StatusPending, before.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shadows := []types.DeviceShadow{}
+	for rows.Next() {
+		sh, err := scanRowIntoShadow(rows)
+		if err != nil {
+			return nil, err
+		}
+		shadows = append(shadows, *sh)
+	}
+	return shadows, rows.Err()
+}
+
+func (s *Store) SaveShadow(sh types.DeviceShadow) error {
+	_, err := s.db.Exec(`
+		INSERT INTO device_shadows (deviceId, desired, desiredAt, reported, reportedAt, status, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			desired = VALUES(desired),
+			desiredAt = VALUES(desiredAt),
+			reported = VALUES(reported),
+			reportedAt = VALUES(reportedAt),
+			status = VALUES(status),
+			error = VALUES(error)
+	`, sh.DeviceID, sh.Desired, utcOrNil(sh.DesiredAt), sh.Reported, utcOrNil(sh.ReportedAt), sh.Status, sh.Error)
+	return err
+}
+
+func scanRowIntoShadow(rows *sql.Rows) (*types.DeviceShadow, error) {
+	sh := new(types.DeviceShadow)
+	var desiredAt, reportedAt sql.NullTime
+	err := rows.Scan(
+		&sh.DeviceID,
+		&sh.Desired,
+		&desiredAt,
+		&sh.Reported,
+		&reportedAt,
+		&sh.Status,
+		&sh.Error,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if desiredAt.Valid {
+		sh.DesiredAt = &desiredAt.Time
+	}
+	if reportedAt.Valid {
+		sh.ReportedAt = &reportedAt.Time
+	}
+	return sh, nil
+}
+
+func utcOrNil(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
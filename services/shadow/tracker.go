@@ -0,0 +1,248 @@
+package shadow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/events"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+// statuses of the last command to a device
+const (
+	StatusPending   = "pending"
+	StatusConfirmed = "confirmed"
+	StatusFailed    = "failed"
+)
+
+// error of a command the device didn't confirm in time
+const errTimedOut = "timed out"
+
+// Tracker sends the commands to the devices' feeds and follows each in the
+// device's shadow until the device reports the commanded value.
+//
+// Adafruit publishes every value posted to a feed on its MQTT topic, the
+// server's own posts included. On a feed a device both reads its commands
+// from and reports on, that echo is what confirms a command: it shows
+// Adafruit took the value and passed it on to the device, not that the
+// device applied it. A command stays pending, and times out, when the echo
+// never comes or the device reports another value over it.
+type Tracker struct {
+	store   types.ShadowStore
+	devices types.DeviceStore
+	bus     *events.Bus
+
+	// how long a device has to report a commanded value
+	timeout time.Duration
+	// posts a value to a feed
+	post func(feedKey string, value string) error
+
+	// shadows are read, changed and saved back under it
+	mu sync.Mutex
+
+	// the lights in a transition by device
+	transitions   map[int]*transition
+	transitionsMu sync.Mutex
+}
+
+func NewTracker(store types.ShadowStore, devices types.DeviceStore, bus *events.Bus) *Tracker {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return &Tracker{
+		store:   store,
+		devices: devices,
+		bus:     bus,
+		timeout: 30 * time.Second,
+		post: func(feedKey string, value string) error {
+			return postToFeed(client, feedKey, value)
+		},
+		transitions: map[int]*transition{},
+	}
+}
+
+// Send posts the value to the device's feed. The command is pending from then
+// on, or failed when Adafruit doesn't take it. It ends the transition the
+// device may be in, whose next step would undo the command.
+func (t *Tracker) Send(feedId int, feedKey string, value string) (types.DeviceShadow, error) {
+	t.stopTransition(feedId)
+	return t.send(feedId, feedKey, value)
+}
+
+// send saves the command as pending before posting it, the echo of the post
+// may come in before the post returns and has to find it.
+func (t *Tracker) send(feedId int, feedKey string, value string) (types.DeviceShadow, error) {
+	now := time.Now()
+
+	t.mu.Lock()
+	sh := t.shadow(feedId)
+	sh.Desired, sh.DesiredAt, sh.Status, sh.Error = value, &now, StatusPending, ""
+	if err := t.store.SaveShadow(sh); err != nil {
+		log.Println("save shadow:", err)
+	}
+	t.mu.Unlock()
+	t.publish(nil, sh)
+
+	err := t.post(feedKey, value)
+	if err == nil {
+		return sh, nil
+	}
+
+	t.mu.Lock()
+	// unless the value came through after all, or another command
+	// replaced it meanwhile
+	sh = t.shadow(feedId)
+	failed := sh.Status == StatusPending && sh.Desired == value
+	if failed {
+		sh.Status, sh.Error = StatusFailed, err.Error()
+		if err := t.store.SaveShadow(sh); err != nil {
+			log.Println("save shadow:", err)
+		}
+	}
+	t.mu.Unlock()
+
+	if failed {
+		t.publish(nil, sh)
+	}
+	return sh, err
+}
+
+// Shadows returns the shadows of the devices that have one by device.
+func (t *Tracker) Shadows(deviceIds []int) (map[int]types.DeviceShadow, error) {
+	shadows, err := t.store.GetShadows(deviceIds)
+	if err != nil {
+		return nil, err
+	}
+	byDevice := map[int]types.DeviceShadow{}
+	for _, sh := range shadows {
+		byDevice[sh.DeviceID] = sh
+	}
+	return byDevice, nil
+}
+
+// Subscribe confirms the pending commands with the values the devices
+// report.
+func (t *Tracker) Subscribe(bus *events.Bus) {
+	bus.DeviceStateChanged.Subscribe(func(e events.DeviceStateChanged) {
+		t.mu.Lock()
+		sh := reported(t.shadow(e.Device.FeedId), e.Value, e.At)
+		if err := t.store.SaveShadow(sh); err != nil {
+			log.Println("save shadow:", err)
+		}
+		t.mu.Unlock()
+
+		device := e.Device
+		t.publish(&device, sh)
+	})
+}
+
+// Start fails the commands the devices didn't confirm in time.
+func (t *Tracker) Start() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		t.expire(time.Now())
+	}
+}
+
+func (t *Tracker) expire(now time.Time) {
+	pending, err := t.store.GetPendingShadows(now.Add(-t.timeout))
+	if err != nil {
+		log.Println("get pending shadows:", err)
+		return
+	}
+
+	for _, p := range pending {
+		t.mu.Lock()
+		// the device may have reported, or got another command, since
+		sh := t.shadow(p.DeviceID)
+		if sh.Status != StatusPending || sh.DesiredAt == nil || !sh.DesiredAt.Equal(*p.DesiredAt) {
+			t.mu.Unlock()
+			continue
+		}
+		sh.Status, sh.Error = StatusFailed, errTimedOut
+		if err := t.store.SaveShadow(sh); err != nil {
+			log.Println("save shadow:", err)
+		}
+		t.mu.Unlock()
+
+		t.publish(nil, sh)
+	}
+}
+
+// reported is the shadow once the device reported the value. The command is
+// confirmed when it's the commanded value, even if it came in after the
+// command timed out.
+func reported(sh types.DeviceShadow, value string, at time.Time) types.DeviceShadow {
+	sh.Reported, sh.ReportedAt = value, &at
+	if value != sh.Desired {
+		return sh
+	}
+	if sh.Status == StatusPending || (sh.Status == StatusFailed && sh.Error == errTimedOut) {
+		sh.Status, sh.Error = StatusConfirmed, ""
+	}
+	return sh
+}
+
+// shadow returns the stored shadow of the device, an empty one when it has
+// none yet.
+func (t *Tracker) shadow(deviceId int) types.DeviceShadow {
+	shadows, err := t.store.GetShadows([]int{deviceId})
+	if err != nil {
+		log.Println("get shadow:", err)
+	}
+	if len(shadows) == 0 {
+		return types.DeviceShadow{DeviceID: deviceId}
+	}
+	return shadows[0]
+}
+
+func (t *Tracker) publish(device *types.Device, sh types.DeviceShadow) {
+	if device == nil {
+		d, err := t.devices.GetDevice(sh.DeviceID)
+		if err != nil {
+			log.Println("shadow of unknown device:", err)
+			return
+		}
+		device = d
+	}
+	t.bus.ShadowChanged.Publish(events.ShadowChanged{Device: *device, Shadow: sh})
+}
+
+func postToFeed(client *http.Client, feedKey string, value string) error {
+	url := os.Getenv("AIOAPI") + feedKey + "/data"
+	log.Println("adding data to", url)
+
+	apiKey := os.Getenv("AIOKey")
+	if apiKey == "" {
+		return fmt.Errorf("missing AIO Key")
+	}
+
+	jsonData, err := json.Marshal(map[string]string{"value": value})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-AIO-Key", apiKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("adafruit responded %s", resp.Status)
+	}
+	return nil
+}
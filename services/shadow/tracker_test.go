@@ -0,0 +1,187 @@
+package shadow
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/events"
+	"github.com/quanghia24/mySmartHome/services/light"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+type shadows struct {
+	types.ShadowStore
+	byDevice map[int]types.DeviceShadow
+}
+
+func (s *shadows) GetShadows(deviceIds []int) ([]types.DeviceShadow, error) {
+	found := []types.DeviceShadow{}
+	for _, id := range deviceIds {
+		if sh, ok := s.byDevice[id]; ok {
+			found = append(found, sh)
+		}
+	}
+	return found, nil
+}
+
+func (s *shadows) GetPendingShadows(before time.Time) ([]types.DeviceShadow, error) {
+	pending := []types.DeviceShadow{}
+	for _, sh := range s.byDevice {
+		if sh.Status == StatusPending && sh.DesiredAt.Before(before) {
+			pending = append(pending, sh)
+		}
+	}
+	return pending, nil
+}
+
+func (s *shadows) SaveShadow(sh types.DeviceShadow) error {
+	s.byDevice[sh.DeviceID] = sh
+	return nil
+}
+
+type devices struct {
+	types.DeviceStore
+}
+
+func (s *devices) GetDevice(feedId int) (*types.Device, error) {
+	return &types.Device{FeedId: feedId, FeedKey: "fan", Type: "fan", UserID: 7}, nil
+}
+
+func newTestTracker(post func(string, string) error) (*Tracker, *shadows, *[]types.DeviceShadow) {
+	bus := events.NewBus()
+	store := &shadows{byDevice: map[int]types.DeviceShadow{}}
+	t := &Tracker{store: store, devices: &devices{}, bus: bus, timeout: 30 * time.Second, post: post, transitions: map[int]*transition{}}
+	t.Subscribe(bus)
+
+	published := []types.DeviceShadow{}
+	bus.ShadowChanged.Subscribe(func(e events.ShadowChanged) {
+		published = append(published, e.Shadow)
+	})
+	return t, store, &published
+}
+
+func TestTrackerConfirmsReportedCommands(t *testing.T) {
+	tracker, store, published := newTestTracker(func(string, string) error { return nil })
+
+	sh, err := tracker.Send(1, "fan", "75")
+	if err != nil || sh.Status != StatusPending || sh.Desired != "75" {
+		t.Fatalf("expected a pending command, got %+v, %v", sh, err)
+	}
+
+	// a value on its way from before the command leaves it pending
+	tracker.bus.DeviceStateChanged.Publish(events.DeviceStateChanged{Device: types.Device{FeedId: 1}, Value: "50", At: time.Now()})
+	if got := store.byDevice[1]; got.Status != StatusPending || got.Reported != "50" {
+		t.Fatalf("expected the command to stay pending, got %+v", got)
+	}
+
+	tracker.bus.DeviceStateChanged.Publish(events.DeviceStateChanged{Device: types.Device{FeedId: 1}, Value: "75", At: time.Now()})
+	if got := store.byDevice[1]; got.Status != StatusConfirmed || got.Reported != "75" {
+		t.Fatalf("expected the command to be confirmed, got %+v", got)
+	}
+
+	if len(*published) != 3 || (*published)[2].Status != StatusConfirmed {
+		t.Errorf("expected every change to be published, got %+v", *published)
+	}
+}
+
+func TestTrackerConfirmsEchoBeforePostReturns(t *testing.T) {
+	var tracker *Tracker
+	tracker, store, _ := newTestTracker(func(feedKey string, value string) error {
+		// Adafruit publishes the post on the feed before answering it
+		tracker.bus.DeviceStateChanged.Publish(events.DeviceStateChanged{Device: types.Device{FeedId: 1}, Value: value, At: time.Now()})
+		return nil
+	})
+
+	tracker.Send(1, "fan", "75")
+	if got := store.byDevice[1]; got.Status != StatusConfirmed || got.Reported != "75" {
+		t.Errorf("expected the echo to confirm the command, got %+v", got)
+	}
+}
+
+func TestTrackerFailsRejectedCommands(t *testing.T) {
+	tracker, store, _ := newTestTracker(func(string, string) error {
+		return fmt.Errorf("adafruit responded 429 Too Many Requests")
+	})
+
+	if _, err := tracker.Send(1, "fan", "75"); err == nil {
+		t.Fatal("expected the error of the post")
+	}
+	if got := store.byDevice[1]; got.Status != StatusFailed || got.Error == "" {
+		t.Errorf("expected a failed command, got %+v", got)
+	}
+}
+
+func TestTrackerTimesOutUnconfirmedCommands(t *testing.T) {
+	tracker, store, _ := newTestTracker(func(string, string) error { return nil })
+
+	tracker.Send(1, "fan", "75")
+	tracker.expire(time.Now())
+	if got := store.byDevice[1]; got.Status != StatusPending {
+		t.Fatalf("expected the command to be pending within the timeout, got %+v", got)
+	}
+
+	tracker.expire(time.Now().Add(time.Minute))
+	if got := store.byDevice[1]; got.Status != StatusFailed || got.Error != errTimedOut {
+		t.Fatalf("expected the command to time out, got %+v", got)
+	}
+
+	// the device may still come through late
+	tracker.bus.DeviceStateChanged.Publish(events.DeviceStateChanged{Device: types.Device{FeedId: 1}, Value: "75", At: time.Now()})
+	if got := store.byDevice[1]; got.Status != StatusConfirmed || got.Error != "" {
+		t.Errorf("expected a late report to confirm the command, got %+v", got)
+	}
+}
+
+func TestSendEndsTransition(t *testing.T) {
+	posted := make(chan string, 4)
+	tracker, _, _ := newTestTracker(func(feedKey string, value string) error {
+		posted <- value
+		return nil
+	})
+
+	tracker.Transition(1, "light", []light.Step{{Value: "#101010"}, {After: time.Hour, Value: "#FFFFFF"}})
+	if v := <-posted; v != "#101010" {
+		t.Fatalf("expected the first step, got %s", v)
+	}
+
+	// a command from a schedule or an automation ends the fade
+	tracker.Send(1, "light", "#000000")
+	if v := <-posted; v != "#000000" {
+		t.Fatalf("expected the command, got %s", v)
+	}
+
+	tracker.transitionsMu.Lock()
+	defer tracker.transitionsMu.Unlock()
+	if len(tracker.transitions) != 0 {
+		t.Errorf("expected the transition to be ended, got %v", tracker.transitions)
+	}
+}
+
+func TestConcurrentTransitionsAllEnd(t *testing.T) {
+	posted := make(chan string, 64)
+	tracker, _, _ := newTestTracker(func(feedKey string, value string) error {
+		posted <- value
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tracker.Transition(1, "light", []light.Step{{After: 50 * time.Millisecond, Value: "#FFFFFF"}})
+		}()
+	}
+	wg.Wait()
+	tracker.Send(1, "light", "#000000")
+
+	time.Sleep(100 * time.Millisecond)
+	close(posted)
+	for v := range posted {
+		if v != "#000000" {
+			t.Errorf("expected every transition ended by the command, got %s posted", v)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package shadow
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/light"
+)
+
+// transition is the light fade a device is in. Its steps are sent under mu,
+// so one being sent is out before the transition can be ended.
+type transition struct {
+	mu   sync.Mutex
+	done chan struct{}
+}
+
+// Transition sends the steps of a light's transition to its feed in the
+// background. Another transition or any command sent to the device ends it.
+func (t *Tracker) Transition(feedId int, feedKey string, steps []light.Step) {
+	tr := &transition{done: make(chan struct{})}
+
+	// swapped under one lock, so a concurrent call can't slip another one
+	// in between and leave it running untracked
+	t.transitionsMu.Lock()
+	if old, ok := t.transitions[feedId]; ok {
+		old.end()
+	}
+	t.transitions[feedId] = tr
+	t.transitionsMu.Unlock()
+
+	go func() {
+		defer t.finishTransition(feedId, tr)
+
+		started := time.Now()
+		for _, step := range steps {
+			select {
+			case <-tr.done:
+				return
+			case <-time.After(time.Until(started.Add(step.After))):
+			}
+			if !t.sendStep(feedId, feedKey, tr, step.Value) {
+				return
+			}
+		}
+	}()
+}
+
+// sendStep sends a step unless the transition was ended meanwhile, it
+// reports whether the transition goes on.
+func (t *Tracker) sendStep(feedId int, feedKey string, tr *transition, value string) bool {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	select {
+	case <-tr.done:
+		return false
+	default:
+	}
+	if _, err := t.send(feedId, feedKey, value); err != nil {
+		log.Println("light transition:", err)
+		return false
+	}
+	return true
+}
+
+// stopTransition ends the transition of the device, once the step being
+// sent, if any, is out.
+func (t *Tracker) stopTransition(feedId int) {
+	t.transitionsMu.Lock()
+	defer t.transitionsMu.Unlock()
+
+	if tr, ok := t.transitions[feedId]; ok {
+		tr.end()
+		delete(t.transitions, feedId)
+	}
+}
+
+func (tr *transition) end() {
+	tr.mu.Lock()
+	close(tr.done)
+	tr.mu.Unlock()
+}
+
+func (t *Tracker) finishTransition(feedId int, tr *transition) {
+	t.transitionsMu.Lock()
+	defer t.transitionsMu.Unlock()
+	if t.transitions[feedId] == tr {
+		delete(t.transitions, feedId)
+	}
+}
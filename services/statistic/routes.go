@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"sort"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/services/retention"
+	"github.com/quanghia24/mySmartHome/services/rollup"
+	"github.com/quanghia24/mySmartHome/services/tariff"
 	"github.com/quanghia24/mySmartHome/types"
 	"github.com/quanghia24/mySmartHome/utils"
 )
@@ -21,9 +24,12 @@ type Handler struct {
 	roomStore   types.RoomStore
 	deviceStore types.DeviceStore
 	sensorStore types.SensorStore
+	rollups     types.RollupStore
+	retention   retention.Config
+	tariff      tariff.Tariff
 }
 
-func NewHandler(deviceLog types.LogDeviceStore, sensorLog types.LogSensorStore, userStore types.UserStore, roomStore types.RoomStore, deviceStore types.DeviceStore, sensorStore types.SensorStore) *Handler {
+func NewHandler(deviceLog types.LogDeviceStore, sensorLog types.LogSensorStore, userStore types.UserStore, roomStore types.RoomStore, deviceStore types.DeviceStore, sensorStore types.SensorStore, rollups types.RollupStore, retention retention.Config, tariff tariff.Tariff) *Handler {
 	return &Handler{
 		deviceLog:   deviceLog,
 		sensorLog:   sensorLog,
@@ -31,6 +37,9 @@ func NewHandler(deviceLog types.LogDeviceStore, sensorLog types.LogSensorStore,
 		roomStore:   roomStore,
 		deviceStore: deviceStore,
 		sensorStore: sensorStore,
+		rollups:     rollups,
+		retention:   retention,
+		tariff:      tariff,
 	}
 }
 
@@ -45,6 +54,7 @@ func (h *Handler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/statistic/rooms/{room_id}", h.getStatisticByRoom).Methods(http.MethodPost)
 	router.HandleFunc("/statistic/rooms/{room_id}/{device_type}", h.getRoomDeviceStatistic).Methods(http.MethodPost)
 	router.HandleFunc("/statistic/rooms-electric", auth.WithJWTAuth(h.getElectricBills, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/statistic/bill", auth.WithJWTAuth(h.getMonthlyBill, h.userStore)).Methods(http.MethodGet)
 
 	router.HandleFunc("/statistic/type/{device_type}", auth.WithJWTAuth(h.getDeviceUsageByType, h.userStore)).Methods(http.MethodPost)
 
@@ -64,51 +74,20 @@ func (h *Handler) getSensorByRoom(w http.ResponseWriter, r *http.Request) {
 	sensors, err := h.sensorStore.GetSensorsByRoomId(room_id)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
-		return 
+		return
 	}
 
 	var result = make(map[string]interface{})
 
+	loc := h.roomLocation(room_id)
 	for _, sensor := range sensors {
-		// get all log belong to
-		logs, err := h.sensorLog.GetSensorsByFeedIDBetween(sensor.FeedId, payload.Start, payload.End)
+		averages, err := h.dailySensorAverages(sensor, payload.Start, payload.End, loc)
 		if err != nil {
 			utils.WriteError(w, http.StatusInternalServerError, err)
 			return
 		}
-		if len(logs) == 0 {
-			utils.WriteJSON(w, http.StatusOK, map[string]float64{})
-			return
-		}
-
-		// Group by date and calculate average
-		type dateStat struct {
-			total float64
-			count int
-		}
-		dailyStats := make(map[string]*dateStat)
-
-		for _, log := range logs {
-			createdAt := log.CreatedAt.Format("2006-01-02") // Only keep date part
-			value, err := strconv.ParseFloat(log.Value, 64)
-			if err != nil {
-				continue // skip if value cannot be parsed
-			}
-
-			if _, exists := dailyStats[createdAt]; !exists {
-				dailyStats[createdAt] = &dateStat{}
-			}
-			dailyStats[createdAt].total += value
-			dailyStats[createdAt].count++
-		}
 
-		// Build response
-		temp := initDateList(payload.Start, payload.End)
-		for date, stat := range dailyStats {
-			temp[date] = stat.total / float64(stat.count)
-		}
-
-		result[sensor.Type] = temp
+		result[sensor.Type] = averages
 	}
 
 	utils.WriteJSON(w, http.StatusOK, result)
@@ -131,30 +110,14 @@ func (h *Handler) getDeviceUsageByType(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	startDate := payload.Start.Truncate(24 * time.Hour) // today at 00:00
-	endDate := payload.End.Add(24 * time.Hour)          // tomorrow 00:00
+	loc := h.userLocation(userId)
+	startDate := utils.StartOfDay(payload.Start, loc) // first day at 00:00
+	endDate := utils.NextDayStart(payload.End, loc)   // the day after the last at 00:00
 
-	offvalue := "0"
-	if mtype == "light" {
-		offvalue = "#000000"
-	}
-
-	var Total float64 = 0
-
-
-	for _, deviceFeedId := range devices {
-		// Get logs of the device today
-		logs, err := h.deviceLog.GetLogsByFeedIDBetween(deviceFeedId, startDate, endDate)
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-
-		// Calculate total ON-time in hours
-		totalHours := calculateOnTimeHours(logs, endDate, offvalue)
-
-		// Apply energy multiplier
-		Total += totalHours
+	Total, err := h.totalOnHours(devices, startDate, endDate)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, map[string]float64{"total": Total})
@@ -178,97 +141,45 @@ func (h *Handler) getGraphicalStatistic(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	offvalue := "0"
-	if mtype == "light" {
-		offvalue = "#000000"
-	}
-
-	startDate := payload.Start.Truncate(24 * time.Hour) // today at 00:00
-	endDate := payload.End.Add(24 * time.Hour)          // tomorrow 00:00
+	loc := h.userLocation(userId)
+	startDate := utils.StartOfDay(payload.Start, loc) // first day at 00:00
+	endDate := utils.NextDayStart(payload.End, loc)   // the day after the last at 00:00
 
+	deviceTypes := []string{mtype}
 	if mtype == "all" {
-		fresult := make(map[int]float64)
-		lresult := make(map[int]float64)
+		deviceTypes = []string{}
+		for _, t := range devicetype.Metered() {
+			deviceTypes = append(deviceTypes, t.Name)
+		}
+	}
 
-		for _, room := range rooms {
-			fresult[room.ID] = 0
-			lresult[room.ID] = 0
+	results := make(map[string]map[int]float64)
+	for _, deviceType := range deviceTypes {
+		result := make(map[int]float64)
 
-			fans, err := h.deviceStore.GetDevicesByRoomIdAndType(room.ID, "fan")
+		for _, room := range rooms {
+			devices, err := h.deviceStore.GetDevicesByRoomIdAndType(room.ID, deviceType)
 			if err != nil {
-				utils.WriteJSON(w, http.StatusInternalServerError, err)
+				utils.WriteError(w, http.StatusInternalServerError, err)
 				return
 			}
-			for _, deviceId := range fans {
-				// Get logs of the device today
-				logs, err := h.deviceLog.GetLogsByFeedIDBetween(deviceId, startDate, endDate)
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-
-				// Calculate total ON-time in hours
-				totalHours := calculateOnTimeHours(logs, endDate, "0")
-
-				// Apply energy multiplier
-				fresult[room.ID] += totalHours
-			}
 
-			lights, err := h.deviceStore.GetDevicesByRoomIdAndType(room.ID, "light")
+			result[room.ID], err = h.totalOnHours(devices, startDate, endDate)
 			if err != nil {
-				utils.WriteJSON(w, http.StatusInternalServerError, err)
+				utils.WriteError(w, http.StatusInternalServerError, err)
 				return
 			}
-			for _, deviceId := range lights {
-				// Get logs of the device today
-				logs, err := h.deviceLog.GetLogsByFeedIDBetween(deviceId, startDate, endDate)
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-
-				// Calculate total ON-time in hours
-				totalHours := calculateOnTimeHours(logs, endDate, "#000000")
-
-				// Apply energy multiplier
-				lresult[room.ID] += totalHours
-			}
 		}
 
-		utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
-			"fan":   fresult,
-			"light": lresult,
-		})
-		return
+		results[deviceType] = result
 	}
 
-	result := make(map[int]float64)
-
-	for _, room := range rooms {
-		result[room.ID] = 0
-		devices, err := h.deviceStore.GetDevicesByRoomIdAndType(room.ID, mtype)
-		if err != nil {
-			utils.WriteJSON(w, http.StatusInternalServerError, err)
-			return
-		}
-
-		for _, deviceId := range devices {
-			// Get logs of the device today
-			logs, err := h.deviceLog.GetLogsByFeedIDBetween(deviceId, startDate, endDate)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-
-			// Calculate total ON-time in hours
-			totalHours := calculateOnTimeHours(logs, endDate, offvalue)
-
-			// Apply energy multiplier
-			result[room.ID] += totalHours
-		}
+	if mtype == "all" {
+		utils.WriteJSON(w, http.StatusOK, results)
+		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, result)
+	utils.WriteJSON(w, http.StatusOK, results[mtype])
 }
 
 func (h *Handler) getDeviceTotalStatistic(w http.ResponseWriter, r *http.Request) {
@@ -281,66 +192,26 @@ func (h *Handler) getDeviceTotalStatistic(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	device, err := h.deviceStore.GetDevicesByFeedID(feed_id)
-	if err != nil {
+	if _, err := h.deviceStore.GetDevicesByFeedID(feed_id); err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	offvalue := "0"
-	if device.Type == "light" {
-		offvalue = "#000000"
-	}
-
-	// get all log
-	logs, err := h.deviceLog.GetLogsByFeedIDBetween(feed_id, payload.Start, payload.End)
+	res := h.retention.DeviceResolution(payload.Start, time.Hour, time.Now())
+	usage, err := h.rollups.GetDeviceUsage([]int{feed_id}, res, payload.Start, payload.End)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	if len(logs) == 0 {
+	if len(usage) == 0 {
 		utils.WriteJSON(w, http.StatusOK, map[string]float64{})
 		return
 	}
 
-	// Sort logs by createdAt (just in case DB doesn't guarantee)
-	sort.Slice(logs, func(i, j int) bool {
-		return logs[i].CreatedAt.Before(logs[j].CreatedAt)
-	})
-
-	var lastOnTime *time.Time
-	dayHours := initDateList(payload.Start, payload.End) // date "YYYY-MM-DD" -> total running hours
-
-	for _, log := range logs {
-		createdAt := log.CreatedAt
-		isOn := log.Value != offvalue // value 0 means off
-
-		if isOn && lastOnTime == nil {
-			// Device just turned on
-			lastOnTime = &createdAt
-		} else if !isOn && lastOnTime != nil {
-			// Device just turned off -> calculate time
-			hoursByDay := splitDurationByDay(*lastOnTime, createdAt)
-			for day, hours := range hoursByDay {
-				dayHours[day] += hours
-			}
-			lastOnTime = nil
-		}
-	}
-
-	// If still "on" at the end of log, close the period
-	if lastOnTime != nil {
-		endTime := payload.End
-		hoursByDay := splitDurationByDay(*lastOnTime, endTime)
-		for day, hours := range hoursByDay {
-			dayHours[day] += hours
-		}
-	}
-
 	total := 0.0
-	for _, value := range dayHours {
-		total += value
+	for _, u := range usage {
+		total += u.OnSeconds / 3600
 	}
 
 	utils.WriteJSON(w, http.StatusOK, map[string]float64{"total": total})
@@ -362,113 +233,18 @@ func (h *Handler) getRoomAllStatistic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fanHours := initDateList(payload.Start, payload.End)
-	lightHours := initDateList(payload.Start, payload.End)
-
+	roomIds := []int{}
 	for _, room := range rooms {
-		// get devices list
-		fans, err := h.deviceStore.GetDevicesByRoomIdAndType(room.ID, "fan")
-		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, err)
-			return
-		}
-		lights, err := h.deviceStore.GetDevicesByRoomIdAndType(room.ID, "light")
-		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, err)
-			return
-		}
-
-		// get logs
-		for _, fanId := range fans {
-			logs, err := h.deviceLog.GetLogsByFeedIDBetween(fanId, payload.Start, payload.End)
-			if err != nil {
-				utils.WriteError(w, http.StatusInternalServerError, err)
-				return
-			}
-
-			// Sort logs by createdAt (just in case DB doesn't guarantee)
-			sort.Slice(logs, func(i, j int) bool {
-				return logs[i].CreatedAt.Before(logs[j].CreatedAt)
-			})
-
-			var lastOnTime *time.Time
-
-			for _, log := range logs {
-				createdAt := log.CreatedAt
-				isOn := log.Value != "0" // value 0 means off
-
-				if isOn && lastOnTime == nil {
-					// Device just turned on
-					lastOnTime = &createdAt
-				} else if !isOn && lastOnTime != nil {
-					// Device just turned off -> calculate time
-					hoursByDay := splitDurationByDay(*lastOnTime, createdAt)
-					for day, hours := range hoursByDay {
-						fanHours[day] += hours
-					}
-					lastOnTime = nil
-				}
-			}
-
-			// If still "on" at the end of log, close the period
-			// if lastOnTime != nil {
-			// 	fmt.Println(lastOnTime)
-			// 	endTime := payload.End
-			// 	hoursByDay := splitDurationByDay(*lastOnTime, endTime)
-			// 	for day, hours := range hoursByDay {
-			// 		fanHours[day] += hours
-			// 	}
-			// }
-		}
-
-		for _, lightId := range lights {
-			logs, err := h.deviceLog.GetLogsByFeedIDBetween(lightId, payload.Start, payload.End)
-			if err != nil {
-				utils.WriteError(w, http.StatusInternalServerError, err)
-				return
-			}
-
-			// Sort logs by createdAt (just in case DB doesn't guarantee)
-			sort.Slice(logs, func(i, j int) bool {
-				return logs[i].CreatedAt.Before(logs[j].CreatedAt)
-			})
-
-			var lastOnTime *time.Time
-
-			for _, log := range logs {
-				createdAt := log.CreatedAt
-				isOn := log.Value != "#000000" // value 0 means off
-
-				if isOn && lastOnTime == nil {
-					// Device just turned on
-					lastOnTime = &createdAt
-				} else if !isOn && lastOnTime != nil {
-					// Device just turned off -> calculate time
-					hoursByDay := splitDurationByDay(*lastOnTime, createdAt)
-					for day, hours := range hoursByDay {
-						lightHours[day] += hours
-					}
-					lastOnTime = nil
-				}
-			}
-
-			// If still "on" at the end of log, close the period
-			// if lastOnTime != nil {
-			// 	fmt.Println(lastOnTime)
-			// 	endTime := payload.End
-			// 	hoursByDay := splitDurationByDay(*lastOnTime, endTime)
-			// 	for day, hours := range hoursByDay {
-			// 		lightHours[day] += hours
-			// 	}
-			// }
-		}
+		roomIds = append(roomIds, room.ID)
+	}
 
+	result, err := h.dailyOnHoursByType(roomIds, payload.Start, payload.End, h.userLocation(userId))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
-		"fan":   fanHours,
-		"light": lightHours,
-	})
+	utils.WriteJSON(w, http.StatusOK, result)
 }
 
 func (h *Handler) getStatisticByRoom(w http.ResponseWriter, r *http.Request) {
@@ -481,109 +257,36 @@ func (h *Handler) getStatisticByRoom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fanHours := initDateList(payload.Start, payload.End)
-	lightHours := initDateList(payload.Start, payload.End)
-
-	// get devices list
-	fans, err := h.deviceStore.GetDevicesByRoomIdAndType(room_id, "fan")
-	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	lights, err := h.deviceStore.GetDevicesByRoomIdAndType(room_id, "light")
+	result, err := h.dailyOnHoursByType([]int{room_id}, payload.Start, payload.End, h.roomLocation(room_id))
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	// get logs
-	for _, fanId := range fans {
-		logs, err := h.deviceLog.GetLogsByFeedIDBetween(fanId, payload.Start, payload.End)
-		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, err)
-			return
-		}
-
-		// Sort logs by createdAt (just in case DB doesn't guarantee)
-		sort.Slice(logs, func(i, j int) bool {
-			return logs[i].CreatedAt.Before(logs[j].CreatedAt)
-		})
-
-		var lastOnTime *time.Time
-
-		for _, log := range logs {
-			createdAt := log.CreatedAt
-			isOn := log.Value != "0" // value 0 means off
-
-			if isOn && lastOnTime == nil {
-				// Device just turned on
-				lastOnTime = &createdAt
-			} else if !isOn && lastOnTime != nil {
-				// Device just turned off -> calculate time
-				hoursByDay := splitDurationByDay(*lastOnTime, createdAt)
-				for day, hours := range hoursByDay {
-					fanHours[day] += hours
-				}
-				lastOnTime = nil
-			}
-		}
+	utils.WriteJSON(w, http.StatusOK, result)
+}
 
-		// If still "on" at the end of log, close the period
-		if lastOnTime != nil {
-			endTime := payload.End
-			hoursByDay := splitDurationByDay(*lastOnTime, endTime)
-			for day, hours := range hoursByDay {
-				fanHours[day] += hours
+// dailyOnHoursByType returns the daily on hours of the devices of the rooms,
+// keyed by the device types drawing power.
+func (h *Handler) dailyOnHoursByType(roomIds []int, start, end time.Time, loc *time.Location) (map[string]map[string]float64, error) {
+	result := map[string]map[string]float64{}
+	for _, t := range devicetype.Metered() {
+		devices := []int{}
+		for _, roomId := range roomIds {
+			roomDevices, err := h.deviceStore.GetDevicesByRoomIdAndType(roomId, t.Name)
+			if err != nil {
+				return nil, err
 			}
+			devices = append(devices, roomDevices...)
 		}
-	}
 
-	for _, lightId := range lights {
-		logs, err := h.deviceLog.GetLogsByFeedIDBetween(lightId, payload.Start, payload.End)
+		hours, err := h.dailyOnHours(devices, start, end, loc)
 		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, err)
-			return
-		}
-
-		// Sort logs by createdAt (just in case DB doesn't guarantee)
-		sort.Slice(logs, func(i, j int) bool {
-			return logs[i].CreatedAt.Before(logs[j].CreatedAt)
-		})
-
-		var lastOnTime *time.Time
-
-		for _, log := range logs {
-			createdAt := log.CreatedAt
-			isOn := log.Value != "#000000" // value 0 means off
-
-			if isOn && lastOnTime == nil {
-				// Device just turned on
-				lastOnTime = &createdAt
-			} else if !isOn && lastOnTime != nil {
-				// Device just turned off -> calculate time
-				hoursByDay := splitDurationByDay(*lastOnTime, createdAt)
-				for day, hours := range hoursByDay {
-					lightHours[day] += hours
-				}
-				lastOnTime = nil
-			}
-		}
-
-		// If still "on" at the end of log, close the period
-		if lastOnTime != nil {
-			endTime := payload.End
-			hoursByDay := splitDurationByDay(*lastOnTime, endTime)
-			for day, hours := range hoursByDay {
-				lightHours[day] += hours
-			}
+			return nil, err
 		}
+		result[t.Name] = hours
 	}
-
-	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
-		"fan":   fanHours,
-		"light": lightHours,
-	})
+	return result, nil
 }
 
 func (h *Handler) getRoomDeviceStatistic(w http.ResponseWriter, r *http.Request) {
@@ -597,37 +300,21 @@ func (h *Handler) getRoomDeviceStatistic(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var Total float64 = 0
-
 	// getall device in room of type device_type
 	devices, err := h.deviceStore.GetDevicesByRoomIdAndType(room_id, mtype)
 	if err != nil {
-		utils.WriteJSON(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	startDate := payload.Start.Truncate(24 * time.Hour) // today at 00:00
-	endDate := payload.End.Add(24 * time.Hour)          // tomorrow 00:00
+	loc := h.roomLocation(room_id)
+	startDate := utils.StartOfDay(payload.Start, loc) // first day at 00:00
+	endDate := utils.NextDayStart(payload.End, loc)   // the day after the last at 00:00
 
-	// return total device type
-	offvalue := "0"
-	if mtype == "light" {
-		offvalue = "#000000"
-	}
-
-	for _, deviceFeedId := range devices {
-		// Get logs of the device today
-		logs, err := h.deviceLog.GetLogsByFeedIDBetween(deviceFeedId, startDate, endDate)
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-
-		// Calculate total ON-time in hours
-		totalHours := calculateOnTimeHours(logs, endDate, offvalue)
-
-		// Apply energy multiplier
-		Total += totalHours
+	Total, err := h.totalOnHours(devices, startDate, endDate)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, map[string]float64{"total": Total})
@@ -647,141 +334,228 @@ func (h *Handler) getElectricBills(w http.ResponseWriter, r *http.Request) {
 		Id    int
 		Title string
 		Total float64
+		Cost  float64
 	}
 
 	roomStats := []roomData{}
-	todayStart := time.Now().Truncate(24 * time.Hour) // today at 00:00
-	todayEnd := todayStart.Add(24 * time.Hour)        // tomorrow 00:00
+	loc := h.userLocation(userId)
+	now := time.Now().In(loc)
+	todayStart := utils.StartOfDay(now, loc) // today at 00:00
+	todayEnd := utils.NextDayStart(now, loc) // tomorrow 00:00
+	monthStart := utils.StartOfDay(time.Date(now.Year(), now.Month(), 1, 12, 0, 0, 0, loc), loc)
+
+	var monthUsages, todayUsages []tariff.Usage
 	for _, room := range rooms {
-		var roomdata = roomData{
-			Id:    room.ID,
-			Title: room.Title,
-			Total: 0,
-		}
-		devices, err := h.roomStore.GetDevicesByRoomId(room.ID)
+		usages, err := h.roomUsage(room.ID, todayStart, todayEnd)
 		if err != nil {
 			log.Println(err)
 			continue
 		}
 
-		for _, deviceFeedId := range devices {
-			// Fetch device info to know type
-			device, err := h.deviceStore.GetDevicesByFeedID(deviceFeedId)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
+		before, err := h.roomUsage(room.ID, monthStart, todayStart)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
 
-			// Only care about fan and light
-			if device.Type == "door" {
-				continue
-			}
+		todayUsages = append(todayUsages, usages...)
+		monthUsages = append(monthUsages, before...)
 
-			// Get logs of the device today
-			logs, err := h.deviceLog.GetLogsByFeedIDBetween(deviceFeedId, todayStart, todayEnd)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
+		roomStats = append(roomStats, roomData{
+			Id:    room.ID,
+			Title: room.Title,
+			Total: totalKWh(usages),
+		})
+	}
 
-			// Apply energy multiplier
-			if device.Type == "fan" {
-				roomdata.Total += calculateOnTimeHours(logs, todayEnd, "0") * 3.0 // fan: 3kWh
-			} else if device.Type == "light" {
-				roomdata.Total += calculateOnTimeHours(logs, todayEnd, "#000000") * 2.0 // light: 2kWh
-			}
+	// today's share of the month's bill, so that tiers already filled by
+	// earlier days are priced in
+	todayCost := h.tariff.Calculate(append(monthUsages, todayUsages...)).Total - h.tariff.Calculate(monthUsages).Total
+	todayKWh := totalKWh(todayUsages)
+	for i := range roomStats {
+		if todayKWh > 0 {
+			roomStats[i].Cost = todayCost * roomStats[i].Total / todayKWh
 		}
-
-		roomStats = append(roomStats, roomdata)
 	}
 
 	utils.WriteJSON(w, http.StatusOK, roomStats)
 }
 
-func calculateOnTimeHours(logs []types.LogDevice, endOfDay time.Time, offvalue string) float64 {
-	if len(logs) == 0 {
-		return 0
+func (h *Handler) getMonthlyBill(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+
+	// the months of the household, the tariff's timezone only places its
+	// time-of-use windows
+	loc := h.userLocation(userId)
+	now := time.Now().In(loc)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	if m := r.URL.Query().Get("month"); m != "" {
+		var err error
+		month, err = time.ParseInLocation("2006-01", m, loc)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("month must be formatted as YYYY-MM"))
+			return
+		}
 	}
 
-	sort.Slice(logs, func(i, j int) bool {
-		return logs[i].CreatedAt.Before(logs[j].CreatedAt)
-	})
+	start := month
+	monthEnd := month.AddDate(0, 1, 0)
+	end := monthEnd
+	if start.After(now) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("month %s is in the future", month.Format("2006-01")))
+		return
+	}
+	if end.After(now) {
+		end = now
+	}
 
-	var lastOnTime *time.Time
-	var totalHours float64
+	rooms, err := h.roomStore.GetRoomsByUserID(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
 
-	for _, log := range logs {
-		createdAt := log.CreatedAt
-		isOn := log.Value != offvalue
+	type roomBill struct {
+		ID    int     `json:"id"`
+		Title string  `json:"title"`
+		KWh   float64 `json:"kwh"`
+		Cost  float64 `json:"cost"`
+	}
 
-		if isOn && lastOnTime == nil {
-			lastOnTime = &createdAt
-		} else if !isOn && lastOnTime != nil {
-			totalHours += createdAt.Sub(*lastOnTime).Hours()
-			lastOnTime = nil
+	var usages []tariff.Usage
+	roomBills := []roomBill{}
+	for _, room := range rooms {
+		roomUsages, err := h.roomUsage(room.ID, start, end)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
 		}
+		usages = append(usages, roomUsages...)
+		roomBills = append(roomBills, roomBill{
+			ID:    room.ID,
+			Title: room.Title,
+			KWh:   totalKWh(roomUsages),
+		})
 	}
 
-	if lastOnTime != nil {
-		totalHours += endOfDay.Sub(*lastOnTime).Hours()
+	bill := h.tariff.Calculate(usages)
+	for i := range roomBills {
+		if bill.KWh > 0 {
+			roomBills[i].Cost = bill.Total * roomBills[i].KWh / bill.KWh
+		}
 	}
 
-	return totalHours
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"month":     month.Format("2006-01"),
+		"start":     start,
+		"end":       end,
+		"bill":      bill,
+		"projected": h.tariff.Project(usages, start, monthEnd, end),
+		"rooms":     roomBills,
+	})
 }
 
-func (h *Handler) getSensorStatistic(w http.ResponseWriter, r *http.Request) {
-	params := mux.Vars(r)
-	feed_id, _ := strconv.Atoi(params["feed_id"])
+// roomUsage returns the energy drawn by every powered device of a room,
+// one entry per device and hour it was on.
+func (h *Handler) roomUsage(roomId int, start, end time.Time) ([]tariff.Usage, error) {
+	usages := []tariff.Usage{}
+	for _, t := range devicetype.Metered() {
+		devices, err := h.deviceStore.GetDevicesByRoomIdAndType(roomId, t.Name)
+		if err != nil {
+			return nil, err
+		}
 
-	var payload types.RequestStatisticDevicePayload
-	if err := utils.ParseJSON(r, &payload); err != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing payload"))
-		return
+		// hourly where it's still kept, so time-of-use periods can be told apart
+		res := h.retention.DeviceResolution(start, time.Hour, time.Now())
+		buckets, err := h.rollups.GetDeviceUsage(devices, res, start, end)
+		if err != nil {
+			return nil, err
+		}
+
+		// weighted by the load, a light dimmed to 5% doesn't draw full power
+		for _, u := range buckets {
+			usages = append(usages, tariff.Usage{
+				Start: u.Start,
+				End:   u.End,
+				KWh:   u.LoadSeconds / 3600 * t.PowerKW,
+			})
+		}
 	}
+	return usages, nil
+}
 
-	// get all log belong to
+func totalKWh(usages []tariff.Usage) float64 {
+	total := 0.0
+	for _, u := range usages {
+		total += u.KWh
+	}
+	return total
+}
 
-	logs, err := h.sensorLog.GetSensorsByFeedIDBetween(feed_id, payload.Start, payload.End)
+// totalOnHours sums the on-time of the devices between start and end.
+func (h *Handler) totalOnHours(feedIds []int, start, end time.Time) (float64, error) {
+	res := h.retention.DeviceResolution(start, 24*time.Hour, time.Now())
+	usage, err := h.rollups.GetDeviceUsage(feedIds, res, start, end)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
-		return
+		return 0, err
 	}
-	if len(logs) == 0 {
-		utils.WriteJSON(w, http.StatusOK, map[string]float64{})
-		return
+
+	total := 0.0
+	for _, u := range usage {
+		total += u.OnSeconds / 3600
 	}
+	return total, nil
+}
 
-	// Group by date and calculate average
-	type dateStat struct {
-		total float64
-		count int
+// dailyOnHours sums the on-time of the devices per local day.
+func (h *Handler) dailyOnHours(feedIds []int, start, end time.Time, loc *time.Location) (map[string]float64, error) {
+	res := h.retention.DeviceResolution(start, 24*time.Hour, time.Now())
+	usage, err := h.rollups.GetDeviceUsage(feedIds, res, start, end)
+	if err != nil {
+		return nil, err
 	}
-	dailyStats := make(map[string]*dateStat)
 
-	for _, log := range logs {
-		createdAt := log.CreatedAt.Format("2006-01-02") // Only keep date part
-		value, err := strconv.ParseFloat(log.Value, 64)
-		if err != nil {
-			continue // skip if value cannot be parsed
-		}
+	dayHours := utils.InitDateList(start, end, loc) // date "YYYY-MM-DD" -> total running hours
+	for day, hours := range rollup.DailyOnHours(usage, loc) {
+		dayHours[day] += hours
+	}
+	return dayHours, nil
+}
 
-		if _, exists := dailyStats[createdAt]; !exists {
-			dailyStats[createdAt] = &dateStat{}
-		}
-		dailyStats[createdAt].total += value
-		dailyStats[createdAt].count++
+// dailySensorAverages averages the readings of a sensor per local day.
+func (h *Handler) dailySensorAverages(sensor types.Sensor, start, end time.Time, loc *time.Location) (map[string]float64, error) {
+	res := h.retention.Sensor(sensor.Type).Resolution(start, 24*time.Hour, time.Now())
+	stats, err := h.rollups.GetSensorStats(sensor.FeedId, res, start, end)
+	if err != nil {
+		return nil, err
 	}
 
-	// Build response
-	result := initDateList(payload.Start, payload.End)
-	for date, stat := range dailyStats {
-		result[date] = stat.total / float64(stat.count)
+	result := utils.InitDateList(start, end, loc)
+	for date, stat := range rollup.DailySensorStats(stats, loc) {
+		result[date] = stat.Avg()
 	}
+	return result, nil
+}
 
-	utils.WriteJSON(w, http.StatusOK, result)
+// userLocation returns the timezone of the user's household, statistics
+// are bucketed by its calendar days.
+func (h *Handler) userLocation(userId int) *time.Location {
+	u, err := h.userStore.GetUserByID(userId)
+	if err != nil {
+		return utils.LoadLocation("")
+	}
+	return utils.LoadLocation(u.Timezone)
 }
 
-func (h *Handler) getDeviceStatistic(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) roomLocation(roomId int) *time.Location {
+	room, err := h.roomStore.GetRoomByID(roomId)
+	if err != nil {
+		return utils.LoadLocation("")
+	}
+	return h.userLocation(room.UserID)
+}
+
+func (h *Handler) getSensorStatistic(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	feed_id, _ := strconv.Atoi(params["feed_id"])
 
@@ -791,103 +565,42 @@ func (h *Handler) getDeviceStatistic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	device, err := h.deviceStore.GetDevicesByFeedID(feed_id)
+	sensor, err := h.sensorStore.GetSensor(feed_id)
 	if err != nil {
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	offvalue := "0"
-	if device.Type == "light" {
-		offvalue = "#000000"
-	}
-
-	// get all log
-	logs, err := h.deviceLog.GetLogsByFeedIDBetween(feed_id, payload.Start, payload.End)
+	result, err := h.dailySensorAverages(*sensor, payload.Start, payload.End, h.userLocation(sensor.UserID))
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	if len(logs) == 0 {
-		utils.WriteJSON(w, http.StatusOK, map[string]float64{})
-		return
-	}
+	utils.WriteJSON(w, http.StatusOK, result)
+}
 
-	// Sort logs by createdAt (just in case DB doesn't guarantee)
-	sort.Slice(logs, func(i, j int) bool {
-		return logs[i].CreatedAt.Before(logs[j].CreatedAt)
-	})
+func (h *Handler) getDeviceStatistic(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	feed_id, _ := strconv.Atoi(params["feed_id"])
 
-	var lastOnTime *time.Time
-	dayHours := initDateList(payload.Start, payload.End) // date "YYYY-MM-DD" -> total running hours
-
-	for _, log := range logs {
-		createdAt := log.CreatedAt
-		isOn := log.Value != offvalue // value 0 means off
-
-		if isOn && lastOnTime == nil {
-			// Device just turned on
-			lastOnTime = &createdAt
-		} else if !isOn && lastOnTime != nil {
-			// Device just turned off -> calculate time
-			hoursByDay := splitDurationByDay(*lastOnTime, createdAt)
-			for day, hours := range hoursByDay {
-				dayHours[day] += hours
-			}
-			lastOnTime = nil
-		}
+	var payload types.RequestStatisticDevicePayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("missing payload"))
+		return
 	}
 
-	// If still "on" at the end of log, close the period
-	if lastOnTime != nil {
-		endTime := payload.End
-		hoursByDay := splitDurationByDay(*lastOnTime, endTime)
-		for day, hours := range hoursByDay {
-			dayHours[day] += hours
-		}
+	device, err := h.deviceStore.GetDevice(feed_id)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, dayHours)
-}
-
-func splitDurationByDay(start, end time.Time) map[string]float64 {
-	result := make(map[string]float64)
-
-	curr := start
-	for curr.Before(end) {
-		// End of current day
-		dayEnd := time.Date(curr.Year(), curr.Month(), curr.Day(), 23, 59, 59, 0, time.UTC)
-
-		if dayEnd.After(end) {
-			dayEnd = end
-		}
-
-		duration := dayEnd.Sub(curr)
-		dayStr := curr.Format("2006-01-02")
-		result[dayStr] += duration.Hours()
-
-		curr = dayEnd.Add(time.Second) // move to next day start
+	dayHours, err := h.dailyOnHours([]int{feed_id}, payload.Start, payload.End, h.userLocation(device.UserID))
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
 	}
 
-	return result
-}
-
-func initDateList(start, end time.Time) map[string]float64 {
-	listHours := make(map[string]float64)
-	curr := start
-	for curr.Before(end) {
-		// End of current day
-		dayEnd := time.Date(curr.Year(), curr.Month(), curr.Day(), 23, 59, 59, 0, time.UTC)
-
-		if dayEnd.After(end) {
-			dayEnd = end
-		}
-
-		dayStr := curr.Format("2006-01-02")
-		listHours[dayStr] = 0
-
-		curr = dayEnd.Add(time.Second) // move to next day start
-	}
-	return listHours
+	utils.WriteJSON(w, http.StatusOK, dayHours)
 }
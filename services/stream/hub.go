@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/services/events"
+)
+
+// events sent on the stream
+const (
+	EventDeviceState   = "device.state"
+	EventDeviceShadow  = "device.shadow"
+	EventSensorReading = "sensor.reading"
+)
+
+// messages kept for a client that's slow to read, later ones are dropped
+const clientBuffer = 32
+
+// Message is an event sent to the clients of a user.
+type Message struct {
+	Event string
+	Data  any
+}
+
+// Hub fans the events on the bus out to the stream clients of their owners.
+type Hub struct {
+	mu      sync.Mutex
+	clients map[int]map[chan Message]bool
+}
+
+func NewHub() *Hub {
+	return &Hub{clients: map[int]map[chan Message]bool{}}
+}
+
+// Subscribe sends the device states, the shadows and the sensor readings on
+// the bus to the clients.
+func (h *Hub) Subscribe(bus *events.Bus) {
+	bus.DeviceStateChanged.Subscribe(func(e events.DeviceStateChanged) {
+		state := map[string]any{
+			"feedId": e.Device.FeedId,
+			"type":   e.Device.Type,
+			"value":  e.Value,
+			"at":     e.At,
+		}
+		if level, ok := devicetype.LevelOf(e.Device.Type, e.Device.Levels, e.Value); ok {
+			state["level"] = level
+		}
+		h.send(e.Device.UserID, Message{EventDeviceState, state})
+	})
+
+	bus.ShadowChanged.Subscribe(func(e events.ShadowChanged) {
+		h.send(e.Device.UserID, Message{EventDeviceShadow, e.Shadow})
+	})
+
+	bus.SensorReading.Subscribe(func(e events.SensorReading) {
+		h.send(e.Sensor.UserID, Message{EventSensorReading, map[string]any{
+			"feedId": e.Sensor.FeedId,
+			"type":   e.Sensor.Type,
+			"value":  e.Raw,
+			"at":     e.At,
+		}})
+	})
+}
+
+// join registers a client of the user, leave is to be called once it's gone.
+func (h *Hub) join(userId int) chan Message {
+	ch := make(chan Message, clientBuffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[userId] == nil {
+		h.clients[userId] = map[chan Message]bool{}
+	}
+	h.clients[userId][ch] = true
+	return ch
+}
+
+func (h *Hub) leave(userId int, ch chan Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients[userId], ch)
+	if len(h.clients[userId]) == 0 {
+		delete(h.clients, userId)
+	}
+}
+
+// send doesn't wait for the clients, the bus is shared with the rest of the
+// server.
+func (h *Hub) send(userId int, m Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients[userId] {
+		select {
+		case ch <- m:
+		default:
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/events"
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+func TestHubSendsToTheOwnersClients(t *testing.T) {
+	bus := events.NewBus()
+	hub := NewHub()
+	hub.Subscribe(bus)
+
+	owner := hub.join(1)
+	other := hub.join(2)
+	defer hub.leave(1, owner)
+	defer hub.leave(2, other)
+
+	bus.DeviceStateChanged.Publish(events.DeviceStateChanged{
+		Device: types.Device{FeedId: 5, Type: "fan", UserID: 1},
+		Value:  "100",
+		At:     time.Now(),
+	})
+
+	select {
+	case m := <-owner:
+		state := m.Data.(map[string]any)
+		if m.Event != EventDeviceState || state["level"] != 3 {
+			t.Errorf("unexpected message %+v", m)
+		}
+	default:
+		t.Fatal("expected the owner's client to get the state")
+	}
+
+	select {
+	case m := <-other:
+		t.Errorf("expected nothing for another user, got %+v", m)
+	default:
+	}
+}
+
+func TestHubDropsForSlowClients(t *testing.T) {
+	hub := NewHub()
+	ch := hub.join(1)
+	defer hub.leave(1, ch)
+
+	// sending never blocks, whatever the client reads
+	for i := 0; i < clientBuffer+5; i++ {
+		hub.send(1, Message{Event: EventDeviceShadow})
+	}
+	if len(ch) != clientBuffer {
+		t.Errorf("expected %d buffered messages, got %d", clientBuffer, len(ch))
+	}
+}
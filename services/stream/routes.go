@@ -0,0 +1,72 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/types"
+	"github.com/quanghia24/mySmartHome/utils"
+)
+
+// comment sent while there are no events, so proxies keep the connection
+const heartbeat = 30 * time.Second
+
+type Handler struct {
+	hub       *Hub
+	userStore types.UserStore
+}
+
+func NewHandler(hub *Hub, userStore types.UserStore) *Handler {
+	return &Handler{
+		hub:       hub,
+		userStore: userStore,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/stream", auth.WithJWTAuth(h.stream, h.userStore)).Methods(http.MethodGet)
+}
+
+// stream sends the events of the user's home as server-sent events until
+// the client goes away.
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("streaming unsupported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	messages := h.hub.join(userId)
+	defer h.hub.leave(userId, messages)
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case m := <-messages:
+			data, err := json.Marshal(m.Data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", m.Event, data)
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,367 @@
+package tariff
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tier is one step of a progressive tariff. UpTo is the upper bound of the
+// step in kWh over the billing period, 0 means the step has no upper bound.
+type Tier struct {
+	UpTo  float64 `json:"upTo"`
+	Price float64 `json:"price"`
+}
+
+// Window is a daily time range in "HH:MM" form. End may be lower than Start
+// for ranges that wrap past midnight. An empty Days list matches every day.
+type Window struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Days  []string `json:"days"`
+}
+
+// Period is a time-of-use price. A period without windows is the fallback
+// used whenever no other period matches.
+type Period struct {
+	Name    string   `json:"name"`
+	Price   float64  `json:"price"`
+	Windows []Window `json:"windows"`
+}
+
+// Tariff prices either by progressive tiers over the billing period or, when
+// TimeOfUse is set, by the period each kWh was drawn in. Prices exclude VAT.
+type Tariff struct {
+	Name      string   `json:"name"`
+	Currency  string   `json:"currency"`
+	Timezone  string   `json:"timezone"`
+	VAT       float64  `json:"vat"`
+	Tiers     []Tier   `json:"tiers"`
+	TimeOfUse []Period `json:"timeOfUse"`
+}
+
+// Usage is energy drawn evenly between Start and End.
+type Usage struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	KWh   float64   `json:"kwh"`
+}
+
+type Line struct {
+	Label  string  `json:"label"`
+	KWh    float64 `json:"kwh"`
+	Price  float64 `json:"price"`
+	Amount float64 `json:"amount"`
+}
+
+type Bill struct {
+	Tariff   string  `json:"tariff"`
+	Currency string  `json:"currency"`
+	KWh      float64 `json:"kwh"`
+	Lines    []Line  `json:"lines"`
+	Subtotal float64 `json:"subtotal"`
+	VAT      float64 `json:"vat"`
+	Total    float64 `json:"total"`
+}
+
+// EVNResidential is the six step household tariff of Vietnam Electricity
+// (Decision 1279/QĐ-BCT, effective 10/05/2025), with the reduced 8% VAT.
+func EVNResidential() Tariff {
+	return Tariff{
+		Name:     "evn-residential",
+		Currency: "VND",
+		Timezone: "Asia/Ho_Chi_Minh",
+		VAT:      0.08,
+		Tiers: []Tier{
+			{UpTo: 50, Price: 1984},
+			{UpTo: 100, Price: 2050},
+			{UpTo: 200, Price: 2380},
+			{UpTo: 300, Price: 2998},
+			{UpTo: 400, Price: 3350},
+			{UpTo: 0, Price: 3460},
+		},
+	}
+}
+
+// EVNTimeOfUse is the low voltage time-of-use variant: off-peak every night,
+// peak hours Monday to Saturday and the normal rate otherwise.
+func EVNTimeOfUse() Tariff {
+	weekdays := []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+	return Tariff{
+		Name:     "evn-tou",
+		Currency: "VND",
+		Timezone: "Asia/Ho_Chi_Minh",
+		VAT:      0.08,
+		TimeOfUse: []Period{
+			{Name: "off-peak", Price: 1918, Windows: []Window{{Start: "22:00", End: "04:00"}}},
+			{Name: "peak", Price: 5422, Windows: []Window{
+				{Start: "09:30", End: "11:30", Days: weekdays},
+				{Start: "17:00", End: "20:00", Days: weekdays},
+			}},
+			{Name: "normal", Price: 3152},
+		},
+	}
+}
+
+// Load returns the tariff configured through TARIFF_FILE (a JSON encoded
+// Tariff) or TARIFF ("evn" or "evn-tou"), defaulting to EVNResidential.
+func Load() (Tariff, error) {
+	if path := os.Getenv("TARIFF_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Tariff{}, fmt.Errorf("read tariff file: %v", err)
+		}
+		var t Tariff
+		if err := json.Unmarshal(data, &t); err != nil {
+			return Tariff{}, fmt.Errorf("parse tariff file: %v", err)
+		}
+		return t, t.Validate()
+	}
+
+	switch os.Getenv("TARIFF") {
+	case "", "evn":
+		return EVNResidential(), nil
+	case "evn-tou":
+		return EVNTimeOfUse(), nil
+	default:
+		return Tariff{}, fmt.Errorf("unknown tariff %q", os.Getenv("TARIFF"))
+	}
+}
+
+func (t Tariff) Validate() error {
+	if len(t.Tiers) == 0 && len(t.TimeOfUse) == 0 {
+		return fmt.Errorf("tariff %q has neither tiers nor time-of-use periods", t.Name)
+	}
+	if _, err := t.location(); err != nil {
+		return err
+	}
+	for i, tier := range t.Tiers {
+		last := i == len(t.Tiers)-1
+		if tier.UpTo == 0 && !last {
+			return fmt.Errorf("only the last tier may be unbounded")
+		}
+		if i > 0 && tier.UpTo != 0 && tier.UpTo <= t.Tiers[i-1].UpTo {
+			return fmt.Errorf("tier %d must end above %v kWh", i+1, t.Tiers[i-1].UpTo)
+		}
+	}
+	for _, p := range t.TimeOfUse {
+		for _, w := range p.Windows {
+			if _, err := parseClock(w.Start); err != nil {
+				return err
+			}
+			if _, err := parseClock(w.End); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Calculate prices the given usage over one billing period.
+func (t Tariff) Calculate(usages []Usage) Bill {
+	if len(t.TimeOfUse) > 0 {
+		return t.calculateTimeOfUse(usages)
+	}
+
+	total := 0.0
+	for _, u := range usages {
+		total += u.KWh
+	}
+	return t.CalculateKWh(total)
+}
+
+// CalculateKWh prices a plain kWh amount. Time-of-use tariffs have no time
+// information to go on here, so everything is billed at the fallback period.
+func (t Tariff) CalculateKWh(kwh float64) Bill {
+	bill := Bill{Tariff: t.Name, Currency: t.Currency, KWh: kwh}
+
+	if len(t.TimeOfUse) > 0 {
+		p := t.fallback()
+		bill.Lines = append(bill.Lines, Line{Label: p.Name, KWh: kwh, Price: p.Price, Amount: kwh * p.Price})
+		return t.finish(bill)
+	}
+
+	remaining := kwh
+	lower := 0.0
+	for i, tier := range t.Tiers {
+		if remaining <= 0 {
+			break
+		}
+		size := remaining
+		if tier.UpTo > 0 && tier.UpTo-lower < size {
+			size = tier.UpTo - lower
+		}
+		bill.Lines = append(bill.Lines, Line{
+			Label:  fmt.Sprintf("tier %d", i+1),
+			KWh:    size,
+			Price:  tier.Price,
+			Amount: size * tier.Price,
+		})
+		remaining -= size
+		lower = tier.UpTo
+	}
+	return t.finish(bill)
+}
+
+// Project extrapolates the usage drawn since periodStart up to now over the
+// whole billing period, a calendar month of the household, and prices the
+// result.
+func (t Tariff) Project(usages []Usage, periodStart, periodEnd, now time.Time) Bill {
+	elapsed := now.Sub(periodStart)
+	if elapsed <= 0 || !now.Before(periodEnd) {
+		return t.Calculate(usages)
+	}
+	factor := periodEnd.Sub(periodStart).Hours() / elapsed.Hours()
+
+	projected := make([]Usage, len(usages))
+	for i, u := range usages {
+		projected[i] = u
+		projected[i].KWh = u.KWh * factor
+	}
+	return t.Calculate(projected)
+}
+
+func (t Tariff) calculateTimeOfUse(usages []Usage) Bill {
+	loc, _ := t.location()
+	perPeriod := make(map[string]float64)
+	total := 0.0
+
+	for _, u := range usages {
+		total += u.KWh
+		duration := u.End.Sub(u.Start)
+		if duration <= 0 {
+			perPeriod[t.periodAt(u.Start.In(loc)).Name] += u.KWh
+			continue
+		}
+
+		curr := u.Start.In(loc)
+		end := u.End.In(loc)
+		for curr.Before(end) {
+			next := t.nextBoundary(curr)
+			if next.After(end) {
+				next = end
+			}
+			perPeriod[t.periodAt(curr).Name] += u.KWh * next.Sub(curr).Hours() / duration.Hours()
+			curr = next
+		}
+	}
+
+	bill := Bill{Tariff: t.Name, Currency: t.Currency, KWh: total}
+	for _, p := range t.TimeOfUse {
+		kwh, ok := perPeriod[p.Name]
+		if !ok {
+			continue
+		}
+		bill.Lines = append(bill.Lines, Line{Label: p.Name, KWh: kwh, Price: p.Price, Amount: kwh * p.Price})
+	}
+	return t.finish(bill)
+}
+
+func (t Tariff) finish(bill Bill) Bill {
+	for _, l := range bill.Lines {
+		bill.Subtotal += l.Amount
+	}
+	bill.Subtotal = math.Round(bill.Subtotal)
+	bill.VAT = math.Round(bill.Subtotal * t.VAT)
+	bill.Total = bill.Subtotal + bill.VAT
+	return bill
+}
+
+func (t Tariff) location() (*time.Location, error) {
+	if t.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("tariff timezone: %v", err)
+	}
+	return loc, nil
+}
+
+func (t Tariff) fallback() Period {
+	for _, p := range t.TimeOfUse {
+		if len(p.Windows) == 0 {
+			return p
+		}
+	}
+	return t.TimeOfUse[len(t.TimeOfUse)-1]
+}
+
+// periodAt returns the first period with a window covering the local time.
+func (t Tariff) periodAt(local time.Time) Period {
+	minute := local.Hour()*60 + local.Minute()
+	day := local.Weekday().String()[:3]
+
+	for _, p := range t.TimeOfUse {
+		for _, w := range p.Windows {
+			if w.covers(day, minute, local) {
+				return p
+			}
+		}
+	}
+	return t.fallback()
+}
+
+// nextBoundary returns the first window edge or midnight after local.
+func (t Tariff) nextBoundary(local time.Time) time.Time {
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	edges := []time.Time{midnight.AddDate(0, 0, 1)}
+
+	for _, p := range t.TimeOfUse {
+		for _, w := range p.Windows {
+			for _, clock := range []string{w.Start, w.End} {
+				m, _ := parseClock(clock)
+				edge := midnight.Add(time.Duration(m) * time.Minute)
+				if edge.After(local) {
+					edges = append(edges, edge)
+				}
+			}
+		}
+	}
+
+	sort.Slice(edges, func(i, j int) bool { return edges[i].Before(edges[j]) })
+	return edges[0]
+}
+
+func (w Window) covers(day string, minute int, local time.Time) bool {
+	start, _ := parseClock(w.Start)
+	end, _ := parseClock(w.End)
+
+	if start <= end {
+		return minute >= start && minute < end && w.onDay(day)
+	}
+
+	// the window wraps past midnight, the early morning part belongs to the
+	// day the window started on
+	if minute >= start {
+		return w.onDay(day)
+	}
+	if minute < end {
+		return w.onDay(local.AddDate(0, 0, -1).Weekday().String()[:3])
+	}
+	return false
+}
+
+func (w Window) onDay(day string) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if strings.EqualFold(d, day) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseClock(clock string) (int, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
@@ -0,0 +1,94 @@
+package tariff
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCalculateKWhTiers(t *testing.T) {
+	bill := EVNResidential().CalculateKWh(120)
+
+	if len(bill.Lines) != 3 {
+		t.Fatalf("expected 3 tiers to be used, got %d", len(bill.Lines))
+	}
+
+	// 50*1984 + 50*2050 + 20*2380
+	expected := 99200.0 + 102500 + 47600
+	if bill.Subtotal != expected {
+		t.Errorf("expected subtotal %v, got %v", expected, bill.Subtotal)
+	}
+	if bill.VAT != math.Round(expected*0.08) {
+		t.Errorf("expected VAT %v, got %v", math.Round(expected*0.08), bill.VAT)
+	}
+	if bill.Total != bill.Subtotal+bill.VAT {
+		t.Errorf("expected total to include VAT")
+	}
+}
+
+func TestCalculateKWhLastTierUnbounded(t *testing.T) {
+	bill := EVNResidential().CalculateKWh(1000)
+
+	last := bill.Lines[len(bill.Lines)-1]
+	if last.KWh != 600 || last.Price != 3460 {
+		t.Errorf("expected 600 kWh at 3460, got %v at %v", last.KWh, last.Price)
+	}
+}
+
+func TestCalculateTimeOfUse(t *testing.T) {
+	tou := EVNTimeOfUse()
+	loc, _ := time.LoadLocation(tou.Timezone)
+
+	// Monday 16:00 -> 18:00: one hour normal, one hour peak
+	start := time.Date(2025, 6, 2, 16, 0, 0, 0, loc)
+	bill := tou.Calculate([]Usage{{Start: start, End: start.Add(2 * time.Hour), KWh: 2}})
+
+	amounts := map[string]float64{}
+	for _, l := range bill.Lines {
+		amounts[l.Label] = l.KWh
+	}
+	if math.Abs(amounts["normal"]-1) > 1e-9 || math.Abs(amounts["peak"]-1) > 1e-9 {
+		t.Errorf("expected 1 kWh normal and 1 kWh peak, got %v", amounts)
+	}
+}
+
+func TestCalculateTimeOfUseWrapsMidnight(t *testing.T) {
+	tou := EVNTimeOfUse()
+	loc, _ := time.LoadLocation(tou.Timezone)
+
+	// Sunday 23:00 -> Monday 05:00: five hours off-peak, one hour normal
+	start := time.Date(2025, 6, 1, 23, 0, 0, 0, loc)
+	bill := tou.Calculate([]Usage{{Start: start, End: start.Add(6 * time.Hour), KWh: 6}})
+
+	amounts := map[string]float64{}
+	for _, l := range bill.Lines {
+		amounts[l.Label] = l.KWh
+	}
+	if math.Abs(amounts["off-peak"]-5) > 1e-9 || math.Abs(amounts["normal"]-1) > 1e-9 {
+		t.Errorf("expected 5 kWh off-peak and 1 kWh normal, got %v", amounts)
+	}
+}
+
+func TestProject(t *testing.T) {
+	tariff := EVNResidential()
+	loc, _ := time.LoadLocation(tariff.Timezone)
+
+	monthStart := time.Date(2025, 6, 1, 0, 0, 0, 0, loc)
+	now := monthStart.AddDate(0, 0, 10)
+
+	bill := tariff.Project([]Usage{{Start: monthStart, End: now, KWh: 100}}, monthStart, monthStart.AddDate(0, 1, 0), now)
+	if math.Abs(bill.KWh-300) > 1e-9 {
+		t.Errorf("expected 300 kWh projected over 30 days, got %v", bill.KWh)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := Tariff{Tiers: []Tier{{UpTo: 0, Price: 1}, {UpTo: 100, Price: 2}}}
+	if bad.Validate() == nil {
+		t.Errorf("expected unbounded middle tier to be rejected")
+	}
+
+	if EVNResidential().Validate() != nil || EVNTimeOfUse().Validate() != nil {
+		t.Errorf("expected default tariffs to be valid")
+	}
+}
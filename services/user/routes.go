@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/services/i18n"
 	"github.com/quanghia24/mySmartHome/types"
 	"github.com/quanghia24/mySmartHome/utils"
 )
@@ -44,6 +46,28 @@ func (h *Handler) handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
 	}
 	payload.ID = userId
 
+	if payload.Timezone == "" || payload.Locale == "" {
+		u, err := h.store.GetUserByID(userId)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("user doesn't exist in database"))
+			return
+		}
+		if payload.Timezone == "" {
+			payload.Timezone = u.Timezone
+		}
+		if payload.Locale == "" {
+			payload.Locale = u.Locale
+		}
+	}
+	if _, err := time.LoadLocation(payload.Timezone); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown timezone %q", payload.Timezone))
+		return
+	}
+	if !i18n.IsLocale(payload.Locale) {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("unsupported locale %q, expected one of %v", payload.Locale, i18n.Locales))
+		return
+	}
+
 	err := h.store.UpdateProfile(payload)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, err)
@@ -19,7 +19,7 @@ func NewStore(db *sql.DB) *Store {
 
 // repository
 func (s *Store) GetUserByEmail(email string) (*types.User, error) {
-	rows, err := s.db.Query("SELECT * FROM users WHERE email = ?", email)
+	rows, err := s.db.Query("SELECT id, firstName, lastName, email, password, avatar, createdAt, timezone, locale FROM users WHERE email = ?", email)
 	if err != nil {
 		return nil, err
 	}
@@ -46,9 +46,11 @@ func (s *Store) UpdateProfile(profile types.User) error {
 		UPDATE users
 		SET firstName = ?,
 		lastName = ?,
-		avatar = ?
+		avatar = ?,
+		timezone = ?,
+		locale = ?
 		WHERE id = ?
-	`, profile.FirstName, profile.LastName, profile.Avatar, profile.ID)
+	`, profile.FirstName, profile.LastName, profile.Avatar, profile.Timezone, profile.Locale, profile.ID)
 	return err
 }
 
@@ -63,6 +65,8 @@ func scanRowIntoUser(rows *sql.Rows) (*types.User, error) {
 		&user.Password,
 		&user.Avatar,
 		&user.CreatedAt,
+		&user.Timezone,
+		&user.Locale,
 	)
 
 	if err != nil {
@@ -73,7 +77,7 @@ func scanRowIntoUser(rows *sql.Rows) (*types.User, error) {
 }
 
 func (s *Store) GetUserByID(id int) (*types.User, error) {
-	rows, err := s.db.Query("SELECT * FROM users WHERE id = ?", id)
+	rows, err := s.db.Query("SELECT id, firstName, lastName, email, password, avatar, createdAt, timezone, locale FROM users WHERE id = ?", id)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,221 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/quanghia24/mySmartHome/services/auth"
+	"github.com/quanghia24/mySmartHome/types"
+	"github.com/quanghia24/mySmartHome/utils"
+)
+
+const (
+	defaultDeliveryPage = 50
+	maxDeliveryPage     = 200
+)
+
+type Handler struct {
+	store     types.WebhookStore
+	userStore types.UserStore
+	sender    *Sender
+}
+
+func NewHandler(store types.WebhookStore, userStore types.UserStore, sender *Sender) *Handler {
+	return &Handler{
+		store:     store,
+		userStore: userStore,
+		sender:    sender,
+	}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/webhooks", auth.WithJWTAuth(h.handleGetWebhooks, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/webhooks", auth.WithJWTAuth(h.handleCreateWebhook, h.userStore)).Methods(http.MethodPost)
+	router.HandleFunc("/webhooks/{id}", auth.WithJWTAuth(h.handleUpdateWebhook, h.userStore)).Methods(http.MethodPut)
+	router.HandleFunc("/webhooks/{id}", auth.WithJWTAuth(h.handleDeleteWebhook, h.userStore)).Methods(http.MethodDelete)
+	router.HandleFunc("/webhooks/{id}/deliveries", auth.WithJWTAuth(h.handleGetDeliveries, h.userStore)).Methods(http.MethodGet)
+	router.HandleFunc("/webhooks/{id}/test", auth.WithJWTAuth(h.handleTestWebhook, h.userStore)).Methods(http.MethodPost)
+}
+
+func (h *Handler) handleGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+
+	hooks, err := h.store.GetWebhooksByUserID(userId)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, hooks)
+}
+
+// handleCreateWebhook adds the webhook with a new secret, which the receiver
+// checks the signature of the posts with.
+func (h *Handler) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userId := auth.GetUserIDFromContext(r.Context())
+
+	var payload types.CreateWebhookPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		error := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", error))
+		return
+	}
+	if err := validateURL(payload.URL); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	hook := types.Webhook{
+		UserID:  userId,
+		URL:     payload.URL,
+		Secret:  secret,
+		Events:  payload.Events,
+		Enabled: true,
+	}
+	hook.ID, err = h.store.CreateWebhook(hook)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, hook)
+}
+
+func (h *Handler) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var payload types.UpdateWebhookPayload
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := utils.Validate.Struct(payload); err != nil {
+		error := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", error))
+		return
+	}
+	if payload.URL != nil {
+		if err := validateURL(*payload.URL); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		hook.URL = *payload.URL
+	}
+	if payload.Events != nil {
+		hook.Events = payload.Events
+	}
+	if payload.Enabled != nil {
+		hook.Enabled = *payload.Enabled
+	}
+
+	if err := h.store.UpdateWebhook(*hook); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, hook)
+}
+
+func (h *Handler) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteWebhook(hook.ID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, "webhook deleted")
+}
+
+// handleGetDeliveries lists the latest deliveries of the webhook, newest
+// first, up to ?limit of them.
+func (h *Handler) handleGetDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultDeliveryPage
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeliveryPage {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxDeliveryPage))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.store.GetWebhookDeliveries(hook.ID, limit)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, deliveries)
+}
+
+// handleTestWebhook posts a test event to the webhook, so the user can check
+// their receiver gets it and accepts its signature.
+func (h *Handler) handleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	d, err := h.sender.Test(*hook)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if d.Status != deliveryDelivered {
+		utils.WriteJSON(w, http.StatusBadGateway, d)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, d)
+}
+
+// ownedWebhook loads the webhook of the path, writing the error response
+// when it isn't the user's.
+func (h *Handler) ownedWebhook(w http.ResponseWriter, r *http.Request) (*types.Webhook, bool) {
+	userId := auth.GetUserIDFromContext(r.Context())
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid webhook id"))
+		return nil, false
+	}
+
+	hook, err := h.store.GetWebhook(id)
+	if err != nil || hook.UserID != userId {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("webhook %d not found", id))
+		return nil, false
+	}
+	return hook, true
+}
+
+// validateURL keeps webhooks to http(s) urls on the internet.
+func validateURL(target string) error {
+	if err := utils.ValidatePublicURL(target); err != nil {
+		return fmt.Errorf("invalid webhook url: %v", err)
+	}
+	return nil
+}
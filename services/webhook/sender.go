@@ -0,0 +1,234 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/types"
+	"github.com/quanghia24/mySmartHome/utils"
+)
+
+// events a webhook can subscribe to
+const (
+	EventDeviceState     = "device.state"
+	EventSensorReading   = "sensor.reading"
+	EventThresholdBreach = "threshold.breach"
+	EventDoorUnlock      = "door.unlock"
+	EventScheduleRun     = "schedule.run"
+	EventOrderStatus     = "order.status"
+
+	// sent by the test button, whatever the webhook subscribed to
+	EventTest = "webhook.test"
+)
+
+// statuses of a delivery
+const (
+	deliveryPending   = "pending"
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+)
+
+const maxAttempts = 5
+
+// Sender posts the events of a user's home to their webhooks. Every post is
+// signed with the webhook's secret: the X-Webhook-Signature header is
+// "sha256=" and the hex HMAC-SHA256 of the X-Webhook-Timestamp header, a dot
+// and the body.
+type Sender struct {
+	store  types.WebhookStore
+	client *http.Client
+
+	// wait before the second attempt, doubled for each next one
+	retryDelay time.Duration
+	wg         sync.WaitGroup
+}
+
+func NewSender(store types.WebhookStore) *Sender {
+	return &Sender{
+		store:      store,
+		client:     utils.PublicClient(10 * time.Second),
+		retryDelay: 10 * time.Second,
+	}
+}
+
+// envelope is the body of a post.
+type envelope struct {
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+// Emit posts the event to the user's enabled webhooks that subscribed to it.
+// It returns right away, the posts are retried in the background.
+func (s *Sender) Emit(userId int, event string, data any) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		hooks, err := s.store.GetWebhooksByUserID(userId)
+		if err != nil {
+			log.Println("webhooks:", err)
+			return
+		}
+		for _, h := range hooks {
+			if !h.Enabled || !slices.Contains(h.Events, event) {
+				continue
+			}
+			d, err := s.record(h, event, data)
+			if err != nil {
+				log.Printf("webhook %d delivery: %v\n", h.ID, err)
+				continue
+			}
+
+			s.wg.Add(1)
+			go func(h types.Webhook) {
+				defer s.wg.Done()
+				s.deliver(h, d)
+			}(h)
+		}
+	}()
+}
+
+// Test posts a test event to the webhook and returns the delivery after its
+// single attempt.
+func (s *Sender) Test(h types.Webhook) (*types.WebhookDelivery, error) {
+	d, err := s.record(h, EventTest, map[string]any{"webhookId": h.ID, "url": h.URL})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.attempt(h, d); err != nil {
+		d.Status = deliveryFailed
+	}
+	if err := s.store.UpdateWebhookDelivery(*d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// record keeps the delivery of the event before its first attempt.
+func (s *Sender) record(h types.Webhook, event string, data any) (*types.WebhookDelivery, error) {
+	body, err := json.Marshal(envelope{Event: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return nil, err
+	}
+
+	d := &types.WebhookDelivery{
+		WebhookID: h.ID,
+		Event:     event,
+		Payload:   body,
+		Status:    deliveryPending,
+	}
+	d.ID, err = s.store.CreateWebhookDelivery(*d)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// deliver makes up to maxAttempts attempts, waiting longer before each, and
+// keeps the outcome of every attempt on the delivery.
+func (s *Sender) deliver(h types.Webhook, d *types.WebhookDelivery) {
+	delay := s.retryDelay
+	for {
+		retry, err := s.attempt(h, d)
+		if err != nil && (!retry || d.Attempts >= maxAttempts) {
+			d.Status = deliveryFailed
+			log.Printf("webhook %d delivery %d: %v\n", h.ID, d.ID, err)
+		}
+		if err := s.store.UpdateWebhookDelivery(*d); err != nil {
+			log.Printf("webhook %d delivery %d: %v\n", h.ID, d.ID, err)
+		}
+		if d.Status != deliveryPending {
+			return
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// attempt posts the delivery once. A failure is worth retrying unless the
+// receiver refused the post.
+func (s *Sender) attempt(h types.Webhook, d *types.WebhookDelivery) (bool, error) {
+	d.Attempts++
+	d.ResponseStatus = 0
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		d.Error = truncate(err.Error(), 255)
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mySmartHome-Webhook")
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.Itoa(d.ID))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(h.Secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		d.Error = truncate(err.Error(), 255)
+		// the host may have moved into a private network since the url
+		// was checked, it won't come back out by retrying
+		return !errors.Is(err, utils.ErrNotPublic), err
+	}
+	defer resp.Body.Close()
+	d.ResponseStatus = resp.StatusCode
+
+	// the body of the response isn't kept, it's shown to the user and
+	// could be anything the url serves
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("receiver responded %s", resp.Status)
+		d.Error = truncate(err.Error(), 255)
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+		return retry, err
+	}
+
+	now := time.Now()
+	d.Status, d.Error, d.DeliveredAt = deliveryDelivered, "", &now
+	return false, nil
+}
+
+// Sign is the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed
+// with the webhook's secret.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newSecret makes the secret of a new webhook.
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// Wait blocks until the deliveries in flight are done.
+func (s *Sender) Wait() {
+	s.wg.Wait()
+}
@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+type hooks struct {
+	types.WebhookStore
+	mu         sync.Mutex
+	webhooks   []types.Webhook
+	deliveries map[int]types.WebhookDelivery
+}
+
+func (s *hooks) GetWebhooksByUserID(userId int) ([]types.Webhook, error) {
+	return s.webhooks, nil
+}
+
+func (s *hooks) CreateWebhookDelivery(d types.WebhookDelivery) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.deliveries == nil {
+		s.deliveries = map[int]types.WebhookDelivery{}
+	}
+	d.ID = len(s.deliveries) + 1
+	s.deliveries[d.ID] = d
+	return d.ID, nil
+}
+
+func (s *hooks) UpdateWebhookDelivery(d types.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[d.ID] = d
+	return nil
+}
+
+// receiver answers with the statuses in turn, then with 200.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	posts    []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rc := &receiver{statuses: statuses}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		rc.posts = append(rc.posts, r)
+		rc.bodies = append(rc.bodies, body)
+		if len(rc.statuses) > 0 {
+			w.WriteHeader(rc.statuses[0])
+			rc.statuses = rc.statuses[1:]
+		}
+		io.WriteString(w, "internal details")
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+// testSender posts to the receivers on the loopback interface, which the
+// client of the sender refuses.
+func testSender(store *hooks) *Sender {
+	s := NewSender(store)
+	s.client = &http.Client{}
+	s.retryDelay = 0
+	return s
+}
+
+func TestEmitSignsAndPosts(t *testing.T) {
+	rc := newReceiver(t)
+	store := &hooks{webhooks: []types.Webhook{
+		{ID: 1, URL: rc.URL, Secret: "secret", Events: []string{EventDoorUnlock}, Enabled: true},
+		{ID: 2, URL: rc.URL, Secret: "secret", Events: []string{EventDeviceState}, Enabled: true},
+		{ID: 3, URL: rc.URL, Secret: "secret", Events: []string{EventDoorUnlock}},
+	}}
+	s := testSender(store)
+
+	s.Emit(1, EventDoorUnlock, map[string]int{"feedId": 7})
+	s.Wait()
+
+	if len(rc.posts) != 1 {
+		t.Fatalf("expected only the enabled subscribed webhook, got %d posts", len(rc.posts))
+	}
+	post, body := rc.posts[0], rc.bodies[0]
+	if post.Header.Get("X-Webhook-Event") != EventDoorUnlock || post.Header.Get("X-Webhook-Delivery") != "1" {
+		t.Errorf("unexpected headers %v", post.Header)
+	}
+	signature := "sha256=" + Sign("secret", post.Header.Get("X-Webhook-Timestamp"), body)
+	if post.Header.Get("X-Webhook-Signature") != signature {
+		t.Errorf("expected the signature %s, got %s", signature, post.Header.Get("X-Webhook-Signature"))
+	}
+
+	var got envelope
+	if err := json.Unmarshal(body, &got); err != nil || got.Event != EventDoorUnlock {
+		t.Errorf("unexpected body %s", body)
+	}
+	if d := store.deliveries[1]; d.Status != deliveryDelivered || d.Attempts != 1 || d.DeliveredAt == nil {
+		t.Errorf("expected the delivery to be delivered, got %+v", d)
+	}
+}
+
+func TestEmitRetries(t *testing.T) {
+	rc := newReceiver(t, http.StatusBadGateway, http.StatusTooManyRequests)
+	store := &hooks{webhooks: []types.Webhook{{ID: 1, URL: rc.URL, Events: []string{EventScheduleRun}, Enabled: true}}}
+	s := testSender(store)
+
+	s.Emit(1, EventScheduleRun, nil)
+	s.Wait()
+
+	if d := store.deliveries[1]; d.Status != deliveryDelivered || d.Attempts != 3 || d.Error != "" {
+		t.Errorf("expected the third attempt to deliver, got %+v", d)
+	}
+}
+
+func TestEmitGivesUp(t *testing.T) {
+	statuses := []int{}
+	for i := 0; i < maxAttempts; i++ {
+		statuses = append(statuses, http.StatusServiceUnavailable)
+	}
+	rc := newReceiver(t, statuses...)
+	store := &hooks{webhooks: []types.Webhook{{ID: 1, URL: rc.URL, Events: []string{EventScheduleRun}, Enabled: true}}}
+	s := testSender(store)
+
+	s.Emit(1, EventScheduleRun, nil)
+	s.Wait()
+
+	d := store.deliveries[1]
+	if d.Status != deliveryFailed || d.Attempts != maxAttempts || d.ResponseStatus != http.StatusServiceUnavailable {
+		t.Errorf("expected the delivery to fail after %d attempts, got %+v", maxAttempts, d)
+	}
+}
+
+func TestEmitDoesNotRetryRefusedPosts(t *testing.T) {
+	rc := newReceiver(t, http.StatusNotFound)
+	store := &hooks{webhooks: []types.Webhook{{ID: 1, URL: rc.URL, Events: []string{EventScheduleRun}, Enabled: true}}}
+	s := testSender(store)
+
+	s.Emit(1, EventScheduleRun, nil)
+	s.Wait()
+
+	if d := store.deliveries[1]; d.Status != deliveryFailed || d.Attempts != 1 {
+		t.Errorf("expected a single attempt, got %+v", d)
+	}
+}
+
+func TestTestEvent(t *testing.T) {
+	rc := newReceiver(t, http.StatusInternalServerError)
+	store := &hooks{}
+	s := testSender(store)
+
+	d, err := s.Test(types.Webhook{ID: 1, URL: rc.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Event != EventTest || d.Status != deliveryFailed || d.Attempts != 1 {
+		t.Errorf("expected a single failed attempt, got %+v", d)
+	}
+	if strings.Contains(d.Error, "internal details") {
+		t.Errorf("expected the response body to stay out of the error, got %q", d.Error)
+	}
+}
+
+func TestEmitRefusesPrivateAddresses(t *testing.T) {
+	rc := newReceiver(t)
+	store := &hooks{webhooks: []types.Webhook{{ID: 1, URL: rc.URL, Events: []string{EventScheduleRun}, Enabled: true}}}
+	s := NewSender(store)
+	s.retryDelay = 0
+
+	s.Emit(1, EventScheduleRun, nil)
+	s.Wait()
+
+	if len(rc.posts) != 0 {
+		t.Errorf("expected no post to the loopback receiver, got %d", len(rc.posts))
+	}
+	if d := store.deliveries[1]; d.Status != deliveryFailed || d.Attempts != 1 {
+		t.Errorf("expected a single refused attempt, got %+v", d)
+	}
+}
@@ -0,0 +1,157 @@
+package webhook
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/quanghia24/mySmartHome/types"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) CreateWebhook(h types.Webhook) (int, error) {
+	res, err := s.db.Exec("INSERT INTO webhooks (userId, url, secret, events, enabled) VALUES (?, ?, ?, ?, ?)",
+		h.UserID, h.URL, h.Secret, strings.Join(h.Events, ","), h.Enabled)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+func (s *Store) GetWebhook(id int) (*types.Webhook, error) {
+	rows, err := s.db.Query("SELECT id, userId, url, secret, events, enabled, createdAt FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("webhook %d not found", id)
+	}
+	return scanRowIntoWebhook(rows)
+}
+
+func (s *Store) GetWebhooksByUserID(userId int) ([]types.Webhook, error) {
+	rows, err := s.db.Query("SELECT id, userId, url, secret, events, enabled, createdAt FROM webhooks WHERE userId = ? ORDER BY id", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []types.Webhook{}
+	for rows.Next() {
+		h, err := scanRowIntoWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, *h)
+	}
+	return hooks, rows.Err()
+}
+
+func (s *Store) UpdateWebhook(h types.Webhook) error {
+	_, err := s.db.Exec("UPDATE webhooks SET url = ?, events = ?, enabled = ? WHERE id = ?", h.URL, strings.Join(h.Events, ","), h.Enabled, h.ID)
+	return err
+}
+
+func (s *Store) DeleteWebhook(id int) error {
+	_, err := s.db.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	return err
+}
+
+func scanRowIntoWebhook(rows *sql.Rows) (*types.Webhook, error) {
+	h := new(types.Webhook)
+	var events string
+	err := rows.Scan(
+		&h.ID,
+		&h.UserID,
+		&h.URL,
+		&h.Secret,
+		&events,
+		&h.Enabled,
+		&h.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	h.Events = []string{}
+	if events != "" {
+		h.Events = strings.Split(events, ",")
+	}
+	return h, nil
+}
+
+func (s *Store) CreateWebhookDelivery(d types.WebhookDelivery) (int, error) {
+	res, err := s.db.Exec("INSERT INTO webhook_deliveries (webhookId, event, payload, status) VALUES (?, ?, ?, ?)",
+		d.WebhookID, d.Event, string(d.Payload), d.Status)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+func (s *Store) UpdateWebhookDelivery(d types.WebhookDelivery) error {
+	_, err := s.db.Exec("UPDATE webhook_deliveries SET status = ?, attempts = ?, responseStatus = NULLIF(?, 0), error = NULLIF(?, ''), deliveredAt = ? WHERE id = ?",
+		d.Status, d.Attempts, d.ResponseStatus, d.Error, d.DeliveredAt, d.ID)
+	return err
+}
+
+// GetWebhookDeliveries returns the latest deliveries of the webhook, newest
+// first.
+func (s *Store) GetWebhookDeliveries(webhookId int, limit int) ([]types.WebhookDelivery, error) {
+	rows, err := s.db.Query(`
+		SELECT id, webhookId, event, payload, status, attempts, responseStatus, error, createdAt, deliveredAt
+		FROM webhook_deliveries
+		WHERE webhookId = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, webhookId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []types.WebhookDelivery{}
+	for rows.Next() {
+		var d types.WebhookDelivery
+		var payload []byte
+		var responseStatus sql.NullInt64
+		var reason sql.NullString
+		var deliveredAt sql.NullTime
+		err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.Event,
+			&payload,
+			&d.Status,
+			&d.Attempts,
+			&responseStatus,
+			&reason,
+			&d.CreatedAt,
+			&deliveredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		d.Payload = payload
+		d.ResponseStatus = int(responseStatus.Int64)
+		d.Error = reason.String
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"github.com/quanghia24/mySmartHome/services/devicetype"
+	"github.com/quanghia24/mySmartHome/services/events"
+)
+
+// Subscribe posts the device states, the sensor readings and the threshold
+// breaches on the bus to the webhooks of their owners.
+func (s *Sender) Subscribe(bus *events.Bus) {
+	bus.DeviceStateChanged.Subscribe(func(e events.DeviceStateChanged) {
+		state := map[string]any{
+			"feedId": e.Device.FeedId,
+			"title":  e.Device.Title,
+			"type":   e.Device.Type,
+			"value":  e.Value,
+		}
+		if level, ok := devicetype.LevelOf(e.Device.Type, e.Device.Levels, e.Value); ok {
+			state["level"] = level
+		}
+		s.Emit(e.Device.UserID, EventDeviceState, state)
+	})
+
+	bus.SensorReading.Subscribe(func(e events.SensorReading) {
+		s.Emit(e.Sensor.UserID, EventSensorReading, map[string]any{
+			"feedId": e.Sensor.FeedId,
+			"title":  e.Sensor.Title,
+			"type":   e.Sensor.Type,
+			"value":  e.Value,
+		})
+	})
+
+	bus.ThresholdCrossed.Subscribe(func(e events.ThresholdCrossed) {
+		s.Emit(e.Sensor.UserID, EventThresholdBreach, map[string]any{
+			"feedId":    e.Sensor.FeedId,
+			"title":     e.Sensor.Title,
+			"type":      e.Sensor.Type,
+			"kind":      e.Event.Kind,
+			"bound":     e.Event.Bound,
+			"threshold": e.Event.Threshold,
+			"value":     e.Event.Value,
+			"severity":  e.Event.Severity,
+		})
+	})
+}
@@ -1,6 +1,11 @@
 package types
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/quanghia24/mySmartHome/services/i18n"
+)
 
 type UserStore interface {
 	GetUserByEmail(email string) (*User, error)
@@ -11,6 +16,7 @@ type UserStore interface {
 
 type RoomStore interface {
 	CreateRoom(Room) error
+	GetRoomByID(roomId int) (*Room, error)
 	GetRoomsByUserID(userId int) ([]RoomInfoPayload, error)
 	GetDevicesByRoomId(roomId int) ([]int, error)
 	UpdateRoom(Room) error
@@ -19,20 +25,35 @@ type RoomStore interface {
 
 type DeviceStore interface {
 	CreateDevice(Device) error
+	GetDevice(feedId int) (*Device, error)
+	GetDeviceByFeedKey(feedKey string) (*Device, error)
 	GetAllDevices() ([]AllDeviceDataPayload, error)
 	GetDevicesByUserID(userId int) ([]DeviceDataPayload, error)
 	GetDevicesByFeedID(feedId int) (*DeviceDataPayload, error)
 	GetDevicesInRoomID(id int) ([]DeviceDataPayload, error)
 	GetDevicesByRoomIdAndType(roomId int, mtype string) ([]int, error)
 	GetDevicesByType(userId int, mtype string) ([]int, error)
+	UpdateDevice(Device) error
 	DeleteDevice(deviceId string, userId int) error
 }
 
 type SensorStore interface {
 	CreateSensor(Sensor) error
+	GetSensor(feedId int) (*Sensor, error)
+	GetSensorByFeedKey(feedKey string) (*Sensor, error)
 	GetSensorByFeedID(feedId int) (*DeviceDataPayload, error)
 	GetAllSensor() ([]Sensor, error)
 	GetSensorsByRoomId(roomId int) ([]Sensor, error)
+	UpdateSensor(Sensor) error
+	UpdateAnomalySensitivity(feedId int, sensitivity string) error
+	RemoveSensor(feedId int) error
+}
+
+// FeedSubscriptions keeps the MQTT subscriptions to the feeds of the devices
+// and the sensors.
+type FeedSubscriptions interface {
+	Subscribe(feedKey string) error
+	Unsubscribe(feedKey string) error
 }
 
 type LogDeviceStore interface {
@@ -41,6 +62,7 @@ type LogDeviceStore interface {
 	GetLogsByFeedIDBetween(feedId int, start time.Time, end time.Time) ([]LogDevice, error)
 	GetLogsByFeedID7Days(feedId int, end time.Time) ([]LogDevice, error)
 	GetLogsByUserID(userId int) ([]LogDevice, error)
+	StreamLogsByFeedIDsBetween(feedIds []int, start time.Time, end time.Time, fn func(LogDevice) error) error
 }
 
 type LogSensorStore interface {
@@ -48,6 +70,19 @@ type LogSensorStore interface {
 	GetLogSensorsByUserID(userId int) ([]LogSensor, error)
 	GetSensorsByFeedIDBetween(feedId int, start time.Time, end time.Time) ([]LogSensor, error)
 	GetLogSensorsLast7HoursByFeedID(feedId int, end time.Time) ([]LogSensor, error)
+	GetLatestSensorData(feedId int) (*LogSensor, error)
+	StreamSensorsByFeedIDsBetween(feedIds []int, start time.Time, end time.Time, fn func(LogSensor) error) error
+}
+
+type RollupStore interface {
+	AddDeviceLog(logId int) error
+	AddSensorLog(logId int) error
+	GetDeviceUsage(feedIds []int, res Resolution, start time.Time, end time.Time) ([]DeviceUsage, error)
+	GetSensorStats(feedId int, res Resolution, start time.Time, end time.Time) ([]SensorStats, error)
+	PruneSensorLogs(sensorType string, before time.Time) (int64, error)
+	PruneSensorStats(sensorType string, res Resolution, before time.Time) (int64, error)
+	PruneDeviceUsage(res Resolution, before time.Time) (int64, error)
+	Backfill() error
 }
 
 type ProductStore interface {
@@ -82,40 +117,320 @@ type PlanStore interface {
 	GetPlansByFeedID(int) (*Plan, error)
 }
 
-type NotiStore interface {
-	CreateNotiIp(NotiIpPayload) error
-	GetNotiIpByUserId(userId int) (*NotiIpPayload, error)
+type AlertStore interface {
+	CreateAlert(Alert) error
+	GetAlertByID(id int) (*Alert, error)
+	GetAlertsByUserID(userId int, statuses []string) ([]Alert, error)
+	GetActiveAlert(sourceType string, sourceId int, kind string) (*Alert, error)
+	AcknowledgeAlert(id int, userId int) error
+	ResolveAlert(id int) error
+	ResolveActiveAlerts(sourceType string, sourceId int, kind string) (int64, error)
+	GetUnacknowledgedAlerts() ([]Alert, error)
+	SetEscalationLevel(id int, level int) error
+}
+
+type HouseholdStore interface {
+	InviteMember(ownerId int, memberId int) error
+	AcceptInvite(ownerId int, memberId int) (bool, error)
+	RemoveMember(ownerId int, memberId int) error
+	GetMembers(ownerId int) ([]User, error)
+	GetMemberships(memberId int) ([]Membership, error)
+	IsMember(ownerId int, memberId int) (bool, error)
+}
 
-	CreateNoti(NotiPayload) error
-	GetNotiByUserId(userId int) ([]NotiPayload, error)
+type NotiStore interface {
+	SavePushToken(PushToken) error
+	GetPushToken(id int) (*PushToken, error)
+	GetPushTokensByUserID(userId int) ([]PushToken, error)
+	DeletePushToken(id int) error
+	DeletePushTokenByValue(token string) error
+
+	CreateNoti(NotiPayload) (int, error)
+	GetNoti(id int) (*NotiPayload, error)
+	GetNotiByUserId(userId int, filter NotiFilter) ([]NotiPayload, error)
+	GetNotiCounts(userId int) ([]NotiCount, error)
+	CountUnreadNoti(userId int) (int, error)
+	SetNotiRead(id int, read bool) error
+	// marks the user's notifications of the category, or all of them, read
+	MarkAllNotiRead(userId int, category string) (int64, error)
+	DeleteNoti(id int) error
+	SetNotiDelivery(id int, status string, reason string) error
+	// notifications kept back during quiet hours, oldest first
+	GetHeldNotis() ([]NotiPayload, error)
+	ReleaseHeldNotis(ids []int) error
+
+	GetNotiPreferences(userId int) ([]NotiPreference, error)
+	GetNotiPreference(userId int, event string) (*NotiPreference, error)
+	SaveNotiPreference(userId int, p NotiPreference) error
+	GetQuietHours(userId int) (*QuietHours, error)
+	SaveQuietHours(userId int, q QuietHours) error
+	DeleteQuietHours(userId int) error
+
+	CreatePushTicket(PushTicket) error
+	GetPushTicketsByNotiID(notiId int) ([]PushTicket, error)
+	// tickets still waiting for their receipt, sent within the range
+	GetPendingPushTickets(from time.Time, to time.Time) ([]PushTicket, error)
+	UpdatePushTicket(PushTicket) error
+
+	CreateChannel(NotiChannel) error
+	GetChannel(id int) (*NotiChannel, error)
+	GetChannelsByUserID(userId int) ([]NotiChannel, error)
+	UpdateChannel(NotiChannel) error
+	DeleteChannel(id int) error
+}
+
+type WebhookStore interface {
+	CreateWebhook(Webhook) (int, error)
+	GetWebhook(id int) (*Webhook, error)
+	GetWebhooksByUserID(userId int) ([]Webhook, error)
+	UpdateWebhook(Webhook) error
+	DeleteWebhook(id int) error
+
+	CreateWebhookDelivery(WebhookDelivery) (int, error)
+	UpdateWebhookDelivery(WebhookDelivery) error
+	GetWebhookDeliveries(webhookId int, limit int) ([]WebhookDelivery, error)
+}
+
+type ShadowStore interface {
+	GetShadows(deviceIds []int) ([]DeviceShadow, error)
+	GetPendingShadows(before time.Time) ([]DeviceShadow, error)
+	SaveShadow(DeviceShadow) error
+}
+
+// Resolution is the bucket size of a rollup table.
+type Resolution string
+
+const (
+	FiveMinutes Resolution = "5m"
+	Hourly      Resolution = "1h"
+	Daily       Resolution = "1d"
+)
+
+type DeviceUsage struct {
+	DeviceID  int       `json:"deviceId"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	OnSeconds float64   `json:"onSeconds"`
+	// on-time weighted by the share of the power drawn, an hour at half
+	// brightness counts half an hour
+	LoadSeconds float64 `json:"loadSeconds"`
+}
+
+type SensorStats struct {
+	SensorID int       `json:"sensorId"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Min      float64   `json:"min"`
+	Max      float64   `json:"max"`
+	Sum      float64   `json:"sum"`
+	Count    int       `json:"count"`
 }
 
 type NotiPayload struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"userID"`
-	Ip        string    `json:"ip"`
-	Message   string    `json:"message"`
-	CreatedAt time.Time `json:"created_at"`
+	ID      int    `json:"id"`
+	UserID  int    `json:"userID"`
+	Ip      string `json:"ip"`
+	Message string `json:"message"`
+	// template of the message, rendered in the reader's locale
+	MessageKey string      `json:"messageKey,omitempty"`
+	Params     i18n.Params `json:"params,omitempty"`
+	Category   string      `json:"category" validate:"omitempty,oneof=threshold door schedule system"`
+	Read       bool        `json:"read"`
+	ReadAt     *time.Time  `json:"readAt"`
+	// kept back from the phone until the digest at the end of quiet hours
+	HeldForDigest bool      `json:"heldForDigest"`
+	CreatedAt     time.Time `json:"created_at"`
+	// the digest goes out on the channels the user wants the event on
+	Event string `json:"event,omitempty"`
+	// sent, delivered or error for pushed notifications
+	DeliveryStatus string `json:"deliveryStatus,omitempty"`
+	DeliveryError  string `json:"deliveryError,omitempty"`
+}
+
+// NotiPreference lists the channels an event is sent on, none mutes it.
+type NotiPreference struct {
+	Event    string   `json:"event"`
+	Channels []string `json:"channels"`
+}
+
+type SetNotiPreferencePayload struct {
+	Channels []string `json:"channels" validate:"required,dive,oneof=push email webhook telegram"`
+}
+
+// QuietHours is the time of day, in the user's timezone, during which only
+// critical alerts reach them right away.
+type QuietHours struct {
+	Start    string `json:"start" validate:"required,datetime=15:04"`
+	End      string `json:"end" validate:"required,datetime=15:04"`
+	Timezone string `json:"timezone"`
+}
+
+// NotiFilter selects a page of a user's notifications, newest first.
+type NotiFilter struct {
+	Category string
+	Unread   bool
+	Before   int // id of the last notification of the previous page
+	Limit    int
+}
+
+type NotiCount struct {
+	Category string `json:"category"`
+	Total    int    `json:"total"`
+	Unread   int    `json:"unread"`
+}
+
+// PushTicket tracks a notification pushed to one phone, from Expo accepting
+// it to the receipt of its delivery.
+type PushTicket struct {
+	ID        int        `json:"id"`
+	NotiID    int        `json:"notiID"`
+	Token     string     `json:"token"`
+	TicketID  string     `json:"ticketID"` // empty when Expo refused the push
+	Status    string     `json:"status"`   // sent, delivered or error
+	Error     string     `json:"error"`
+	CreatedAt time.Time  `json:"createdAt"`
+	CheckedAt *time.Time `json:"checkedAt"`
+}
+
+// NotiChannel is a way to reach a user besides the push to their phone.
+type NotiChannel struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"userID"`
+	Type   string `json:"type"`   // email, webhook or telegram
+	Target string `json:"target"` // address, url or chat id
+	// only used when an alert is escalated
+	EscalationOnly bool      `json:"escalationOnly"`
+	Enabled        bool      `json:"enabled"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type CreateNotiChannelPayload struct {
+	Type           string `json:"type" validate:"required,oneof=email webhook telegram"`
+	Target         string `json:"target" validate:"required,max=512"`
+	EscalationOnly bool   `json:"escalationOnly"`
 }
 
+type UpdateNotiChannelPayload struct {
+	EscalationOnly *bool `json:"escalationOnly"`
+	Enabled        *bool `json:"enabled"`
+}
+
+// Webhook is a url that gets the events of a user's home posted to it.
+type Webhook struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"userID"`
+	URL    string `json:"url"`
+	// the receiver checks the signature of the posts with it
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreateWebhookPayload struct {
+	URL    string   `json:"url" validate:"required,url,max=512"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=device.state sensor.reading threshold.breach door.unlock schedule.run order.status"`
+}
+
+type UpdateWebhookPayload struct {
+	URL     *string  `json:"url" validate:"omitempty,url,max=512"`
+	Events  []string `json:"events" validate:"omitempty,min=1,dive,oneof=device.state sensor.reading threshold.breach door.unlock schedule.run order.status"`
+	Enabled *bool    `json:"enabled"`
+}
+
+// WebhookDelivery is an event posted to a webhook, with the outcome of its
+// last attempt.
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	WebhookID      int             `json:"webhookID"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"` // pending, delivered or failed
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"responseStatus,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt"`
+}
+
+// DeviceShadow is the value last commanded to a device next to the one it
+// last reported. A command is pending until the device reports its value,
+// or fails when it doesn't in time.
+type DeviceShadow struct {
+	DeviceID   int        `json:"deviceId"`
+	Desired    string     `json:"desired"`
+	DesiredAt  *time.Time `json:"desiredAt"`
+	Reported   string     `json:"reported"`
+	ReportedAt *time.Time `json:"reportedAt"`
+	Status     string     `json:"status"` // pending, confirmed or failed, empty before any command
+	Error      string     `json:"error,omitempty"`
+}
+
+// NotiIpPayload is the push token of a phone as the /noti-ip routes of
+// older app versions send it.
 type NotiIpPayload struct {
 	UserID int    `json:"userID"`
 	Ip     string `json:"ip"`
 }
 
+// PushToken is the Expo push token of one of a user's phones.
+type PushToken struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"userID"`
+	Token      string    `json:"token"`
+	Platform   string    `json:"platform"`
+	AppVersion string    `json:"appVersion"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type RegisterPushTokenPayload struct {
+	Token      string `json:"token" validate:"required,max=255"`
+	Platform   string `json:"platform" validate:"omitempty,oneof=ios android web"`
+	AppVersion string `json:"appVersion" validate:"max=32"`
+}
+
 type RequestStatisticDevicePayload struct {
 	Start time.Time `json:"start"`
 	End   time.Time `json:"end"`
 }
 
 type Plan struct {
-	ID        int       `json:"id"`
-	SensorID  int       `json:"sensorId"`
-	Lower     string    `json:"lower"`
-	Upper     string    `json:"upper"`
+	ID       int    `json:"id"`
+	SensorID int    `json:"sensorId"`
+	Lower    string `json:"lower"`
+	Upper    string `json:"upper"`
+	// how far the value has to come back past the bound to clear an alert
+	Hysteresis float64 `json:"hysteresis" validate:"gte=0"`
+	// how long the bound has to be crossed before an alert starts
+	MinDurationSeconds int `json:"minDurationSeconds" validate:"gte=0"`
+	// least time between two notified alerts
+	CooldownSeconds int `json:"cooldownSeconds" validate:"gte=0"`
+	// severity of the alerts the plan raises, warning when not set
+	Severity  string    `json:"severity" validate:"omitempty,oneof=info warning critical"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+type Alert struct {
+	ID             int        `json:"id"`
+	UserID         int        `json:"userID"`
+	SourceType     string     `json:"sourceType"` // sensor or device
+	SourceID       int        `json:"sourceId"`
+	SourceTitle    string     `json:"sourceTitle"`
+	Kind           string     `json:"kind"`
+	Severity       string     `json:"severity"`
+	Message        string     `json:"message"`
+	Value          string     `json:"value"`
+	Status         string     `json:"status"`
+	OpenedAt       time.Time  `json:"openedAt"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt"`
+	AcknowledgedBy *int       `json:"acknowledgedBy"`
+	ResolvedAt     *time.Time `json:"resolvedAt"`
+	// how far the alert got escalated while nobody acknowledged it
+	EscalationLevel int        `json:"escalationLevel"`
+	EscalatedAt     *time.Time `json:"escalatedAt"`
+}
+
 type Schedule struct {
 	ID            int    `json:"id"`
 	DeviceID      int    `json:"deviceId"`
@@ -135,23 +450,29 @@ type DoorPassword struct {
 }
 
 type LogSensor struct {
-	ID        int       `json:"id"`
-	Type      string    `json:"type"`
-	Message   string    `json:"message"`
-	SensorID  int       `json:"sensorID"`
-	UserID    int       `json:"userID"`
-	Value     string    `json:"value"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID      int    `json:"id"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	// template of the message, rendered in the reader's locale
+	MessageKey string      `json:"messageKey,omitempty"`
+	Params     i18n.Params `json:"params,omitempty"`
+	SensorID   int         `json:"sensorID"`
+	UserID     int         `json:"userID"`
+	Value      string      `json:"value"`
+	CreatedAt  time.Time   `json:"createdAt"`
 }
 
 type LogDevice struct {
-	ID        int       `json:"id"`
-	Type      string    `json:"type"`
-	Message   string    `json:"message"`
-	DeviceID  int       `json:"deviceID"`
-	UserID    int       `json:"userID"`
-	Value     string    `json:"value"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID      int    `json:"id"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	// template of the message, rendered in the reader's locale
+	MessageKey string      `json:"messageKey,omitempty"`
+	Params     i18n.Params `json:"params,omitempty"`
+	DeviceID   int         `json:"deviceID"`
+	UserID     int         `json:"userID"`
+	Value      string      `json:"value"`
+	CreatedAt  time.Time   `json:"createdAt"`
 }
 
 type Room struct {
@@ -168,15 +489,20 @@ type Device struct {
 	Type    string `json:"type"`
 	UserID  int    `json:"userID"`
 	RoomID  int    `json:"roomID"`
+	// feed values of the device's levels, nil for its type's and empty for
+	// a continuous range
+	Levels []int `json:"levels"`
 }
 
 type Sensor struct {
-	FeedId  int    `json:"feedId"`
-	FeedKey string `json:"feedKey"`
-	Title   string `json:"title"`
-	Type    string `json:"type"`
-	UserID  int    `json:"userID"`
-	RoomID  int    `json:"roomID"`
+	FeedId             int    `json:"feedId"`
+	FeedKey            string `json:"feedKey"`
+	Title              string `json:"title"`
+	Type               string `json:"type"`
+	UserID             int    `json:"userID"`
+	RoomID             int    `json:"roomID"`
+	AnomalySensitivity string `json:"anomalySensitivity"`
+	Virtual            bool   `json:"virtual"`
 }
 
 type Order struct {
@@ -222,9 +548,23 @@ type User struct {
 	Email     string    `json:"email"`
 	Password  string    `json:"-"`
 	Avatar    string    `json:"avatar"`
+	Timezone  string    `json:"timezone"`
+	Locale    string    `json:"locale"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// Membership is a home the user was invited to, AcceptedAt is nil until the
+// invite is accepted.
+type Membership struct {
+	Owner      User       `json:"owner"`
+	InvitedAt  time.Time  `json:"invitedAt"`
+	AcceptedAt *time.Time `json:"acceptedAt"`
+}
+
+type AddMemberPayload struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
 type RegisterUserPayload struct {
 	FirstName string `json:"firstName" validate:"required"`
 	LastName  string `json:"lastName" validate:"required"`
@@ -256,6 +596,40 @@ type CreateDevicePayload struct {
 	Title   string `json:"title" validate:"required"`
 	Type    string `json:"type" validate:"required"`
 	RoomID  int    `json:"roomID" validate:"required"`
+	// the type's levels when left out, [] for a continuous range
+	Levels []int `json:"levels" validate:"max=10"`
+}
+
+// UpdateDevicePayload changes the fields it has of a device, its logs,
+// schedules and password stay attached to its feedId.
+type UpdateDevicePayload struct {
+	Title   *string `json:"title" validate:"omitempty,min=1,max=255"`
+	FeedKey *string `json:"feedkey" validate:"omitempty,min=1,max=255"`
+	Type    *string `json:"type" validate:"omitempty,min=1"`
+	RoomID  *int    `json:"roomID" validate:"omitempty,gt=0"`
+	// [] makes the device continuous
+	Levels *[]int `json:"levels" validate:"omitempty,max=10"`
+}
+
+// LightCommandPayload changes the fields it has of a light's state over
+// TransitionMs. An effect sets the whole state and its own transition, the
+// other fields then adjust it.
+type LightCommandPayload struct {
+	On           *bool   `json:"on"`
+	Brightness   *int    `json:"brightness" validate:"omitempty,min=1,max=100"`
+	Color        *string `json:"color" validate:"omitempty,len=7,hexcolor"`
+	ColorTemp    *int    `json:"colorTemp" validate:"omitempty,min=2000,max=6500"`
+	Effect       string  `json:"effect"`
+	TransitionMs *int    `json:"transitionMs" validate:"omitempty,min=0,max=3600000"`
+}
+
+// UpdateSensorPayload changes the fields it has of a sensor, its logs and
+// plan stay attached to its feedId.
+type UpdateSensorPayload struct {
+	Title   *string `json:"title" validate:"omitempty,min=1,max=255"`
+	FeedKey *string `json:"feedkey" validate:"omitempty,min=1,max=255"`
+	Type    *string `json:"type" validate:"omitempty,min=1"`
+	RoomID  *int    `json:"roomID" validate:"omitempty,gt=0"`
 }
 
 type DeviceDataPayload struct {
@@ -265,6 +639,11 @@ type DeviceDataPayload struct {
 	Type      string    `json:"type"`
 	Title     string    `json:"title"`
 	CreatedAt time.Time `json:"created_at"`
+	Levels    []int     `json:"levels,omitempty"`
+	// level the value maps back to, for devices with levels
+	Level *int `json:"level,omitempty"`
+	// state of the last command to the device
+	Shadow *DeviceShadow `json:"shadow,omitempty"`
 }
 
 type AllDeviceDataPayload struct {
@@ -289,6 +668,7 @@ type RoomInfoPayload struct {
 	ID    int    `json:"id"`
 	Title string `json:"title"`
 
+	// kept for the apps reading them, Devices has every type
 	FanCount    int `json:"fanCount"`
 	FanStatus   int `json:"fanStatus"`
 	LightCount  int `json:"lightCount"`
@@ -296,6 +676,30 @@ type RoomInfoPayload struct {
 	DoorCount   int `json:"doorCount"`
 	DoorStatus  int `json:"doorStatus"`
 	SensorCount int `json:"sensorCount"`
+
+	// by device type
+	Devices map[string]DeviceTypeCount `json:"devices"`
+
+	// latest derived metrics, nil unless the room has paired sensors
+	Comfort *RoomComfort `json:"comfort"`
+}
+
+// DeviceTypeCount is the number of devices of a type in a room, with a
+// status of 1 when any of them is on.
+type DeviceTypeCount struct {
+	Count  int `json:"count"`
+	Status int `json:"status"`
+}
+
+type RoomComfort struct {
+	HeatIndex        float64 `json:"heatIndex"`
+	DewPoint         float64 `json:"dewPoint"`
+	AbsoluteHumidity float64 `json:"absoluteHumidity"`
+	Score            float64 `json:"score"`
+}
+
+type UpdateAnomalySensitivityPayload struct {
+	Sensitivity string `json:"sensitivity" validate:"required,oneof=off low medium high"`
 }
 
 type SensorDataPayload struct {
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"time"
+)
+
+// households that never picked a timezone are assumed to be in Vietnam
+const DefaultTimezone = "Asia/Ho_Chi_Minh"
+
+// LoadLocation resolves an IANA timezone name, falling back to
+// DefaultTimezone when it's empty or unknown.
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		name = DefaultTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc, _ = time.LoadLocation(DefaultTimezone)
+	}
+	return loc
+}
+
+// DayKey formats the local calendar day t falls on as "YYYY-MM-DD".
+func DayKey(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("2006-01-02")
+}
+
+// StartOfDay returns the first instant of the local day t falls on. In zones
+// where a DST change skips midnight that is the first instant after the gap.
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	// time.Date normalises a skipped midnight back into the previous day
+	for start.In(loc).Day() != local.Day() {
+		start = start.Add(time.Hour)
+	}
+	return start
+}
+
+// NextDayStart returns the first instant of the local day after the one t
+// falls on.
+func NextDayStart(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	// noon always exists, so stepping a day from there can't be normalised
+	// into the wrong date
+	noon := time.Date(local.Year(), local.Month(), local.Day()+1, 12, 0, 0, 0, loc)
+	return StartOfDay(noon, loc)
+}
+
+// SplitDurationByDay spreads [start, end) over the local days it covers and
+// returns the hours spent in each.
+func SplitDurationByDay(start, end time.Time, loc *time.Location) map[string]float64 {
+	result := make(map[string]float64)
+
+	curr := start
+	for curr.Before(end) {
+		next := NextDayStart(curr, loc)
+		if next.After(end) {
+			next = end
+		}
+
+		result[DayKey(curr, loc)] += next.Sub(curr).Hours()
+		curr = next
+	}
+
+	return result
+}
+
+// InitDateList returns a zero entry for every local day touched by
+// [start, end).
+func InitDateList(start, end time.Time, loc *time.Location) map[string]float64 {
+	listHours := make(map[string]float64)
+
+	for curr := start; curr.Before(end); curr = NextDayStart(curr, loc) {
+		listHours[DayKey(curr, loc)] = 0
+	}
+	return listHours
+}
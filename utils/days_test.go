@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return loc
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestSplitDurationByDayCutsAtLocalMidnight(t *testing.T) {
+	loc := mustLoad(t, "Asia/Ho_Chi_Minh")
+
+	// 23:30 -> 00:30 local, which is 16:30 -> 17:30 UTC on a single UTC day
+	start := time.Date(2025, 5, 1, 16, 30, 0, 0, time.UTC)
+	days := SplitDurationByDay(start, start.Add(time.Hour), loc)
+
+	if !almostEqual(days["2025-05-01"], 0.5) || !almostEqual(days["2025-05-02"], 0.5) {
+		t.Errorf("expected half an hour on each side of local midnight, got %v", days)
+	}
+}
+
+func TestSplitDurationByDayLosesNoTime(t *testing.T) {
+	loc := mustLoad(t, "Asia/Ho_Chi_Minh")
+
+	start := time.Date(2025, 5, 1, 0, 0, 0, 0, loc)
+	days := SplitDurationByDay(start, start.AddDate(0, 0, 3), loc)
+
+	if len(days) != 3 {
+		t.Fatalf("expected 3 days, got %v", days)
+	}
+	for day, hours := range days {
+		if !almostEqual(hours, 24) {
+			t.Errorf("expected %s to have 24 hours, got %v", day, hours)
+		}
+	}
+}
+
+func TestSplitDurationByDayDST(t *testing.T) {
+	loc := mustLoad(t, "America/New_York")
+
+	tests := []struct {
+		day   time.Time
+		hours float64
+	}{
+		{time.Date(2025, 3, 9, 0, 0, 0, 0, loc), 23},  // spring forward
+		{time.Date(2025, 11, 2, 0, 0, 0, 0, loc), 25}, // fall back
+		{time.Date(2025, 7, 1, 0, 0, 0, 0, loc), 24},
+	}
+
+	for _, tt := range tests {
+		days := SplitDurationByDay(tt.day, NextDayStart(tt.day, loc), loc)
+		key := tt.day.Format("2006-01-02")
+		if len(days) != 1 || !almostEqual(days[key], tt.hours) {
+			t.Errorf("expected %s to have %v hours, got %v", key, tt.hours, days)
+		}
+	}
+}
+
+func TestStartOfDaySkippedMidnight(t *testing.T) {
+	// Chile springs forward at midnight, 2025-09-07 starts at 01:00
+	loc := mustLoad(t, "America/Santiago")
+
+	noon := time.Date(2025, 9, 7, 12, 0, 0, 0, loc)
+	start := StartOfDay(noon, loc)
+
+	if start.In(loc).Format("2006-01-02 15:04") != "2025-09-07 01:00" {
+		t.Errorf("expected the day to start at 01:00, got %v", start.In(loc))
+	}
+
+	prev := time.Date(2025, 9, 6, 12, 0, 0, 0, loc)
+	if !NextDayStart(prev, loc).Equal(start) {
+		t.Errorf("expected next day start %v, got %v", start, NextDayStart(prev, loc))
+	}
+
+	days := SplitDurationByDay(start, NextDayStart(start, loc), loc)
+	if !almostEqual(days["2025-09-07"], 23) {
+		t.Errorf("expected a 23 hour day, got %v", days)
+	}
+}
+
+func TestSplitDurationByDayHalfHourOffset(t *testing.T) {
+	loc := mustLoad(t, "Asia/Kolkata")
+
+	// the UTC hour 18:00 -> 19:00 is 23:30 -> 00:30 in India
+	start := time.Date(2025, 5, 1, 18, 0, 0, 0, time.UTC)
+	days := SplitDurationByDay(start, start.Add(time.Hour), loc)
+
+	if !almostEqual(days["2025-05-01"], 0.5) || !almostEqual(days["2025-05-02"], 0.5) {
+		t.Errorf("expected the hour to straddle local midnight, got %v", days)
+	}
+}
+
+func TestInitDateList(t *testing.T) {
+	loc := mustLoad(t, "Asia/Ho_Chi_Minh")
+
+	start := time.Date(2025, 5, 1, 0, 0, 0, 0, loc)
+	end := time.Date(2025, 5, 4, 0, 0, 0, 0, loc)
+	days := InitDateList(start, end, loc)
+
+	if len(days) != 3 {
+		t.Errorf("expected the end to be exclusive, got %v", days)
+	}
+	for _, key := range []string{"2025-05-01", "2025-05-02", "2025-05-03"} {
+		if _, ok := days[key]; !ok {
+			t.Errorf("expected %s in %v", key, days)
+		}
+	}
+
+	// a range given in UTC still lists local days
+	days = InitDateList(start.UTC(), end.UTC(), loc)
+	if _, ok := days["2025-04-30"]; ok {
+		t.Errorf("expected no UTC day in %v", days)
+	}
+}
+
+func TestLoadLocationFallback(t *testing.T) {
+	if LoadLocation("").String() != DefaultTimezone {
+		t.Errorf("expected empty timezone to fall back to %s", DefaultTimezone)
+	}
+	if LoadLocation("Not/AZone").String() != DefaultTimezone {
+		t.Errorf("expected unknown timezone to fall back to %s", DefaultTimezone)
+	}
+	if LoadLocation("Europe/Berlin").String() != "Europe/Berlin" {
+		t.Errorf("expected Europe/Berlin to load")
+	}
+}
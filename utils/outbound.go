@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrNotPublic is the error of a url whose host isn't on the internet.
+var ErrNotPublic = errors.New("not a public address")
+
+// ranges that are neither on the internet nor covered by the net.IP checks:
+// "this network" and the carrier-grade NAT shared by the customers of an ISP
+var nonPublicRanges = []*net.IPNet{
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+}
+
+// IsPublicIP tells whether ip is an address on the internet, rather than
+// loopback, a private network or link-local, which holds the cloud metadata
+// service at 169.254.169.254.
+func IsPublicIP(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, r := range nonPublicRanges {
+		if r.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidatePublicURL checks a url given by a user is http(s) and its host
+// resolves to public addresses only, so the server can't be made to post
+// into its own network.
+func ValidatePublicURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", target)
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return fmt.Errorf("cannot resolve %s", u.Hostname())
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return fmt.Errorf("%s: %w", u.Hostname(), ErrNotPublic)
+		}
+	}
+	return nil
+}
+
+// PublicClient is an http client for the urls users give. It connects to
+// public addresses only, whatever a host resolves to by the time of the
+// request or a redirect leads to.
+func PublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		// called with the resolved address of every connection
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+				return fmt.Errorf("%s: %w", host, ErrNotPublic)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would make the connection on our behalf, unchecked
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}